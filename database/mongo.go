@@ -3,11 +3,12 @@ package database
 import (
 	"context"
 	"errors"
-	"log"
 	"sync"
 	"time"
+	"todolist/logging"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -19,6 +20,13 @@ const (
 	userCollection     = "users"
 	todolistDatabase   = "todolistdb"
 	todolistCollection = "todolist"
+	tagCollection      = "tags"
+	eventCollection    = "events"
+	syncCollection     = "syncstates"
+	//Data requests and the archives of exports are user data,
+	//they're kept with the users.
+	dataRequestCollection = "datarequests"
+	archiveBucket         = "archives"
 )
 
 //Mutex helps us to keep the conn count synced properly.
@@ -35,8 +43,42 @@ func GetTodoListCollection(dbClient *mongo.Client) *mongo.Collection {
 	return collection
 }
 
+func GetTagCollection(dbClient *mongo.Client) *mongo.Collection {
+	collection := dbClient.Database(todolistDatabase).Collection(tagCollection)
+	return collection
+}
+
+func GetEventCollection(dbClient *mongo.Client) *mongo.Collection {
+	collection := dbClient.Database(todolistDatabase).Collection(eventCollection)
+	return collection
+}
+
+func GetSyncCollection(dbClient *mongo.Client) *mongo.Collection {
+	collection := dbClient.Database(todolistDatabase).Collection(syncCollection)
+	return collection
+}
+
+func GetDataRequestCollection(dbClient *mongo.Client) *mongo.Collection {
+	collection := dbClient.Database(userDatabase).Collection(dataRequestCollection)
+	return collection
+}
+
+//GetArchiveBucket is the GridFS bucket archives of personal
+//data are stored in, they can be larger than a document.
+func GetArchiveBucket(dbClient *mongo.Client) (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(dbClient.Database(userDatabase), options.GridFSBucket().SetName(archiveBucket))
+}
+
+//Ping tells whether the server of client answers within
+//timeout, connecting doesn't wait for it.
+func Ping(client *mongo.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return client.Ping(ctx, nil)
+}
+
 func ReleaseMongoConnection(client *mongo.Client) {
-	log.Printf("Releasing db connection\n")
+	logging.Debugf("Releasing db connection")
 	if client != nil {
 		client.Disconnect(context.Background())
 	}
@@ -44,25 +86,36 @@ func ReleaseMongoConnection(client *mongo.Client) {
 	connMutex.Lock()
 	defer connMutex.Unlock()
 	connCount -= 1
-	log.Printf("Released db connection\n")
+	activeConnections.Set(float64(connCount))
+	logging.Debugf("Released db connection")
 }
 
 func GetMongoConnection(mongoURI string) (*mongo.Client, error) {
+	return GetMongoConnectionContext(context.Background(), mongoURI)
+}
+
+//GetMongoConnectionContext is a connection for the work reqCtx
+//is for, failing to connect is logged with it. The operations
+//on the connection take contexts of their own.
+func GetMongoConnectionContext(reqCtx context.Context, mongoURI string) (*mongo.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	connMutex.Lock()
 	defer connMutex.Unlock()
 	if connCount == maxConnections {
+		connectionErrors.Inc()
 		return nil, errors.New("Max DB Connection limit reached")
 	}
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(
-		mongoURI))
+		mongoURI).SetMonitor(commandMonitor()))
 	if err != nil {
-		log.Printf("Error connection to %s, err = %v\n", mongoURI, err)
+		logging.FromContext(reqCtx).Errorf("Error connection to %s, err = %v", mongoURI, err)
+		connectionErrors.Inc()
 	} else {
 		connCount += 1
-		log.Printf("Connection was successful to mongodb, current connections = %d",
+		activeConnections.Set(float64(connCount))
+		logging.Debugf("Connection was successful to mongodb, current connections = %d",
 			connCount)
 	}
 	return client, err
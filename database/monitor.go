@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"todolist/metrics"
+	"todolist/tracing"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	activeConnections = metrics.NewGauge("todolist_mongo_connections_active",
+		"MongoDB connections taken and not yet released.")
+	connectionErrors = metrics.NewCounter("todolist_mongo_connection_errors_total",
+		"MongoDB connections which couldn't be made.")
+	operationDuration = metrics.NewHistogram("todolist_db_operation_duration_seconds",
+		"How long MongoDB commands took, by command and collection.", nil, "operation", "collection")
+	operationErrors = metrics.NewCounter("todolist_db_operation_errors_total",
+		"MongoDB commands which failed, by command and collection.", "operation", "collection")
+)
+
+//command is what's known of a command sent until it's done.
+type command struct {
+	collection string
+	span       *tracing.Span
+}
+
+//commandMonitor measures the commands sent on a connection and
+//traces each under the span of the call which sent it. Commands
+//sent for work which isn't traced, such as the change stream's,
+//are only measured.
+func commandMonitor() *event.CommandMonitor {
+	var commands sync.Map
+	finish := func(evt *event.CommandFinishedEvent, failure string) {
+		value, ok := commands.Load(evt.RequestID)
+		if !ok {
+			return
+		}
+		commands.Delete(evt.RequestID)
+		cmd := value.(*command)
+		operationDuration.Observe(time.Duration(evt.DurationNanos).Seconds(), evt.CommandName, cmd.collection)
+		if failure != "" {
+			operationErrors.Inc(evt.CommandName, cmd.collection)
+		}
+		if cmd.span == nil {
+			return
+		}
+		if failure != "" {
+			cmd.span.SetError(errors.New(failure))
+		}
+		cmd.span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			cmd := &command{}
+			cmd.collection, _ = evt.Command.Lookup(evt.CommandName).StringValueOK()
+			commands.Store(evt.RequestID, cmd)
+			if tracing.SpanFromContext(ctx) == nil {
+				return
+			}
+			name := evt.CommandName
+			if cmd.collection != "" {
+				name += " " + cmd.collection
+			}
+			_, cmd.span = tracing.Start(ctx, name, tracing.Client)
+			cmd.span.SetAttribute("db.system", "mongodb")
+			cmd.span.SetAttribute("db.name", evt.DatabaseName)
+			cmd.span.SetAttribute("db.operation", evt.CommandName)
+			if cmd.collection != "" {
+				cmd.span.SetAttribute("db.mongodb.collection", cmd.collection)
+			}
+			cmd.span.SetAttribute("db.mongodb.connection_id", evt.ConnectionID)
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finish(&evt.CommandFinishedEvent, "")
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finish(&evt.CommandFinishedEvent, evt.Failure)
+		},
+	}
+}
@@ -8,6 +8,44 @@ const (
 	MongoDBConnectionString = "MONGO_DB_CONNECTION_STRING"
 	AppTokenSecret          = "APP_TOKEN_SECRET"
 	AppName                 = "APP_NAME"
+	//ItemWorkflow configures the states of an item, see
+	//model.ParseWorkflow for the format.
+	ItemWorkflow          = "ITEM_WORKFLOW"
+	ItemWorkflowDoneState = "ITEM_WORKFLOW_DONE_STATE"
+	//APIMinVersion is the oldest API version still served,
+	//APIDeprecatedVersions lists versions going away as
+	//version=sunset date pairs, e.g. 1=2027-06-30,2=2028-01-31
+	APIMinVersion         = "API_MIN_VERSION"
+	APIDeprecatedVersions = "API_DEPRECATED_VERSIONS"
+	APIUpgradeURL         = "API_UPGRADE_URL"
+	//MaxBodyBytes limits the size of request bodies of routes
+	//which don't set their own limit.
+	MaxBodyBytes = "MAX_BODY_BYTES"
+	//BatchMaxOps is the most operations one /post/batch
+	//request may carry.
+	BatchMaxOps = "BATCH_MAX_OPS"
+	//IdempotencyTTL is how long responses are kept for replay
+	//to requests with the same Idempotency-Key, e.g. 24h
+	IdempotencyTTL = "IDEMPOTENCY_TTL"
+	//EventsChangeStream set to true sends item events through
+	//a mongo change stream, for running more than one instance.
+	EventsChangeStream = "EVENTS_CHANGE_STREAM"
+	//LogLevel is the lowest level logged, one of debug, info,
+	//warn and error. LogRedactKeys are keys, comma separated,
+	//whose values are kept out of logs on top of tokens and
+	//passwords.
+	LogLevel      = "LOG_LEVEL"
+	LogRedactKeys = "LOG_REDACT_KEYS"
+	//TracesExporter is where spans go, none, stdout or otlp.
+	//OTLPEndpoint is the base URL of the collector otlp sends
+	//them to, ServiceName what they're reported as coming from.
+	//The names are those of the OpenTelemetry SDKs.
+	TracesExporter = "OTEL_TRACES_EXPORTER"
+	OTLPEndpoint   = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	ServiceName    = "OTEL_SERVICE_NAME"
+	//MetricsPort, if set, is where /metrics is served instead
+	//of PORT, so that it can be kept off the public network.
+	MetricsPort = "METRICS_PORT"
 )
 
 func GetEnvironment(variable string) string {
@@ -31,3 +69,75 @@ func GetAppName() string {
 	}
 	return appName
 }
+
+func GetItemWorkflow() string {
+	return GetEnvironment(ItemWorkflow)
+}
+
+func GetItemWorkflowDoneState() string {
+	doneState := GetEnvironment(ItemWorkflowDoneState)
+	if doneState == "" {
+		doneState = "done"
+	}
+	return doneState
+}
+
+func GetAPIMinVersion() string {
+	return GetEnvironment(APIMinVersion)
+}
+
+func GetAPIDeprecatedVersions() string {
+	return GetEnvironment(APIDeprecatedVersions)
+}
+
+func GetAPIUpgradeURL() string {
+	return GetEnvironment(APIUpgradeURL)
+}
+
+func GetMaxBodyBytes() string {
+	return GetEnvironment(MaxBodyBytes)
+}
+
+func GetBatchMaxOps() string {
+	return GetEnvironment(BatchMaxOps)
+}
+
+func GetIdempotencyTTL() string {
+	return GetEnvironment(IdempotencyTTL)
+}
+
+func GetEventsChangeStream() bool {
+	return GetEnvironment(EventsChangeStream) == "true"
+}
+
+func GetLogLevel() string {
+	return GetEnvironment(LogLevel)
+}
+
+func GetLogRedactKeys() string {
+	return GetEnvironment(LogRedactKeys)
+}
+
+func GetTracesExporter() string {
+	return GetEnvironment(TracesExporter)
+}
+
+func GetOTLPEndpoint() string {
+	endpoint := GetEnvironment(OTLPEndpoint)
+	if endpoint == "" {
+		endpoint = "http://localhost:4318"
+	}
+	return endpoint
+}
+
+func GetServiceName() string {
+	name := GetEnvironment(ServiceName)
+	if name == "" {
+		name = GetAppName()
+	}
+	return name
+}
+
+func GetMetricsPort() string {
+	return GetEnvironment(MetricsPort)
+}
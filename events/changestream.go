@@ -0,0 +1,83 @@
+package events
+
+import (
+	"time"
+	"todolist/database"
+	"todolist/logging"
+	"todolist/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	//eventRetention is how long stored events are kept, they
+	//only need to last until every instance has seen them.
+	eventRetention  = time.Hour
+	watchRetryDelay = 5 * time.Second
+)
+
+//StartChangeStream makes Publish store events in mongo and
+//hands those any instance stores to the subscribers of this
+//one. It needs a replica set, without one the events of an
+//instance stay with it.
+func StartChangeStream(mongoURI string) error {
+	dbClient, err := database.GetMongoConnection(mongoURI)
+	if err != nil {
+		return err
+	}
+	context := utils.GetContext()
+	collection := database.GetEventCollection(dbClient)
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "time", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(eventRetention.Seconds())),
+	}
+	if _, err = collection.Indexes().CreateOne(context, index); err != nil {
+		database.ReleaseMongoConnection(dbClient)
+		return err
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}}
+	stream, err := collection.Watch(context, pipeline)
+	if err != nil {
+		database.ReleaseMongoConnection(dbClient)
+		return err
+	}
+	changeStream = true
+	streamClient = dbClient
+	go watch(collection, pipeline, stream)
+	return nil
+}
+
+//watch broadcasts the events inserted into collection, it
+//resumes where it stopped when the stream fails.
+func watch(collection *mongo.Collection, pipeline mongo.Pipeline, stream *mongo.ChangeStream) {
+	context := utils.GetContext()
+	for {
+		for stream.Next(context) {
+			change := struct {
+				FullDocument Event `bson:"fullDocument"`
+			}{}
+			if err := stream.Decode(&change); err != nil {
+				logging.Errorf("Couldn't decode event: %v", err)
+				continue
+			}
+			hub.Broadcast(change.FullDocument)
+		}
+		logging.Errorf("Event change stream stopped: %v", stream.Err())
+		resumeToken := stream.ResumeToken()
+		stream.Close(context)
+		for {
+			time.Sleep(watchRetryDelay)
+			opts := options.ChangeStream()
+			if resumeToken != nil {
+				opts.SetResumeAfter(resumeToken)
+			}
+			var err error
+			if stream, err = collection.Watch(context, pipeline, opts); err == nil {
+				break
+			}
+			logging.Errorf("Couldn't watch events: %v", err)
+		}
+	}
+}
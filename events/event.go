@@ -0,0 +1,219 @@
+package events
+
+import (
+	"context"
+	"time"
+	"todolist/database"
+	"todolist/logging"
+	"todolist/metrics"
+	"todolist/model"
+	"todolist/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//Types of events, an item is shared when the users it's
+//shared with change.
+const (
+	ItemCreated = "item.created"
+	ItemUpdated = "item.updated"
+	ItemDeleted = "item.deleted"
+	ItemShared  = "item.shared"
+)
+
+//Event is a change to an item, it goes to the users in
+//Audience. ID is given by Publish, it's the _id the event is
+//stored with so that every instance knows it by the same id.
+type Event struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Type     string             `json:"type"`
+	ItemID   string             `json:"item_id"`
+	Item     *model.TodoItem    `json:"item,omitempty"`
+	Time     time.Time          `json:"time"`
+	Audience []string           `json:"-"`
+}
+
+//ItemEvents are the events about a change to item, before is
+//the item as it was if it was there. The owner and the users
+//item is shared with get eventType, with the item unless it's
+//deleted. Users it was shared with before but no longer is
+//only hear that it's gone for them, they don't get its content.
+func ItemEvents(eventType string, item *model.TodoItem, before *model.TodoItem) []Event {
+	now := time.Now().UTC()
+	audience := []string{item.Owner}
+	for _, user := range item.SharedWith {
+		if !utils.StringSlice(audience).Contains(user) {
+			audience = append(audience, user)
+		}
+	}
+	event := Event{Type: eventType, ItemID: item.ID, Time: now, Audience: audience}
+	if eventType != ItemDeleted {
+		event.Item = item
+	}
+	events := []Event{event}
+	if before == nil {
+		return events
+	}
+	var removed []string
+	for _, user := range before.SharedWith {
+		if !utils.StringSlice(audience).Contains(user) && !utils.StringSlice(removed).Contains(user) {
+			removed = append(removed, user)
+		}
+	}
+	if len(removed) > 0 {
+		events = append(events, Event{Type: ItemDeleted, ItemID: item.ID, Time: now, Audience: removed})
+	}
+	return events
+}
+
+var hub = NewHub()
+
+func init() {
+	metrics.NewGaugeFunc("todolist_event_subscribers", "Event streams open on this instance.", func() float64 {
+		return float64(hub.Subscribers())
+	})
+}
+
+//changeStream is set once StartChangeStream watches the
+//events collection, events then go through mongo so that
+//every instance sees them. streamClient is its connection.
+var changeStream bool
+var streamClient *mongo.Client
+
+type pendingKey struct{}
+
+//Pending holds back events of changes which may still be
+//rolled back, they're published once the changes are
+//committed.
+type Pending struct {
+	events []Event
+}
+
+//WithPending makes Publish add the events published with the
+//returned context to pending.
+func WithPending(ctx context.Context, pending *Pending) context.Context {
+	return context.WithValue(ctx, pendingKey{}, pending)
+}
+
+//Publish sends event to its audience on every instance.
+func (pending *Pending) Publish(ctx context.Context, dbClient *mongo.Client) {
+	for _, event := range pending.events {
+		Publish(ctx, dbClient, event)
+	}
+	pending.events = nil
+}
+
+//Publish sends event to its audience, or holds it back if ctx
+//has a Pending. With the change stream the event is stored
+//for all instances to pick up, the hub of this one included.
+func Publish(ctx context.Context, dbClient *mongo.Client, event Event) {
+	if pending, ok := ctx.Value(pendingKey{}).(*Pending); ok {
+		pending.events = append(pending.events, event)
+		return
+	}
+	event.ID = primitive.NewObjectID()
+	if changeStream && dbClient != nil {
+		//The change is made, its event goes out though the
+		//request is over.
+		_, err := database.GetEventCollection(dbClient).InsertOne(utils.Detach(ctx), event)
+		if err == nil {
+			return
+		}
+		logging.FromContext(ctx).Errorf("Couldn't store %s event of item %s, sending it here only: %v", event.Type, event.ItemID, err)
+	}
+	hub.Broadcast(event)
+}
+
+//Subscribe is Hub.Subscribe of the hub Publish sends to. With
+//the change stream the events after lastID are read from those
+//stored, so that clients which reconnect to another instance,
+//or after a restart, get what they missed.
+func Subscribe(ctx context.Context, userID string, lastID primitive.ObjectID) *Subscription {
+	var missed []Event
+	if streamClient != nil && !lastID.IsZero() {
+		missed = storedEventsAfter(ctx, streamClient, userID, lastID)
+	}
+	return hub.Subscribe(userID, lastID, missed)
+}
+
+//storedEventsAfter are the stored events which reach userID
+//after lastID, as many as a subscription holds.
+func storedEventsAfter(ctx context.Context, dbClient *mongo.Client, userID string, lastID primitive.ObjectID) []Event {
+	query := bson.M{
+		"audience": userID,
+		"_id":      bson.M{"$gt": lastID},
+	}
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(subscriptionBuffer)
+	collection := database.GetEventCollection(dbClient)
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Couldn't find events of user %s after %s: %v", userID, lastID.Hex(), err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+	var missed []Event
+	if err = cursor.All(ctx, &missed); err != nil {
+		logging.FromContext(ctx).Errorf("Error decoding events of user %s: %v", userID, err)
+		return nil
+	}
+	return missed
+}
+
+func Unsubscribe(sub *Subscription) {
+	hub.Unsubscribe(sub)
+}
+
+//EachStoredEvent calls fn with the stored events which reach
+//userID, they're only kept for a while.
+func EachStoredEvent(ctx context.Context, dbClient *mongo.Client, userID string, fn func(event *Event) error) error {
+	query := bson.M{
+		"audience": userID,
+	}
+	collection := database.GetEventCollection(dbClient)
+	cursor, err := collection.Find(ctx, query, options.Find().SetSort(bson.M{"time": 1}))
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Couldn't find events of user %s: %v", userID, err)
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		event := &Event{}
+		if err = cursor.Decode(event); err != nil {
+			logging.FromContext(ctx).Errorf("Error decoding event of user %s: %v", userID, err)
+			return err
+		}
+		if err = fn(event); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+//EraseUser forgets userID in the events stored and those this
+//instance keeps. Events about the user's items are dropped,
+//the others no longer mention the user. Other instances keep
+//theirs until they're pushed out by newer ones.
+func EraseUser(ctx context.Context, dbClient *mongo.Client, userID string) error {
+	hub.Forget(userID)
+	collection := database.GetEventCollection(dbClient)
+	if _, err := collection.DeleteMany(ctx, bson.M{"item.owner": userID}); err != nil {
+		return err
+	}
+	if _, err := collection.UpdateMany(ctx, bson.M{"audience": userID},
+		bson.M{"$pull": bson.M{"audience": userID}}); err != nil {
+		return err
+	}
+	if _, err := collection.UpdateMany(ctx, bson.M{"item.sharedwith": userID},
+		bson.M{"$pull": bson.M{"item.sharedwith": userID}}); err != nil {
+		return err
+	}
+	if _, err := collection.UpdateMany(ctx, bson.M{"item.completedby": userID},
+		bson.M{"$unset": bson.M{"item.completedby": ""}}); err != nil {
+		return err
+	}
+	_, err := collection.DeleteMany(ctx, bson.M{"audience": bson.M{"$size": 0}})
+	return err
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"todolist/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//received is what one user gets of an event.
+type received struct {
+	eventType string
+	withItem  bool
+}
+
+//byUser is what each user gets of events.
+func byUser(events []Event) map[string][]received {
+	users := map[string][]received{}
+	for _, event := range events {
+		for _, user := range event.Audience {
+			users[user] = append(users[user], received{event.Type, event.Item != nil})
+		}
+	}
+	return users
+}
+
+func TestItemEventsAudience(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		item      *model.TodoItem
+		before    *model.TodoItem
+		want      map[string][]received
+	}{
+		{
+			name:      "created",
+			eventType: ItemCreated,
+			item:      &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a"}},
+			want: map[string][]received{
+				"owner": {{ItemCreated, true}},
+				"a":     {{ItemCreated, true}},
+			},
+		},
+		{
+			name:      "updated",
+			eventType: ItemUpdated,
+			item:      &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a"}},
+			before:    &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a"}},
+			want: map[string][]received{
+				"owner": {{ItemUpdated, true}},
+				"a":     {{ItemUpdated, true}},
+			},
+		},
+		{
+			name:      "shared with another user",
+			eventType: ItemShared,
+			item:      &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a", "b"}},
+			before:    &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a"}},
+			want: map[string][]received{
+				"owner": {{ItemShared, true}},
+				"a":     {{ItemShared, true}},
+				"b":     {{ItemShared, true}},
+			},
+		},
+		{
+			name:      "unshared users don't get the content",
+			eventType: ItemShared,
+			item:      &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a"}},
+			before:    &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a", "b", "c"}},
+			want: map[string][]received{
+				"owner": {{ItemShared, true}},
+				"a":     {{ItemShared, true}},
+				"b":     {{ItemDeleted, false}},
+				"c":     {{ItemDeleted, false}},
+			},
+		},
+		{
+			name:      "deleted",
+			eventType: ItemDeleted,
+			item:      &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a"}},
+			want: map[string][]received{
+				"owner": {{ItemDeleted, false}},
+				"a":     {{ItemDeleted, false}},
+			},
+		},
+		{
+			name:      "shared with the owner",
+			eventType: ItemUpdated,
+			item:      &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"owner"}},
+			before:    &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"owner"}},
+			want: map[string][]received{
+				"owner": {{ItemUpdated, true}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := ItemEvents(test.eventType, test.item, test.before)
+			got := byUser(events)
+			if len(got) != len(test.want) {
+				t.Fatalf("got events for %v, want for %v", got, test.want)
+			}
+			for user, want := range test.want {
+				if len(got[user]) != len(want) {
+					t.Fatalf("%s got %v, want %v", user, got[user], want)
+				}
+				for idx := range want {
+					if got[user][idx] != want[idx] {
+						t.Errorf("%s got %v, want %v", user, got[user][idx], want[idx])
+					}
+				}
+			}
+			for _, event := range events {
+				if event.ItemID != test.item.ID {
+					t.Errorf("event %s is about item %s, want %s", event.Type, event.ItemID, test.item.ID)
+				}
+			}
+		})
+	}
+}
+
+//TestUnsharedReplay checks a user no longer shared an item
+//doesn't get its content when reconnecting either.
+func TestUnsharedReplay(t *testing.T) {
+	hub = NewHub()
+	before := &model.TodoItem{Owner: "owner", ID: "1", SharedWith: []string{"a"}}
+	item := &model.TodoItem{Owner: "owner", ID: "1", Content: map[string]interface{}{"secret": "x"}}
+	for _, event := range ItemEvents(ItemShared, item, before) {
+		Publish(context.Background(), nil, event)
+	}
+	sub := Subscribe(context.Background(), "a", primitive.NewObjectIDFromTimestamp(hub.recent[0].ID.Timestamp().Add(-1)))
+	defer Unsubscribe(sub)
+	if len(sub.Events) != 1 {
+		t.Fatalf("replayed %d events, want 1", len(sub.Events))
+	}
+	event := <-sub.Events
+	if event.Type != ItemDeleted || event.Item != nil {
+		t.Errorf("replayed %s with item %v, want %s without it", event.Type, event.Item, ItemDeleted)
+	}
+}
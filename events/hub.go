@@ -0,0 +1,141 @@
+package events
+
+import (
+	"bytes"
+	"sync"
+	"todolist/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	//subscriptionBuffer events may wait for a subscriber, one
+	//that falls further behind is dropped and has to reconnect.
+	subscriptionBuffer = 64
+	//recentEvents are kept for subscribers which reconnect
+	//with the id of the last event they got.
+	recentEvents = 256
+)
+
+//Subscription gets the events of UserID until it's
+//unsubscribed, or dropped for not keeping up. Either closes
+//Events.
+type Subscription struct {
+	UserID string
+	Events chan Event
+}
+
+//Hub hands every event to the subscribers in its audience.
+type Hub struct {
+	sync.Mutex
+	subscribers map[*Subscription]bool
+	recent      []Event
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[*Subscription]bool{}}
+}
+
+func (event *Event) reaches(userID string) bool {
+	return utils.StringSlice(event.Audience).Contains(userID)
+}
+
+//after tells if id comes after lastID, ids are ObjectIDs and
+//start with the time they were made at.
+func after(id, lastID primitive.ObjectID) bool {
+	return bytes.Compare(id[:], lastID[:]) > 0
+}
+
+//Subscribe starts sending the events of userID, first missed
+//and then those after lastID the hub still has which aren't
+//among them.
+func (hub *Hub) Subscribe(userID string, lastID primitive.ObjectID, missed []Event) *Subscription {
+	hub.Lock()
+	defer hub.Unlock()
+	sub := &Subscription{UserID: userID, Events: make(chan Event, subscriptionBuffer)}
+	sent := map[primitive.ObjectID]bool{}
+	for _, event := range missed {
+		if len(sub.Events) < subscriptionBuffer {
+			sub.Events <- event
+			sent[event.ID] = true
+		}
+	}
+	if !lastID.IsZero() {
+		for _, event := range hub.recent {
+			if after(event.ID, lastID) && !sent[event.ID] && event.reaches(userID) &&
+				len(sub.Events) < subscriptionBuffer {
+				sub.Events <- event
+			}
+		}
+	}
+	hub.subscribers[sub] = true
+	return sub
+}
+
+//Subscribers is how many subscriptions the hub has.
+func (hub *Hub) Subscribers() int {
+	hub.Lock()
+	defer hub.Unlock()
+	return len(hub.subscribers)
+}
+
+func (hub *Hub) Unsubscribe(sub *Subscription) {
+	hub.Lock()
+	defer hub.Unlock()
+	if hub.subscribers[sub] {
+		delete(hub.subscribers, sub)
+		close(sub.Events)
+	}
+}
+
+//Broadcast sends event to its audience.
+func (hub *Hub) Broadcast(event Event) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.recent = append(hub.recent, event)
+	if len(hub.recent) > recentEvents {
+		hub.recent = hub.recent[len(hub.recent)-recentEvents:]
+	}
+	for sub := range hub.subscribers {
+		if !event.reaches(sub.UserID) {
+			continue
+		}
+		select {
+		case sub.Events <- event:
+		default:
+			delete(hub.subscribers, sub)
+			close(sub.Events)
+		}
+	}
+}
+
+//Forget drops the recent events about the items of userID and
+//takes userID out of the audience of the others. Subscriptions
+//of userID are closed.
+func (hub *Hub) Forget(userID string) {
+	hub.Lock()
+	defer hub.Unlock()
+	recent := hub.recent[:0]
+	for _, event := range hub.recent {
+		if event.Item != nil && event.Item.Owner == userID {
+			continue
+		}
+		audience := []string{}
+		for _, user := range event.Audience {
+			if user != userID {
+				audience = append(audience, user)
+			}
+		}
+		if len(audience) > 0 {
+			event.Audience = audience
+			recent = append(recent, event)
+		}
+	}
+	hub.recent = recent
+	for sub := range hub.subscribers {
+		if sub.UserID == userID {
+			delete(hub.subscribers, sub)
+			close(sub.Events)
+		}
+	}
+}
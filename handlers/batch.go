@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"todolist/environment"
+	"todolist/events"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/responses"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultBatchMaxOps = 100
+
+	batchOpAdd    = "add"
+	batchOpEdit   = "edit"
+	batchOpRemove = "remove"
+)
+
+//batchRequest is an ordered list of operations. With Atomic
+//either all of them are applied or none is.
+type batchRequest struct {
+	Atomic     bool             `json:"atomic,omitempty"`
+	Operations []batchOperation `json:"operations" validate:"required"`
+}
+
+//batchOperation adds or edits Item, or removes the item with
+//ID, which may also be given as the id of Item.
+type batchOperation struct {
+	Op   string          `json:"op" validate:"required"`
+	Item *model.TodoItem `json:"item,omitempty"`
+	ID   string          `json:"id,omitempty"`
+}
+
+//batchResult is what became of the operation at Index, it
+//carries what the single request would have answered.
+type batchResult struct {
+	Index   int                    `json:"index"`
+	Op      string                 `json:"op"`
+	ID      string                 `json:"id,omitempty"`
+	Status  int                    `json:"status"`
+	APICode int64                  `json:"apicode"`
+	Message string                 `json:"msg,omitempty"`
+	Errors  []responses.FieldError `json:"errors,omitempty"`
+	Meta    map[string]interface{} `json:"extra,omitempty"`
+}
+
+var batchMaxOpsOnce sync.Once
+var batchMaxOps int
+
+func getBatchMaxOps() int {
+	batchMaxOpsOnce.Do(func() {
+		batchMaxOps = defaultBatchMaxOps
+		value := environment.GetBatchMaxOps()
+		if value == "" {
+			return
+		}
+		max, err := strconv.Atoi(value)
+		if err != nil || max <= 0 {
+			logging.Warnf("Ignoring %s=%s, using %d", environment.BatchMaxOps, value, batchMaxOps)
+			return
+		}
+		batchMaxOps = max
+	})
+	return batchMaxOps
+}
+
+//recorder keeps the response an operation writes, so that it
+//can go into the operation's result instead.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+}
+
+func (rec *recorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(data)
+}
+
+//batchWriter records in the locale of the batch request.
+func batchWriter(w http.ResponseWriter) (*recorder, http.ResponseWriter) {
+	rec := &recorder{header: http.Header{}}
+	nw := &negotiatedWriter{ResponseWriter: rec}
+	if parent := findNegotiatedWriter(w); parent != nil {
+		nw.locale = parent.locale
+	}
+	return rec, nw
+}
+
+//result turns what the operation wrote into its result.
+func (rec *recorder) result(index int, op *batchOperation, id string) batchResult {
+	result := batchResult{Index: index, Op: op.Op, ID: id, Status: rec.status}
+	resp := responses.Response{}
+	if err := json.Unmarshal(rec.body.Bytes(), &resp); err == nil {
+		result.APICode = resp.APICode
+		result.Message = resp.Message
+		result.Errors = resp.Errors
+		result.Meta = resp.Meta
+	}
+	if result.Status == 0 {
+		result.Status = http.StatusInternalServerError
+	}
+	return result
+}
+
+//errTooManyOperations is what scanBatchOperations returns once
+//it has read more operations than a batch may have.
+var errTooManyOperations = errors.New("too many operations")
+
+//scanBatchOperations reads the members of the items of the
+//operations in body, one operation at a time, and stops at the
+//first one past max. Anything it can't make out is left for
+//decoding the body to report, it then returns no items.
+func scanBatchOperations(body io.Reader, max int) ([]map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(body)
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return nil, nil
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, nil
+		}
+		if token != "operations" {
+			var skipped json.RawMessage
+			if dec.Decode(&skipped) != nil {
+				return nil, nil
+			}
+			continue
+		}
+		if token, err = dec.Token(); err != nil || token != json.Delim('[') {
+			return nil, nil
+		}
+		var items []map[string]json.RawMessage
+		for dec.More() {
+			if len(items) == max {
+				return nil, errTooManyOperations
+			}
+			op := struct {
+				Item map[string]json.RawMessage `json:"item"`
+			}{}
+			if dec.Decode(&op) != nil {
+				return nil, nil
+			}
+			items = append(items, op.Item)
+		}
+		return items, nil
+	}
+	return nil, nil
+}
+
+func writeBatchTooLarge(w *http.ResponseWriter, max int) {
+	resp := responses.Response{
+		Status:      http.StatusRequestEntityTooLarge,
+		APICode:     API_ERROR_CODE_REQUEST_TOO_LARGE,
+		Message:     msgBatchTooLarge,
+		MessageArgs: []interface{}{max},
+		Errors: []responses.FieldError{{Field: "operations",
+			Reason: fmt.Sprintf("at most %d operations", max)}},
+	}
+	GenericWriteResponse(w, &resp)
+}
+
+//decodeBatch fills batch from the body, writing the response if
+//it can't. A batch with too many operations is refused as soon
+//as they're counted, before any of them is decoded.
+func decodeBatch(w *http.ResponseWriter, r *http.Request, batch *batchRequest) bool {
+	max := getBatchMaxOps()
+	var scanned bytes.Buffer
+	items, err := scanBatchOperations(io.TeeReader(r.Body, &scanned), max)
+	if err == errTooManyOperations {
+		writeBatchTooLarge(w, max)
+		return false
+	}
+	r.Body = ioutil.NopCloser(io.MultiReader(&scanned, r.Body))
+	if !decodeJSONBody(w, r, batch) {
+		return false
+	}
+	//Members named other than operations, in another case,
+	//aren't counted by the scan.
+	if len(batch.Operations) > max {
+		writeBatchTooLarge(w, max)
+		return false
+	}
+	keepOmittedPriorities(items, batch)
+	return true
+}
+
+//keepOmittedPriorities sets the priority of the items which
+//leave it out to PriorityUnset, edits keep the stored one then.
+//items are the members each item was given with.
+func keepOmittedPriorities(items []map[string]json.RawMessage, batch *batchRequest) {
+	if len(items) != len(batch.Operations) {
+		return
+	}
+	for idx, item := range items {
+		if _, ok := item["priority"]; !ok && batch.Operations[idx].Item != nil {
+			batch.Operations[idx].Item.Priority = model.PriorityUnset
+		}
+	}
+}
+
+//attempt is a copy of the batch for one try at applying it,
+//applying an operation changes its item.
+func (batch *batchRequest) attempt() *batchRequest {
+	copied := &batchRequest{Atomic: batch.Atomic, Operations: make([]batchOperation, len(batch.Operations))}
+	for idx, op := range batch.Operations {
+		if op.Item != nil {
+			op.Item = op.Item.Copy()
+		}
+		copied.Operations[idx] = op
+	}
+	return copied
+}
+
+func (op *batchOperation) itemID() string {
+	if op.ID == "" && op.Item != nil {
+		return op.Item.ID
+	}
+	return op.ID
+}
+
+//applyBatchOperation does what /post/add, /post/edit and
+///post/remove do, writing the response only if it fails.
+func applyBatchOperation(w *http.ResponseWriter, ctx context.Context, connection *mongo.Client,
+	userID string, op *batchOperation) bool {
+	switch op.Op {
+	case batchOpAdd, batchOpEdit:
+		if op.Item == nil {
+			writeFieldErrors(w, msgItemRequired, responses.FieldError{Field: "item", Reason: "required"})
+			return false
+		}
+		if err := checkRequired(op.Item); err != nil {
+			writeBodyError(w, err)
+			return false
+		}
+		item := op.Item
+		item.Owner = userID
+		stored, err := model.GetOneTodoItemForOwner(ctx, connection, userID, item.ID)
+		if op.Op == batchOpAdd {
+			if idErr := checkItemID(item); idErr != nil {
+				writeBodyError(w, idErr)
+				return false
+			}
+			if err == nil {
+				GenericResponseWithEC(w, msgItemAlreadyExists, http.StatusConflict, API_ERROR_CODE_INVALID_INPUT)
+				return false
+			}
+			stored = nil
+		} else if err != nil {
+			GenericResponseWithEC(w, msgItemNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+			return false
+		}
+		return saveItem(w, ctx, connection, item, stored)
+	case batchOpRemove:
+		if op.itemID() == "" {
+			writeFieldErrors(w, msgIDRequired, responses.FieldError{Field: "id", Reason: "required"})
+			return false
+		}
+		return removeItem(w, ctx, connection, userID, op.itemID())
+	}
+	resp := responses.Response{
+		Status:      http.StatusBadRequest,
+		APICode:     API_ERROR_CODE_INVALID_INPUT,
+		Message:     msgUnknownBatchOperation,
+		MessageArgs: []interface{}{op.Op},
+		Errors:      []responses.FieldError{{Field: "op", Reason: "must be add, edit or remove"}},
+	}
+	GenericWriteResponse(w, &resp)
+	return false
+}
+
+//applyBatch applies the operations in order, with atomic it
+//stops at the first which fails. It returns the results and
+//the index of the operation that failed, -1 if none did.
+func applyBatch(w http.ResponseWriter, ctx context.Context, connection *mongo.Client, userID string,
+	batch *batchRequest) ([]batchResult, int) {
+	results := make([]batchResult, 0, len(batch.Operations))
+	failed := -1
+	for idx := range batch.Operations {
+		op := &batch.Operations[idx]
+		rec, opWriter := batchWriter(w)
+		if applyBatchOperation(&opWriter, ctx, connection, userID, op) {
+			results = append(results, batchResult{Index: idx, Op: op.Op, ID: op.itemID(),
+				Status: http.StatusOK, APICode: API_ERROR_CODE_OK})
+			continue
+		}
+		results = append(results, rec.result(idx, op, op.itemID()))
+		if failed < 0 {
+			failed = idx
+		}
+		if batch.Atomic {
+			break
+		}
+	}
+	return results, failed
+}
+
+//PostBatch applies a list of add, edit and remove operations,
+//answering with the result of each. Atomic batches run in a
+//transaction, when an operation fails none is applied and the
+//following ones aren't tried.
+func PostBatch(w http.ResponseWriter, r *http.Request) {
+	batch := batchRequest{}
+	if !decodeBatch(&w, r, &batch) {
+		return
+	}
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	var results []batchResult
+	failed := -1
+	if batch.Atomic {
+		errFailed := fmt.Errorf("batch operation failed")
+		//Events are sent once the transaction is committed.
+		var pending *events.Pending
+		err := model.WithTransaction(r.Context(), connection, func(sc mongo.SessionContext) error {
+			//The transaction may be retried, start over with the
+			//items as they were sent.
+			pending = &events.Pending{}
+			results, failed = applyBatch(w, events.WithPending(sc, pending), connection, userID, batch.attempt())
+			if failed >= 0 {
+				return errFailed
+			}
+			return nil
+		})
+		if err != nil && err != errFailed {
+			logging.FromContext(r.Context()).Errorf("Couldn't run batch of user %s in a transaction: %v", userID, err)
+			GenericInternalServerError(&w, msgUnableToProcess)
+			return
+		}
+		if err == nil {
+			pending.Publish(r.Context(), connection)
+		}
+	} else {
+		results, failed = applyBatch(w, r.Context(), connection, userID, &batch)
+	}
+	succeeded := 0
+	for _, result := range results {
+		if successStatus(result.Status) {
+			succeeded++
+		}
+	}
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		APICode: API_ERROR_CODE_OK,
+		Message: msgBatchComplete,
+		Meta: map[string]interface{}{
+			"atomic":    batch.Atomic,
+			"results":   results,
+			"succeeded": succeeded,
+			"failed":    len(batch.Operations) - succeeded,
+		},
+	}
+	if batch.Atomic && failed >= 0 {
+		//Nothing was applied, the operation which failed says why.
+		resp.Status = results[failed].Status
+		resp.APICode = results[failed].APICode
+		resp.Message = msgBatchRolledBack
+		resp.MessageArgs = []interface{}{failed}
+		resp.Meta["succeeded"] = 0
+		resp.Meta["failed"] = len(batch.Operations)
+		for idx := range results[:failed] {
+			results[idx].Status = http.StatusFailedDependency
+			results[idx].APICode = API_ERROR_CODE_GENERIC_ERROR
+			results[idx].Message = translate(&w, msgRolledBack)
+		}
+	}
+	GenericWriteResponse(&w, &resp)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"todolist/model"
+)
+
+func TestScanBatchOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		max      int
+		items    int
+		tooLarge bool
+	}{
+		{"empty", `{"operations":[]}`, 2, 0, false},
+		{"at the limit", `{"operations":[{"op":"add","item":{}},{"op":"remove","id":"1"}]}`, 2, 2, false},
+		{"past the limit", `{"operations":[{"op":"add"},{"op":"add"},{"op":"add"}]}`, 2, 0, true},
+		{"past the limit, then broken", `{"operations":[{},{},{},`, 2, 0, true},
+		{"other members first", `{"atomic":true,"x":[1,{"y":2}],"operations":[{}]}`, 2, 1, false},
+		{"not an object", `[]`, 2, 0, false},
+		{"broken", `{"operations":[{"op":`, 2, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := scanBatchOperations(strings.NewReader(test.body), test.max)
+			if (err == errTooManyOperations) != test.tooLarge {
+				t.Errorf("error %v, want too large %v", err, test.tooLarge)
+			}
+			if len(items) != test.items {
+				t.Errorf("scanned %d items, want %d", len(items), test.items)
+			}
+		})
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		status     int
+		priorities []int
+	}{
+		{"priority given", `{"operations":[{"op":"add","item":{"priority":2}}]}`, 0, []int{2}},
+		{"priority left out", `{"operations":[{"op":"edit","item":{"id":"1"}},{"op":"remove","id":"2"}]}`,
+			0, []int{model.PriorityUnset, 0}},
+		{"too many", `{"operations":[` + strings.Repeat(`{"op":"remove","id":"1"},`, getBatchMaxOps()) +
+			`{"op":"remove","id":"1"}]}`, http.StatusRequestEntityTooLarge, nil},
+		{"broken", `{"operations":[{"op":`, http.StatusBadRequest, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/post/batch", strings.NewReader(test.body))
+			recorder := httptest.NewRecorder()
+			var w http.ResponseWriter = recorder
+			batch := batchRequest{}
+			ok := decodeBatch(&w, r, &batch)
+			if ok != (test.status == 0) {
+				t.Fatalf("decoded %v, want %v", ok, test.status == 0)
+			}
+			if !ok {
+				if recorder.Code != test.status {
+					t.Errorf("status %d, want %d", recorder.Code, test.status)
+				}
+				return
+			}
+			for idx, priority := range test.priorities {
+				item := batch.Operations[idx].Item
+				if item != nil && item.Priority != priority {
+					t.Errorf("operation %d has priority %d, want %d", idx, item.Priority, priority)
+				}
+			}
+		})
+	}
+}
+
+//TestBatchAttempt checks applying the copy of an attempt leaves
+//the batch as it was sent for the next one.
+func TestBatchAttempt(t *testing.T) {
+	item := &model.TodoItem{ID: "1", SharedWith: []string{"a"}, Tags: []string{"t"},
+		Content: map[string]interface{}{"k": "v"}}
+	batch := &batchRequest{Atomic: true, Operations: []batchOperation{
+		{Op: "add", Item: item},
+		{Op: "remove", ID: "2"},
+	}}
+	attempt := batch.attempt()
+	changed := attempt.Operations[0].Item
+	changed.Owner = "user"
+	changed.Status = "done"
+	changed.SharedWith[0] = "b"
+	changed.Tags = append(changed.Tags, "u")
+	changed.Content["k"] = "w"
+	if item.Owner != "" || item.Status != "" || item.SharedWith[0] != "a" || len(item.Tags) != 1 ||
+		item.Content["k"] != "v" {
+		t.Errorf("attempt changed the item sent, %+v", item)
+	}
+	if len(attempt.Operations) != 2 || attempt.Operations[1].ID != "2" || !attempt.Atomic {
+		t.Errorf("attempt is %+v, want the batch", attempt)
+	}
+}
@@ -0,0 +1,781 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"todolist/environment"
+	"todolist/ical"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/responses"
+	"todolist/webdav"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//davPrefix is where the CalDAV tree starts. Below it are the
+//users' calendar homes, which hold a calendar per list, which
+//hold the items as .ics resources.
+const davPrefix = "/dav/"
+
+//Items filed under no list are in the default calendar, the
+//others in the calendar of their list.
+const (
+	defaultCollection    = "default"
+	listCollectionPrefix = "list-"
+	syncTokenPrefix      = "urn:todolist:sync:"
+	calendarContentType  = ical.MediaType + "; charset=utf-8; component=VTODO"
+	davMethods           = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+)
+
+//How deep a path goes into the tree.
+const (
+	davRoot = iota
+	davHome
+	davCollection
+	davItem
+)
+
+//The reports calendars answer.
+const supportedReports = `<supported-report><report><calendar-query xmlns="` + webdav.NamespaceCalDAV + `"/></report></supported-report>` +
+	`<supported-report><report><calendar-multiget xmlns="` + webdav.NamespaceCalDAV + `"/></report></supported-report>` +
+	`<supported-report><report><sync-collection/></report></supported-report>`
+
+const calendarPrivileges = "<privilege><read/></privilege><privilege><write/></privilege>" +
+	"<privilege><write-content/></privilege><privilege><bind/></privilege><privilege><unbind/></privilege>"
+
+type davPath struct {
+	level  int
+	User   string
+	List   string
+	ItemID string
+}
+
+func parseDAVPath(r *http.Request) (*davPath, bool) {
+	path := &davPath{}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), davPrefix), "/")
+	if rest == "" {
+		return path, true
+	}
+	segments := strings.Split(rest, "/")
+	if len(segments) > davItem {
+		return nil, false
+	}
+	for idx, segment := range segments {
+		value, err := url.PathUnescape(segment)
+		if err != nil || value == "" {
+			return nil, false
+		}
+		switch idx {
+		case 0:
+			path.User = value
+		case 1:
+			list, ok := collectionList(value)
+			if !ok {
+				return nil, false
+			}
+			path.List = list
+		case 2:
+			if !strings.HasSuffix(value, ".ics") || value == ".ics" {
+				return nil, false
+			}
+			path.ItemID = strings.TrimSuffix(value, ".ics")
+		}
+	}
+	path.level = len(segments)
+	return path, true
+}
+
+func collectionList(segment string) (string, bool) {
+	if segment == defaultCollection {
+		return "", true
+	}
+	if strings.HasPrefix(segment, listCollectionPrefix) {
+		return strings.TrimPrefix(segment, listCollectionPrefix), true
+	}
+	return "", false
+}
+
+func homeHref(userID string) string {
+	return davPrefix + url.PathEscape(userID) + "/"
+}
+
+func collectionHref(userID, list string) string {
+	segment := defaultCollection
+	if list != "" {
+		segment = listCollectionPrefix + list
+	}
+	return homeHref(userID) + url.PathEscape(segment) + "/"
+}
+
+func itemHref(userID string, item *model.TodoItem) string {
+	return collectionHref(userID, item.List) + url.PathEscape(item.ID) + ".ics"
+}
+
+//itemETag changes whenever anything clients can see of the
+//item does.
+func itemETag(item *model.TodoItem) string {
+	data, err := json.Marshal(item)
+	if err != nil {
+		logging.Errorf("Couldn't marshal item %s for its etag: %v", item.ID, err)
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+//etagListHas tells whether etag is in the value of If-Match or
+//If-None-Match.
+func etagListHas(list, etag string) bool {
+	for _, value := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(value), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+//preconditionFailed checks If-Match and If-None-Match against
+//stored, which is nil if there's no item yet.
+func preconditionFailed(r *http.Request, stored *model.TodoItem) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if stored == nil || (match != "*" && !etagListHas(match, itemETag(stored))) {
+			return true
+		}
+	}
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" && stored != nil {
+		if noneMatch == "*" || etagListHas(noneMatch, itemETag(stored)) {
+			return true
+		}
+	}
+	return false
+}
+
+//itemCalendar is the resource of item, a calendar holding it.
+func itemCalendar(item *model.TodoItem) *ical.Component {
+	calendar := newCalendar()
+	calendar.Components = append(calendar.Components, itemVTODO(item, time.Now()))
+	return calendar
+}
+
+//collectionState is the state a list is in, the sync token of
+//which changes whenever any of its items does.
+func collectionState(userID, list string, items []model.TodoItem) *model.SyncState {
+	entries := make([]model.SyncEntry, len(items))
+	for idx := range items {
+		entries[idx] = model.SyncEntry{ID: items[idx].ID, ETag: itemETag(&items[idx])}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	sum := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintf(sum, "%s %s\n", entry.ID, entry.ETag)
+	}
+	return &model.SyncState{
+		Owner:   userID,
+		List:    list,
+		Token:   syncTokenPrefix + hex.EncodeToString(sum.Sum(nil)[:16]),
+		Entries: entries,
+		Time:    time.Now(),
+	}
+}
+
+//ownerCollections gives the user's items by list, the default
+//list is there even when it's empty.
+func ownerCollections(ctx context.Context, connection *mongo.Client, userID string) (map[string][]model.TodoItem, []string, error) {
+	items, err := model.GetOwnerItems(ctx, connection, userID, &model.ItemQuery{})
+	if err != nil {
+		return nil, nil, err
+	}
+	collections := map[string][]model.TodoItem{"": nil}
+	for _, item := range items {
+		collections[item.List] = append(collections[item.List], item)
+	}
+	lists := make([]string, 0, len(collections))
+	for list := range collections {
+		lists = append(lists, list)
+	}
+	sort.Strings(lists)
+	return collections, lists, nil
+}
+
+func rootProperties(userID string) []webdav.Property {
+	return []webdav.Property{
+		webdav.Prop(webdav.NamespaceDAV, "resourcetype", "<collection/>"),
+		webdav.HrefProp(webdav.NamespaceDAV, "current-user-principal", homeHref(userID)),
+	}
+}
+
+//homeProperties are those of the user's principal, which is
+//the calendar home as well.
+func homeProperties(userID string) []webdav.Property {
+	href := homeHref(userID)
+	return []webdav.Property{
+		webdav.Prop(webdav.NamespaceDAV, "resourcetype", "<collection/><principal/>"),
+		webdav.TextProp(webdav.NamespaceDAV, "displayname", userID),
+		webdav.HrefProp(webdav.NamespaceDAV, "current-user-principal", href),
+		webdav.HrefProp(webdav.NamespaceDAV, "principal-URL", href),
+		webdav.HrefProp(webdav.NamespaceCalDAV, "calendar-home-set", href),
+	}
+}
+
+func collectionProperties(state *model.SyncState) []webdav.Property {
+	displayName := state.List
+	if displayName == "" {
+		displayName = environment.GetAppName()
+	}
+	return []webdav.Property{
+		webdav.Prop(webdav.NamespaceDAV, "resourcetype", `<collection/><calendar xmlns="`+webdav.NamespaceCalDAV+`"/>`),
+		webdav.TextProp(webdav.NamespaceDAV, "displayname", displayName),
+		webdav.HrefProp(webdav.NamespaceDAV, "current-user-principal", homeHref(state.Owner)),
+		webdav.Prop(webdav.NamespaceDAV, "current-user-privilege-set", calendarPrivileges),
+		webdav.Prop(webdav.NamespaceDAV, "supported-report-set", supportedReports),
+		webdav.TextProp(webdav.NamespaceDAV, "sync-token", state.Token),
+		webdav.TextProp(webdav.NamespaceCalendarServer, "getctag", state.Token),
+		webdav.Prop(webdav.NamespaceCalDAV, "supported-calendar-component-set", `<comp name="VTODO"/>`),
+	}
+}
+
+//itemProperties leave calendar-data out unless it's asked
+//for, it's most of the item.
+func itemProperties(item *model.TodoItem, request *webdav.PropRequest) []webdav.Property {
+	properties := []webdav.Property{
+		webdav.Prop(webdav.NamespaceDAV, "resourcetype", ""),
+		webdav.TextProp(webdav.NamespaceDAV, "getetag", itemETag(item)),
+		webdav.TextProp(webdav.NamespaceDAV, "getcontenttype", calendarContentType),
+	}
+	if request.Asks(webdav.NamespaceCalDAV, "calendar-data") {
+		var data strings.Builder
+		itemCalendar(item).Encode(&data)
+		properties = append(properties, webdav.TextProp(webdav.NamespaceCalDAV, "calendar-data", data.String()))
+	}
+	return properties
+}
+
+func itemResponse(userID string, item *model.TodoItem, request *webdav.PropRequest) webdav.Response {
+	return webdav.Response{Href: itemHref(userID, item), Propstats: request.Propstats(itemProperties(item, request))}
+}
+
+//collectionResponse stores the state of the collection, so
+//that the sync token it carries can be used.
+func collectionResponse(ctx context.Context, connection *mongo.Client, state *model.SyncState, request *webdav.PropRequest) webdav.Response {
+	model.SaveSyncState(ctx, connection, state)
+	return webdav.Response{
+		Href:      collectionHref(state.Owner, state.List),
+		Propstats: request.Propstats(collectionProperties(state)),
+	}
+}
+
+//collectionItem is the item the path leads to, nil if it's not
+//in the list of the path.
+func collectionItem(ctx context.Context, connection *mongo.Client, userID string, path *davPath) *model.TodoItem {
+	item, err := model.GetOneTodoItemForOwner(ctx, connection, userID, path.ItemID)
+	if err != nil || item.List != path.List {
+		return nil
+	}
+	return item
+}
+
+//readDAVBody reads the XML body, writing the response if it's
+//not XML.
+func readDAVBody(w *http.ResponseWriter, r *http.Request) (*webdav.Element, bool) {
+	body, err := webdav.Parse(r.Body)
+	if err != nil {
+		writeFieldErrors(w, msgRequestBodyNotXML, responses.FieldError{Field: "body", Reason: err.Error()})
+		return nil, false
+	}
+	return body, true
+}
+
+func davPropfind(w http.ResponseWriter, r *http.Request, path *davPath) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	body, ok := readDAVBody(&w, r)
+	if !ok {
+		return
+	}
+	request := webdav.ParsePropRequest(body)
+	//Infinity is taken as 1, the tree isn't deep.
+	children := r.Header.Get("Depth") != "0"
+	ms := &webdav.Multistatus{}
+	switch path.level {
+	case davRoot:
+		ms.Responses = append(ms.Responses, webdav.Response{Href: davPrefix, Propstats: request.Propstats(rootProperties(userID))})
+		if children {
+			ms.Responses = append(ms.Responses, webdav.Response{Href: homeHref(userID), Propstats: request.Propstats(homeProperties(userID))})
+		}
+	case davHome:
+		ms.Responses = append(ms.Responses, webdav.Response{Href: homeHref(userID), Propstats: request.Propstats(homeProperties(userID))})
+		if children {
+			collections, lists, err := ownerCollections(r.Context(), connection, userID)
+			if err != nil {
+				GenericInternalServerError(&w, msgUnableToProcess)
+				return
+			}
+			for _, list := range lists {
+				ms.Responses = append(ms.Responses, collectionResponse(r.Context(), connection, collectionState(userID, list, collections[list]), request))
+			}
+		}
+	case davCollection:
+		collections, _, err := ownerCollections(r.Context(), connection, userID)
+		if err != nil {
+			GenericInternalServerError(&w, msgUnableToProcess)
+			return
+		}
+		items := collections[path.List]
+		ms.Responses = append(ms.Responses, collectionResponse(r.Context(), connection, collectionState(userID, path.List, items), request))
+		if children {
+			for idx := range items {
+				ms.Responses = append(ms.Responses, itemResponse(userID, &items[idx], request))
+			}
+		}
+	case davItem:
+		item := collectionItem(r.Context(), connection, userID, path)
+		if item == nil {
+			GenericResponseWithEC(&w, msgItemNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+			return
+		}
+		ms.Responses = append(ms.Responses, itemResponse(userID, item, request))
+	}
+	if err := ms.Write(w); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't send PROPFIND of %s: %v", r.URL.Path, err)
+	}
+}
+
+//timeRange reads the start and end of a CALDAV:time-range, the
+//zero time for those left out.
+func timeRange(element *webdav.Element) (time.Time, time.Time) {
+	var bounds [2]time.Time
+	for idx, name := range []string{"start", "end"} {
+		if value := element.Attr(name); value != "" {
+			property := ical.Property{Value: value}
+			bounds[idx], _, _ = property.ParseTime()
+		}
+	}
+	return bounds[0], bounds[1]
+}
+
+func propertyTime(component *ical.Component, name string) (time.Time, bool) {
+	property := component.Get(name)
+	if property == nil {
+		return time.Time{}, false
+	}
+	t, _, err := property.ParseTime()
+	return t, err == nil
+}
+
+//todoInRange is the time range test of VTODOs, RFC 4791
+//section 9.9.
+func todoInRange(todo *ical.Component, element *webdav.Element) bool {
+	start, end := timeRange(element)
+	startsBefore := func(t time.Time, strict bool) bool {
+		return start.IsZero() || start.Before(t) || (!strict && start.Equal(t))
+	}
+	endsAfter := func(t time.Time, strict bool) bool {
+		return end.IsZero() || end.After(t) || (!strict && end.Equal(t))
+	}
+	dtstart, hasStart := propertyTime(todo, "DTSTART")
+	due, hasDue := propertyTime(todo, "DUE")
+	completed, hasCompleted := propertyTime(todo, "COMPLETED")
+	switch {
+	case hasStart && hasDue:
+		return (startsBefore(due, true) || startsBefore(dtstart, false)) &&
+			(endsAfter(dtstart, true) || endsAfter(due, false))
+	case hasStart:
+		return startsBefore(dtstart, false) && endsAfter(dtstart, true)
+	case hasDue:
+		return startsBefore(due, true) && endsAfter(due, false)
+	case hasCompleted:
+		return startsBefore(completed, false) && endsAfter(completed, false)
+	}
+	return true
+}
+
+//matchesPropFilter is the CALDAV:prop-filter test, parameters
+//aren't looked at.
+func matchesPropFilter(component *ical.Component, filter *webdav.Element) bool {
+	var properties []*ical.Property
+	for idx := range component.Properties {
+		if strings.EqualFold(component.Properties[idx].Name, filter.Attr("name")) {
+			properties = append(properties, &component.Properties[idx])
+		}
+	}
+	if filter.Child(webdav.NamespaceCalDAV, "is-not-defined") != nil {
+		return len(properties) == 0
+	}
+	if len(properties) == 0 {
+		return false
+	}
+	if textMatch := filter.Child(webdav.NamespaceCalDAV, "text-match"); textMatch != nil {
+		negate := textMatch.Attr("negate-condition") == "yes"
+		text := strings.ToLower(textMatch.Text)
+		for _, property := range properties {
+			if strings.Contains(strings.ToLower(ical.UnescapeText(property.Value)), text) != negate {
+				return true
+			}
+		}
+		return false
+	}
+	if rangeFilter := filter.Child(webdav.NamespaceCalDAV, "time-range"); rangeFilter != nil {
+		start, end := timeRange(rangeFilter)
+		for _, property := range properties {
+			t, _, err := property.ParseTime()
+			if err == nil && (start.IsZero() || !t.Before(start)) && (end.IsZero() || t.Before(end)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+//matchesCompFilter tests component, which has the name of the
+//CALDAV:comp-filter, against what the filter holds.
+func matchesCompFilter(component *ical.Component, filter *webdav.Element) bool {
+	for idx := range filter.Children {
+		child := &filter.Children[idx]
+		switch {
+		case child.Is(webdav.NamespaceCalDAV, "time-range"):
+			if component.Name == "VTODO" && !todoInRange(component, child) {
+				return false
+			}
+		case child.Is(webdav.NamespaceCalDAV, "prop-filter"):
+			if !matchesPropFilter(component, child) {
+				return false
+			}
+		case child.Is(webdav.NamespaceCalDAV, "comp-filter"):
+			undefined := child.Child(webdav.NamespaceCalDAV, "is-not-defined") != nil
+			matched := false
+			for _, sub := range component.Components {
+				if strings.EqualFold(sub.Name, child.Attr("name")) && (undefined || matchesCompFilter(sub, child)) {
+					matched = true
+					break
+				}
+			}
+			if matched == undefined {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//itemFromHref is the id of the item a multiget href of the
+//collection names, "" if it's not one.
+func itemFromHref(href, collection string) string {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimPrefix(parsed.EscapedPath(), collection)
+	if name == parsed.EscapedPath() || !strings.HasSuffix(name, ".ics") {
+		return ""
+	}
+	id, err := url.PathUnescape(strings.TrimSuffix(name, ".ics"))
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+//davReport answers calendar-query, calendar-multiget and
+//sync-collection on calendars.
+func davReport(w http.ResponseWriter, r *http.Request, path *davPath) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	if path.level != davCollection {
+		webdav.WriteError(w, http.StatusForbidden, webdav.NamespaceDAV, "supported-report")
+		return
+	}
+	body, ok := readDAVBody(&w, r)
+	if !ok {
+		return
+	}
+	if body == nil {
+		writeFieldErrors(&w, msgRequestBodyNotXML, responses.FieldError{Field: "body", Reason: "REPORT needs a body"})
+		return
+	}
+	collections, _, err := ownerCollections(r.Context(), connection, userID)
+	if err != nil {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	items := collections[path.List]
+	request := webdav.ParsePropRequest(body)
+	ms := &webdav.Multistatus{}
+	switch {
+	case body.Is(webdav.NamespaceCalDAV, "calendar-query"):
+		var root *webdav.Element
+		if filter := body.Child(webdav.NamespaceCalDAV, "filter"); filter != nil {
+			root = filter.Child(webdav.NamespaceCalDAV, "comp-filter")
+		}
+		for idx := range items {
+			if root != nil && (root.Attr("name") != "VCALENDAR" || !matchesCompFilter(itemCalendar(&items[idx]), root)) {
+				continue
+			}
+			ms.Responses = append(ms.Responses, itemResponse(userID, &items[idx], request))
+		}
+	case body.Is(webdav.NamespaceCalDAV, "calendar-multiget"):
+		byID := map[string]*model.TodoItem{}
+		for idx := range items {
+			byID[items[idx].ID] = &items[idx]
+		}
+		collection := collectionHref(userID, path.List)
+		for _, child := range body.Children {
+			if !child.Is(webdav.NamespaceDAV, "href") {
+				continue
+			}
+			href := strings.TrimSpace(child.Text)
+			if item, ok := byID[itemFromHref(href, collection)]; ok {
+				ms.Responses = append(ms.Responses, itemResponse(userID, item, request))
+				continue
+			}
+			ms.Responses = append(ms.Responses, webdav.Response{Href: href, Status: webdav.Status(http.StatusNotFound)})
+		}
+	case body.Is(webdav.NamespaceDAV, "sync-collection"):
+		var token string
+		if element := body.Child(webdav.NamespaceDAV, "sync-token"); element != nil {
+			token = strings.TrimSpace(element.Text)
+		}
+		current := collectionState(userID, path.List, items)
+		before := map[string]string{}
+		if token != "" {
+			state := model.GetSyncState(r.Context(), connection, userID, path.List, token)
+			if state == nil {
+				webdav.WriteError(w, http.StatusForbidden, webdav.NamespaceDAV, "valid-sync-token")
+				return
+			}
+			for _, entry := range state.Entries {
+				before[entry.ID] = entry.ETag
+			}
+		}
+		for idx := range items {
+			etag, ok := before[items[idx].ID]
+			delete(before, items[idx].ID)
+			if ok && etag == itemETag(&items[idx]) {
+				continue
+			}
+			ms.Responses = append(ms.Responses, itemResponse(userID, &items[idx], request))
+		}
+		//What's left is gone.
+		collection := collectionHref(userID, path.List)
+		for id := range before {
+			ms.Responses = append(ms.Responses, webdav.Response{
+				Href:   collection + url.PathEscape(id) + ".ics",
+				Status: webdav.Status(http.StatusNotFound),
+			})
+		}
+		model.SaveSyncState(r.Context(), connection, current)
+		ms.SyncToken = current.Token
+	default:
+		webdav.WriteError(w, http.StatusForbidden, webdav.NamespaceDAV, "supported-report")
+		return
+	}
+	if err = ms.Write(w); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't send REPORT of %s: %v", r.URL.Path, err)
+	}
+}
+
+func davGet(w http.ResponseWriter, r *http.Request, path *davPath) {
+	item := collectionItem(r.Context(), RequestDatabase(r), RequestUserID(r), path)
+	if item == nil {
+		GenericResponseWithEC(&w, msgItemNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	w.Header().Set("Content-Type", calendarContentType)
+	w.Header().Set("ETag", itemETag(item))
+	if err := itemCalendar(item).Encode(w); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't send item %s: %v", item.ID, err)
+	}
+}
+
+//mergeVTODO lays what clients can change of an item, as todo
+//has it, over stored. The status is only taken when it's not the
+//one stored is shown with, so that states iCalendar has no name
+//for survive an edit.
+func mergeVTODO(stored, imported *model.TodoItem, todo *ical.Component) *model.TodoItem {
+	merged := *stored
+	merged.Name = imported.Name
+	merged.StartTime = imported.StartTime
+	merged.EndTime = imported.EndTime
+	merged.Priority = imported.Priority
+	merged.Recurrence = imported.Recurrence
+	merged.Tags = imported.Tags
+	status := strings.ToUpper(todo.Text("STATUS"))
+	if status == "" {
+		status = "NEEDS-ACTION"
+	}
+	if status != icalStatus(stored.Status) {
+		merged.Status = imported.Status
+		if merged.Status == "" {
+			merged.Status = model.GetWorkflow().Initial
+		}
+	}
+	description := todo.Text("DESCRIPTION")
+	if description != itemDescription(stored) {
+		merged.Content = map[string]interface{}{}
+		for key, value := range stored.Content {
+			merged.Content[key] = value
+		}
+		if description == "" {
+			delete(merged.Content, "description")
+		} else {
+			merged.Content["description"] = description
+		}
+	}
+	return &merged
+}
+
+func davPut(w http.ResponseWriter, r *http.Request, path *davPath) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	calendar, err := ical.Parse(r.Body)
+	if parseErr, ok := err.(*ical.ParseError); ok {
+		writeFieldErrors(&w, msgCalendarCantBeParsed,
+			responses.FieldError{Field: fmt.Sprintf("line %d", parseErr.Line), Reason: parseErr.Reason})
+		return
+	}
+	if err != nil {
+		writeBodyError(&w, err)
+		return
+	}
+	var todo *ical.Component
+	if calendar.Name == "VCALENDAR" {
+		for _, component := range calendar.Components {
+			if component.Name == "VTODO" {
+				todo = component
+				break
+			}
+		}
+	}
+	if todo == nil {
+		webdav.WriteError(w, http.StatusForbidden, webdav.NamespaceCalDAV, "supported-calendar-component")
+		return
+	}
+	if todo.Text("UID") != path.ItemID {
+		writeFieldErrors(&w, msgUIDMismatch,
+			responses.FieldError{Field: "UID", Reason: "must be " + path.ItemID})
+		return
+	}
+	imported, fieldErr := componentItem(todo)
+	if fieldErr != nil {
+		writeFieldErrors(&w, msgComponentNotImportable, *fieldErr)
+		return
+	}
+	stored, err := model.GetOneTodoItemForOwner(r.Context(), connection, userID, path.ItemID)
+	if err != nil {
+		stored = nil
+	}
+	if preconditionFailed(r, stored) {
+		GenericResponseWithEC(&w, msgItemHasChanged, http.StatusPreconditionFailed, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	item := imported
+	if stored != nil {
+		item = mergeVTODO(stored, imported, todo)
+	}
+	item.Owner = userID
+	item.List = path.List
+	if !saveItem(&w, r.Context(), connection, item, stored) {
+		return
+	}
+	if saved, err := model.GetOneTodoItemForOwner(r.Context(), connection, userID, item.ID); err == nil {
+		w.Header().Set("ETag", itemETag(saved))
+	}
+	if stored == nil {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func davDelete(w http.ResponseWriter, r *http.Request, path *davPath) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	item := collectionItem(r.Context(), connection, userID, path)
+	if item == nil {
+		GenericResponseWithEC(&w, msgItemNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	if preconditionFailed(r, item) {
+		GenericResponseWithEC(&w, msgItemHasChanged, http.StatusPreconditionFailed, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	if !removeItem(&w, r.Context(), connection, userID, item.ID) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//AuthenticateDAV lets CalDAV clients in with Basic
+//authentication, the user id and password, as well as with
+//bearer tokens. Clients which sent neither are asked for Basic.
+func AuthenticateDAV(next http.Handler) http.Handler {
+	authenticate := Authenticate(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			authenticate.ServeHTTP(w, r)
+			return
+		}
+		var user *model.User
+		if userID, password, ok := r.BasicAuth(); ok {
+			user = model.GetUser(r.Context(), RequestDatabase(r), userID, password)
+		}
+		if user == nil {
+			logging.FromContext(r.Context()).Infof("CalDAV request to %s without valid credentials", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, environment.GetAppName()))
+			GenericResponseWithEC(&w, msgAuthenticationRequired, http.StatusUnauthorized, API_ERROR_CODE_INVALID_INPUT)
+			return
+		}
+		logging.SetField(r.Context(), "user_id", user.ID)
+		ctx := context.WithValue(r.Context(), principalKey{}, &Principal{UserID: user.ID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+//CalDAV serves the user's lists as calendars of VTODOs to
+//CalDAV clients, RFC 4791. Items are read and written through
+//the same model as the rest of the API, so changes made here
+//reach the apps as events.
+func CalDAV(w http.ResponseWriter, r *http.Request) {
+	path, ok := parseDAVPath(r)
+	if !ok {
+		GenericResponseWithEC(&w, msgResourceNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	if path.level > davRoot && path.User != RequestUserID(r) {
+		GenericResponseWithEC(&w, msgOtherUsersCalendars, http.StatusForbidden, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	switch {
+	case method == http.MethodOptions:
+		w.Header().Set("Allow", davMethods)
+		w.WriteHeader(http.StatusOK)
+	case method == "PROPFIND":
+		davPropfind(w, r, path)
+	case method == "REPORT":
+		davReport(w, r, path)
+	case method == http.MethodGet && path.level == davItem:
+		davGet(w, r, path)
+	case method == http.MethodPut && path.level == davItem:
+		davPut(w, r, path)
+	case method == http.MethodDelete && path.level == davItem:
+		davDelete(w, r, path)
+	default:
+		w.Header().Set("Allow", davMethods)
+		GenericResponseWithEC(&w, msgMethodNotSupported, http.StatusMethodNotAllowed, API_ERROR_CODE_GENERIC_ERROR)
+	}
+}
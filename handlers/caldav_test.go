@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"todolist/model"
+)
+
+func TestPreconditionFailed(t *testing.T) {
+	stored := &model.TodoItem{ID: "1", Name: "Call"}
+	etag := itemETag(stored)
+	tests := []struct {
+		name      string
+		ifMatch   string
+		noneMatch string
+		stored    *model.TodoItem
+		want      bool
+	}{
+		{"no conditions", "", "", stored, false},
+		{"no conditions, no item", "", "", nil, false},
+		{"matches", etag, "", stored, false},
+		{"matches one of a list", `"other", ` + etag, "", stored, false},
+		{"matches weakly", "W/" + etag, "", stored, false},
+		{"changed since", `"other"`, "", stored, true},
+		{"any, item exists", "*", "", stored, false},
+		{"any, no item", "*", "", nil, true},
+		{"match, no item", etag, "", nil, true},
+		{"create only, item exists", "", "*", stored, true},
+		{"create only, no item", "", "*", nil, false},
+		{"not this version", "", etag, stored, true},
+		{"not another version", "", `"other"`, stored, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/dav/", nil)
+			if test.ifMatch != "" {
+				r.Header.Set("If-Match", test.ifMatch)
+			}
+			if test.noneMatch != "" {
+				r.Header.Set("If-None-Match", test.noneMatch)
+			}
+			if got := preconditionFailed(r, test.stored); got != test.want {
+				t.Errorf("failed %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestItemETag(t *testing.T) {
+	item := &model.TodoItem{ID: "1", Name: "Call"}
+	etag := itemETag(item)
+	if again := itemETag(&model.TodoItem{ID: "1", Name: "Call"}); again != etag {
+		t.Errorf("etag %s of the same item, want %s", again, etag)
+	}
+	item.Status = "done"
+	if changed := itemETag(item); changed == etag {
+		t.Errorf("etag %s didn't change with the item", changed)
+	}
+}
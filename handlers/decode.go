@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"todolist/environment"
+	"todolist/i18n"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/responses"
+)
+
+//bodyError is a request body the client got wrong, it carries
+//the response to send back.
+type bodyError struct {
+	status  int
+	apiCode int64
+	message string
+	args    []interface{}
+	errors  []responses.FieldError
+}
+
+func (e *bodyError) Error() string {
+	return i18n.Translate(i18n.DefaultLocale, e.message, e.args...)
+}
+
+func newFieldError(apiCode int64, message, field, reason string) *bodyError {
+	return &bodyError{
+		status:  http.StatusBadRequest,
+		apiCode: apiCode,
+		message: message,
+		errors:  []responses.FieldError{{Field: field, Reason: reason}},
+	}
+}
+
+type bodyLimitKey struct{}
+
+var bodyLimitOnce sync.Once
+var bodyLimit int64
+
+//configuredMaxBodyBytes is environment.MaxBodyBytes, or
+//defaultMaxBodyBytes if it isn't set or isn't a size.
+func configuredMaxBodyBytes() int64 {
+	bodyLimitOnce.Do(func() {
+		bodyLimit = defaultMaxBodyBytes
+		value := environment.GetMaxBodyBytes()
+		if value == "" {
+			return
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			logging.Warnf("Ignoring %s=%s, using %d", environment.MaxBodyBytes, value, bodyLimit)
+			return
+		}
+		bodyLimit = limit
+	})
+	return bodyLimit
+}
+
+//requestBodyLimit is the limit Require set for the route, or
+//the configured one.
+func requestBodyLimit(r *http.Request) int64 {
+	if limit, ok := r.Context().Value(bodyLimitKey{}).(int64); ok {
+		return limit
+	}
+	return configuredMaxBodyBytes()
+}
+
+//errBodyTooLarge is what reading a body returns once it goes
+//past the limit Require set.
+var errBodyTooLarge = errors.New("request body too large")
+
+//limitedBody is http.MaxBytesReader with an error of its own,
+//so that it can be told apart from others.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func newLimitedBody(body io.ReadCloser, limit int64) io.ReadCloser {
+	return &limitedBody{ReadCloser: body, remaining: limit}
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	if body.err != nil {
+		return 0, body.err
+	}
+	//One byte more than allowed tells that there's more.
+	if int64(len(p)) > body.remaining+1 {
+		p = p[:body.remaining+1]
+	}
+	n, err := body.ReadCloser.Read(p)
+	if int64(n) <= body.remaining {
+		body.remaining -= int64(n)
+		body.err = err
+		return n, err
+	}
+	n = int(body.remaining)
+	body.remaining = 0
+	body.err = errBodyTooLarge
+	return n, body.err
+}
+
+func joinPath(path, member string) string {
+	if path == "" {
+		return member
+	}
+	return path + "." + member
+}
+
+//checkDuplicateKeys walks the first json value of dec, json
+//itself allows duplicates and Unmarshal keeps the last one.
+func checkDuplicateKeys(dec *json.Decoder, path string) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		seen := map[string]bool{}
+		for dec.More() {
+			token, err = dec.Token()
+			if err != nil {
+				return err
+			}
+			key := token.(string)
+			if seen[key] {
+				return newFieldError(API_ERROR_CODE_DUPLICATE_FIELD, msgBodyDuplicateMember,
+					joinPath(path, key), "duplicate")
+			}
+			seen[key] = true
+			if err = checkDuplicateKeys(dec, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for idx := 0; dec.More(); idx++ {
+			if err = checkDuplicateKeys(dec, fmt.Sprintf("%s[%d]", path, idx)); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+//checkRequired reports the fields of the struct v points to
+//which are tagged validate:"required" and were left empty.
+func checkRequired(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	var missing []responses.FieldError
+	for idx := 0; idx < value.NumField(); idx++ {
+		field := value.Type().Field(idx)
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+		for _, rule := range rules {
+			if rule == "required" && value.Field(idx).IsZero() {
+				missing = append(missing, responses.FieldError{Field: jsonFieldName(field), Reason: "required"})
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return &bodyError{
+		status:  http.StatusBadRequest,
+		apiCode: API_ERROR_CODE_MISSING_FIELD,
+		message: msgBodyMissesMembers,
+		errors:  missing,
+	}
+}
+
+//checkItemID refuses items added without an id. The model
+//can't require it, edits take the id from the path instead.
+func checkItemID(item *model.TodoItem) error {
+	if item.ID != "" {
+		return nil
+	}
+	return &bodyError{
+		status:  http.StatusBadRequest,
+		apiCode: API_ERROR_CODE_MISSING_FIELD,
+		message: msgBodyMissesMembers,
+		errors:  []responses.FieldError{{Field: "id", Reason: "required"}},
+	}
+}
+
+//decodeJSON fills v from exactly one json value read from body,
+//which must not be larger than limit. With strict, members v
+//has no field for are refused.
+func decodeJSON(body io.Reader, v interface{}, strict bool, limit int64) error {
+	data, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > limit {
+		return &bodyError{
+			status:  http.StatusRequestEntityTooLarge,
+			apiCode: API_ERROR_CODE_REQUEST_TOO_LARGE,
+			message: msgBodyLargerThan,
+			args:    []interface{}{limit},
+		}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &bodyError{status: http.StatusBadRequest, apiCode: API_ERROR_CODE_MALFORMED_JSON,
+			message: msgBodyEmpty}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err = checkDuplicateKeys(dec, ""); err != nil {
+		return err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return &bodyError{status: http.StatusBadRequest, apiCode: API_ERROR_CODE_MALFORMED_JSON,
+			message: msgBodyTrailingData, args: []interface{}{dec.InputOffset()}}
+	}
+	if strict {
+		var members interface{}
+		if err = json.Unmarshal(data, &members); err != nil {
+			return err
+		}
+		if err = checkUnknownFields(members, reflect.TypeOf(v), ""); err != nil {
+			return err
+		}
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	return checkRequired(v)
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+//jsonFields maps the json names of the fields of t, fields of
+//embedded structs included, to their types.
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) {
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			jsonFields(fieldType, fields)
+			continue
+		}
+		fields[jsonFieldName(field)] = field.Type
+	}
+}
+
+//checkUnknownFields refuses the members of value which t has
+//no field for, matching names the way json does. Types which
+//decode themselves are left to it.
+func checkUnknownFields(value interface{}, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if t.Kind() == reflect.Map {
+			for key, member := range v {
+				if err := checkUnknownFields(member, t.Elem(), joinPath(path, key)); err != nil {
+					return err
+				}
+			}
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		fields := map[string]reflect.Type{}
+		jsonFields(t, fields)
+		for key, member := range v {
+			fieldType, ok := fields[key]
+			for name := range fields {
+				if !ok && strings.EqualFold(name, key) {
+					fieldType, ok = fields[name]
+				}
+			}
+			if !ok {
+				return newFieldError(API_ERROR_CODE_UNKNOWN_FIELD, msgBodyUnknownMembers,
+					joinPath(path, key), "unknown")
+			}
+			if err := checkUnknownFields(member, fieldType, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return nil
+		}
+		for idx, element := range v {
+			if err := checkUnknownFields(element, t.Elem(), fmt.Sprintf("%s[%d]", path, idx)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//decodeJSONBody fills v from the json request body, writing
+//the response if it can't. Clients on the legacy API version
+//may send members v doesn't know about, they're ignored.
+func decodeJSONBody(w *http.ResponseWriter, r *http.Request, v interface{}) bool {
+	strict := APIVersion(r) != LegacyAPIVersion
+	if err := decodeJSON(r.Body, v, strict, requestBodyLimit(r)); err != nil {
+		writeBodyError(w, err)
+		return false
+	}
+	return true
+}
+
+//toBodyError maps what json and the body reader return, it's
+//nil for errors which aren't the client's.
+func toBodyError(err error) *bodyError {
+	switch e := err.(type) {
+	case *bodyError:
+		return e
+	case *json.SyntaxError:
+		return &bodyError{status: http.StatusBadRequest, apiCode: API_ERROR_CODE_MALFORMED_JSON,
+			message: msgBodyMalformed, args: []interface{}{e.Offset, e}}
+	case *json.UnmarshalTypeError:
+		field := e.Field
+		if field == "" {
+			field = "body"
+		}
+		return newFieldError(API_ERROR_CODE_INVALID_FIELD_TYPE, msgBodyWrongType,
+			field, fmt.Sprintf("must be %s, not %s", e.Type, e.Value))
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return &bodyError{status: http.StatusBadRequest, apiCode: API_ERROR_CODE_MALFORMED_JSON,
+			message: msgBodyIncomplete}
+	}
+	if err == errBodyTooLarge {
+		return &bodyError{status: http.StatusRequestEntityTooLarge, apiCode: API_ERROR_CODE_REQUEST_TOO_LARGE,
+			message: msgBodyTooLarge}
+	}
+	return nil
+}
+
+func writeBodyError(w *http.ResponseWriter, err error) {
+	e := toBodyError(err)
+	if e == nil {
+		logging.Errorf("Couldn't read request body: %v", err)
+		GenericInternalServerError(w, msgBodyUnreadable)
+		return
+	}
+	response := responses.Response{
+		Status:      e.status,
+		APICode:     e.apiCode,
+		Message:     e.message,
+		MessageArgs: e.args,
+		Errors:      e.errors,
+	}
+	GenericWriteResponse(w, &response)
+}
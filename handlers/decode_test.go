@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+type decodedInner struct {
+	Value int `json:"value"`
+}
+
+type decodedBody struct {
+	Name   string                  `json:"name" validate:"required"`
+	Inner  decodedInner            `json:"inner"`
+	List   []decodedInner          `json:"list"`
+	Values map[string]decodedInner `json:"values"`
+	Any    interface{}             `json:"any"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		strict  bool
+		apiCode int64
+		field   string
+	}{
+		{"valid", `{"name":"a","inner":{"value":1}}`, true, 0, ""},
+		{"names match case insensitively", `{"NAME":"a"}`, true, 0, ""},
+		{"unknown member, legacy", `{"name":"a","extra":1}`, false, 0, ""},
+		{"unknown member", `{"name":"a","extra":1}`, true, API_ERROR_CODE_UNKNOWN_FIELD, "extra"},
+		{"unknown nested member", `{"name":"a","inner":{"other":1}}`, true, API_ERROR_CODE_UNKNOWN_FIELD, "inner.other"},
+		{"unknown member in array", `{"name":"a","list":[{},{"x":1}]}`, true, API_ERROR_CODE_UNKNOWN_FIELD, "list[1].x"},
+		{"unknown member in map", `{"name":"a","values":{"k":{"x":1}}}`, true, API_ERROR_CODE_UNKNOWN_FIELD, "values.k.x"},
+		{"anything under interface", `{"name":"a","any":{"x":{"y":1}}}`, true, 0, ""},
+		{"duplicate member", `{"name":"a","name":"b"}`, false, API_ERROR_CODE_DUPLICATE_FIELD, "name"},
+		{"duplicate nested member", `{"name":"a","list":[{"value":1,"value":2}]}`, false,
+			API_ERROR_CODE_DUPLICATE_FIELD, "list[0].value"},
+		{"same member in two objects", `{"name":"a","list":[{"value":1},{"value":2}]}`, true, 0, ""},
+		{"missing required", `{"inner":{}}`, false, API_ERROR_CODE_MISSING_FIELD, "name"},
+		{"empty", "  ", false, API_ERROR_CODE_MALFORMED_JSON, ""},
+		{"trailing data", `{"name":"a"} {}`, false, API_ERROR_CODE_MALFORMED_JSON, ""},
+		{"malformed", `{"name":}`, false, API_ERROR_CODE_MALFORMED_JSON, ""},
+		{"incomplete", `{"name":"a"`, false, API_ERROR_CODE_MALFORMED_JSON, ""},
+		{"wrong type", `{"name":"a","inner":{"value":"1"}}`, false, API_ERROR_CODE_INVALID_FIELD_TYPE, "inner.value"},
+		{"too large", `{"name":"` + strings.Repeat("a", 64) + `"}`, false, API_ERROR_CODE_REQUEST_TOO_LARGE, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := decodeJSON(strings.NewReader(test.body), &decodedBody{}, test.strict, 64)
+			if test.apiCode == 0 {
+				if err != nil {
+					t.Fatalf("refused %v", err)
+				}
+				return
+			}
+			e := toBodyError(err)
+			if e == nil {
+				t.Fatalf("error %v, want api code %d", err, test.apiCode)
+			}
+			if e.apiCode != test.apiCode {
+				t.Errorf("api code %d, want %d", e.apiCode, test.apiCode)
+			}
+			if test.field != "" && (len(e.errors) != 1 || e.errors[0].Field != test.field) {
+				t.Errorf("errors %v, want one for %s", e.errors, test.field)
+			}
+		})
+	}
+}
@@ -12,6 +12,16 @@ const (
 	API_ERROR_CODE_INVALID_PUBLIC_CERT
 	API_ERROR_CODE_INVALID_RSA_KEY
 	API_ERROR_CODE_NOT_IMPLEMENTED
+	API_ERROR_CODE_INVALID_TRANSITION
+	API_ERROR_CODE_UNSUPPORTED_MEDIA_TYPE
+	API_ERROR_CODE_REQUEST_TOO_LARGE
+	API_ERROR_CODE_MALFORMED_JSON
+	API_ERROR_CODE_UNKNOWN_FIELD
+	API_ERROR_CODE_DUPLICATE_FIELD
+	API_ERROR_CODE_MISSING_FIELD
+	API_ERROR_CODE_INVALID_FIELD_TYPE
+	API_ERROR_CODE_IDEMPOTENCY_KEY_REUSED
+	API_ERROR_CODE_PATCH_TEST_FAILED
 )
 
 func ApiErrorCodeToString(errorCode int64) string {
@@ -22,6 +32,8 @@ func ApiErrorCodeToString(errorCode int64) string {
 		return "token expired"
 	case API_ERROR_CODE_INVALID_INPUT:
 		return "invalid input in request"
+	case API_ERROR_CODE_INVALID_VERSION:
+		return "api version not supported, upgrade the app"
 	case API_ERROR_CODE_UNKNOWN_AUTH_PROVIDER:
 		return "unknown auth provider"
 	case API_ERROR_CODE_INVALID_PUBLIC_CERT:
@@ -30,6 +42,26 @@ func ApiErrorCodeToString(errorCode int64) string {
 		return "invalid RSA key format"
 	case API_ERROR_CODE_NOT_IMPLEMENTED:
 		return "api is not currently implemented"
+	case API_ERROR_CODE_INVALID_TRANSITION:
+		return "status transition not allowed by workflow"
+	case API_ERROR_CODE_UNSUPPORTED_MEDIA_TYPE:
+		return "unsupported content type or charset"
+	case API_ERROR_CODE_REQUEST_TOO_LARGE:
+		return "request body too large"
+	case API_ERROR_CODE_MALFORMED_JSON:
+		return "request body isn't valid json"
+	case API_ERROR_CODE_UNKNOWN_FIELD:
+		return "request body has an unknown field"
+	case API_ERROR_CODE_DUPLICATE_FIELD:
+		return "request body has a field more than once"
+	case API_ERROR_CODE_MISSING_FIELD:
+		return "request body misses a required field"
+	case API_ERROR_CODE_INVALID_FIELD_TYPE:
+		return "request body has a field of the wrong type"
+	case API_ERROR_CODE_IDEMPOTENCY_KEY_REUSED:
+		return "idempotency key was used for another request"
+	case API_ERROR_CODE_PATCH_TEST_FAILED:
+		return "a test operation of the patch failed"
 	case API_ERROR_CODE_OK:
 		return "api execution was successful"
 	default:
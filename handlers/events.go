@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"todolist/events"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/websocket"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//heartbeatInterval keeps idle streams from being cut by
+//proxies, and finds clients which went away.
+const heartbeatInterval = 25 * time.Second
+
+//publishItemEvent tells the owner of item and the users it's
+//shared with, before or after the change, about it.
+func publishItemEvent(ctx context.Context, connection *mongo.Client, eventType string, item, stored *model.TodoItem) {
+	for _, event := range events.ItemEvents(eventType, item, stored) {
+		events.Publish(ctx, connection, event)
+	}
+}
+
+//sharingChanged is true when item isn't shared with the same
+//users as stored is.
+func sharingChanged(item, stored *model.TodoItem) bool {
+	if len(item.SharedWith) != len(stored.SharedWith) {
+		return true
+	}
+	for idx := range item.SharedWith {
+		if item.SharedWith[idx] != stored.SharedWith[idx] {
+			return true
+		}
+	}
+	return false
+}
+
+//lastEventID is where a client reconnecting left off, browsers
+//send Last-Event-ID, other clients may use the query.
+func lastEventID(r *http.Request) primitive.ObjectID {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := primitive.ObjectIDFromHex(value)
+	return id
+}
+
+func streamSSE(w http.ResponseWriter, r *http.Request, sub *events.Subscription) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-sub.Events:
+			if !ok {
+				//Dropped, the client reconnects with the last id.
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				logging.FromContext(r.Context()).Errorf("Couldn't marshal event %s: %v", event.ID.Hex(), err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID.Hex(), event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+func streamWebSocket(w http.ResponseWriter, r *http.Request, sub *events.Subscription) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't upgrade to websocket for user %s: %v", sub.UserID, err)
+		GenericBadRequest(&w, msgWebSocketUpgradeFailed)
+		return
+	}
+	defer conn.Close()
+	closed := make(chan error, 1)
+	go func() {
+		closed <- conn.ReadLoop()
+	}()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case err = <-closed:
+			if err != nil {
+				logging.FromContext(r.Context()).Errorf("Websocket of user %s failed: %v", sub.UserID, err)
+			}
+			return
+		case <-heartbeat.C:
+			err = conn.Ping()
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			data, marshalErr := json.Marshal(event)
+			if marshalErr != nil {
+				logging.FromContext(r.Context()).Errorf("Couldn't marshal event %s: %v", event.ID.Hex(), marshalErr)
+				continue
+			}
+			err = conn.WriteText(data)
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Couldn't write to websocket of user %s: %v", sub.UserID, err)
+			return
+		}
+	}
+}
+
+//Events streams changes to the items the user owns or has
+//been shared, as server-sent events or, when the request asks
+//for an upgrade, over a WebSocket.
+func Events(w http.ResponseWriter, r *http.Request) {
+	sub := events.Subscribe(r.Context(), RequestUserID(r), lastEventID(r))
+	defer events.Unsubscribe(sub)
+	if websocket.IsUpgrade(r) {
+		streamWebSocket(w, r, sub)
+		return
+	}
+	streamSSE(w, r, sub)
+}
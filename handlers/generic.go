@@ -1,79 +1,42 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"strings"
+	"strconv"
 	"todolist/handlers/token"
+	"todolist/i18n"
+	"todolist/logging"
 	"todolist/responses"
 	"todolist/tptverify"
+	"todolist/tracing"
 	"todolist/utils"
 )
 
-//Map the required headers based on whether they are mandatory or not.
-//Most of the time a header would contain only a single value but we
-//make it an array here to allow for multiple values if any.
-
-type requestHeaderChecker func(requestValues []string, possibleValues []string) bool
-type requestHeadersRequired struct {
-	Name      string
-	Values    []string
-	Required  bool
-	CheckerFn requestHeaderChecker
-}
-
 type RequestClaims struct {
 	Claims interface{}
 	tptverify.Verifier
 }
 
-//Authorization checker is a bit special we need to check
-//if Bearer is present.
-func authorizationCheckerFunc(requestValues []string, possibleValues []string) bool {
-	reqValue := strings.Split(requestValues[0], " ")[0]
-	return reqValue == possibleValues[0]
-}
-
-func contentTypeCheckerFunc(requestValues []string, possibleValues []string) bool {
-	//Check for application/json in Content-Type
-	//whose value is as Content-Type: application/json; charset=UT-8
-	reqValue := strings.Split(requestValues[0], ";")
-	for v := range reqValue {
-		for validValue := range possibleValues {
-			if v == validValue {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-var headersRequired = map[string][]requestHeadersRequired{
-	http.MethodGet: {
-		{Name: "Content-Type", Values: []string{"application/json"}, Required: true, CheckerFn: contentTypeCheckerFunc},
-	},
-	http.MethodPost: {
-		{Name: "Content-Type", Values: []string{"application/json"}, Required: true, CheckerFn: contentTypeCheckerFunc},
-		{Name: "Authorization", Values: []string{"Bearer"}, Required: true, CheckerFn: authorizationCheckerFunc},
-		{Name: "X-Resource-Auth", Values: []string{""}, Required: false},
-	},
-}
-
 func GenericNotImplemented(w http.ResponseWriter, r *http.Request) {
-	GenericResponseWithEC(&w, "not implemented",
+	GenericResponseWithEC(&w, msgNotImplemented,
 		http.StatusNotImplemented, API_ERROR_CODE_GENERIC_ERROR)
 }
 
 func GenericResponse(w *http.ResponseWriter, message string, httpStatusCode int) {
 	apiErrorCode := API_ERROR_CODE_OK
-	if httpStatusCode != http.StatusOK {
+	if !successStatus(httpStatusCode) {
 		apiErrorCode = API_ERROR_CODE_GENERIC_ERROR
 	}
 	GenericResponseWithEC(w, message, httpStatusCode, apiErrorCode)
 }
 
+func successStatus(httpStatusCode int) bool {
+	return httpStatusCode >= http.StatusOK && httpStatusCode < http.StatusMultipleChoices
+}
+
 func GenericResponseWithEC(w *http.ResponseWriter, message string, httpStatusCode int, errorCode int64) {
 	response := responses.Response{
 		Status:             httpStatusCode,
@@ -94,7 +57,7 @@ func GenericBadRequest(w *http.ResponseWriter, message string) {
 
 func GenericWriteResponse(w *http.ResponseWriter, resp *responses.Response) {
 	if resp.APICode == API_ERROR_CODE_OK {
-		if resp.Status != http.StatusOK {
+		if !successStatus(resp.Status) {
 			resp.APICode = API_ERROR_CODE_GENERIC_ERROR
 		} else {
 			resp.APICode = API_ERROR_CODE_OK
@@ -103,13 +66,32 @@ func GenericWriteResponse(w *http.ResponseWriter, resp *responses.Response) {
 	if resp.APICodeDescription == "" {
 		resp.APICodeDescription = ApiErrorCodeToString(resp.APICode)
 	}
-	respBytes, err := json.Marshal(*resp)
+	if resp.RequestID == "" {
+		resp.RequestID = (*w).Header().Get(utils.RequestIDHeader)
+	}
+	if resp.APICode != API_ERROR_CODE_OK {
+		apiErrorsTotal.Inc(strconv.FormatInt(resp.APICode, 10))
+	}
+	nw := findNegotiatedWriter(*w)
+	if nw != nil {
+		localize(w, resp, nw.locale)
+	} else {
+		resp.Message = i18n.Translate(i18n.DefaultLocale, resp.Message, resp.MessageArgs...)
+	}
+	var body interface{} = *resp
+	contentType := "application/json; charset=UTF-8"
+	//Errors go out as problem details to clients asking for them.
+	if nw != nil && nw.problem && !successStatus(resp.Status) {
+		body = newProblem(resp, nw.instance)
+		contentType = responses.ProblemMediaType + "; charset=UTF-8"
+	}
+	respBytes, err := json.Marshal(body)
 	if err != nil {
 		(*w).WriteHeader(http.StatusInternalServerError)
-		log.Printf("error Marshalling response %v", resp)
+		logging.Errorf("Error marshalling response with message %s: %v", resp.Message, err)
 		return
 	}
-	(*w).Header().Set("Content-Type", "application/json; charset=UTF-8")
+	(*w).Header().Set("Content-Type", contentType)
 	(*w).WriteHeader(resp.Status)
 	(*w).Write(respBytes)
 }
@@ -150,68 +132,6 @@ func redirectToHTTPS(w *http.ResponseWriter, r *http.Request) bool {
 	return false
 }
 
-func checkRequestHeaders(w *http.ResponseWriter, r *http.Request) bool {
-	expectedHeaders, ok := headersRequired[r.Method]
-	result := true
-	if !ok {
-		result = false
-		goto out
-	}
-	//For a particular request, go over all expected headers.
-	//and match the values.
-	for _, header := range expectedHeaders {
-		requestHeaderValues, ok := r.Header[header.Name]
-		//Request doesn't contains the header.
-		if !ok {
-			//header not found but is required.
-			if header.Required {
-				result = false
-				goto out
-			}
-			continue
-		}
-		//Support only the first value
-		//check of request header
-		if header.CheckerFn != nil {
-			if header.Required && !header.CheckerFn(requestHeaderValues,
-				header.Values) {
-				result = false
-				log.Printf("Header %s, found = %s, need = %s\n",
-					header.Name, requestHeaderValues[0],
-					header.Values[0])
-				goto out
-			}
-		} else if (requestHeaderValues[0] != header.Values[0]) && header.Required {
-			log.Printf("Header %s, found = %s, need = %s\n",
-				header.Name, requestHeaderValues[0],
-				header.Values[0])
-			result = false
-			goto out
-		}
-	}
-out:
-	if !result {
-		response := responses.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Required Request headers missing.",
-		}
-		GenericWriteResponse(w, &response)
-	}
-	return result
-}
-
-func checkRequestMethod(w *http.ResponseWriter, r *http.Request, method string) bool {
-	if r.Method != method {
-		response := responses.Response{
-			Status:  http.StatusBadRequest,
-			Message: "HTTP Method Not Supported",
-		}
-		GenericWriteResponse(w, &response)
-		return false
-	}
-	return true
-}
-
 func VerifyBearerToken(request *http.Request) (*RequestClaims, responses.Response) {
 	var result = &RequestClaims{}
 	response := responses.Response{
@@ -223,7 +143,7 @@ func VerifyBearerToken(request *http.Request) (*RequestClaims, responses.Respons
 	bearerToken := token.GetBearerToken(request)
 	authProvider, err := utils.GetRequestHeader(request, "X-Resource-Auth")
 	if err != nil {
-		log.Printf("Verifier is not third-party\n")
+		logging.FromContext(request.Context()).Infof("Verifier is not third-party")
 		authProvider = tptverify.VERIFIER_US
 	}
 	provider, err := tptverify.GetVerifier(authProvider)
@@ -231,19 +151,35 @@ func VerifyBearerToken(request *http.Request) (*RequestClaims, responses.Respons
 		response.Status = http.StatusBadRequest
 		response.APICode = API_ERROR_CODE_INVALID_INPUT
 		response.APICodeDescription = ApiErrorCodeToString(response.APICode)
-		log.Printf("Error = %s", err)
+		logging.FromContext(request.Context()).Warnf("Error = %s", err)
 		return result, response
 	}
 	result.Verifier = provider
 
-	claims, err := provider.Verify(bearerToken)
+	claims, err := verifyToken(request.Context(), provider, bearerToken)
 	if err != nil {
 		response.Status = http.StatusBadRequest
 		response.APICode = API_ERROR_CODE_TOKEN_EXPIRED
 		response.APICodeDescription = ApiErrorCodeToString(response.APICode)
-		log.Printf("Error = %s", err)
+		logging.FromContext(request.Context()).Warnf("Error = %s", err)
 	} else {
 		result.Claims = claims
 	}
 	return result, response
 }
+
+//verifyToken has provider verify bearerToken in a span of its
+//own, third party verifiers may have to fetch their keys first.
+func verifyToken(ctx context.Context, provider tptverify.Verifier, bearerToken string) (interface{}, error) {
+	_, span := tracing.Start(ctx, "verify "+provider.Name(), tracing.Internal)
+	defer span.End()
+	span.SetAttribute("auth.verifier", provider.Name())
+	claims, err := provider.Verify(bearerToken)
+	if err != nil {
+		span.SetError(err)
+		verificationsTotal.Inc(provider.Name(), "failure")
+	} else {
+		verificationsTotal.Inc(provider.Name(), "success")
+	}
+	return claims, err
+}
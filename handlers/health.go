@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"todolist/database"
+	"todolist/environment"
+	"todolist/handlers/token"
+	"todolist/logging"
+	"todolist/responses"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	//checkTimeout is how long a readiness check may take before
+	//it's failed.
+	checkTimeout = 5 * time.Second
+	//startupRetry is how often the checks are run again while
+	//the process isn't ready, and what Retry-After says.
+	startupRetry = 5 * time.Second
+	//readyCacheTTL is how long /readyz answers with the checks
+	//it last ran.
+	readyCacheTTL = 3 * time.Second
+)
+
+//readinessCheck is one thing the process needs to serve its
+//routes.
+type readinessCheck struct {
+	name string
+	run  func() error
+}
+
+var readinessChecks = []readinessCheck{
+	{"secrets", checkSecrets},
+	{"mongo", checkMongo},
+	{"google_certs", token.LoadGoogleCerts},
+}
+
+//checkResult is how a check went. /readyz tells the status,
+//latency and reason, the error is only logged as it may hold
+//addresses or credentials.
+type checkResult struct {
+	Status  string
+	Latency time.Duration
+	Reason  string
+	Error   string
+}
+
+//Reasons a check failed for, what /readyz says of the error.
+const (
+	reasonNotConfigured = "not_configured"
+	reasonTimeout       = "timeout"
+	reasonUnavailable   = "unavailable"
+)
+
+var (
+	errNotConfigured = errors.New("not configured")
+	errCheckTimeout  = errors.New("timed out")
+)
+
+//failureReason is what err says of a failed check without
+//telling what's in it.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, errCheckTimeout):
+		return reasonTimeout
+	case errors.Is(err, errNotConfigured), errors.Is(err, token.ErrNoSecret):
+		return reasonNotConfigured
+	}
+	return reasonUnavailable
+}
+
+//started is set once the checks have all passed, routes behind
+//StartupGate are refused until then.
+var started int32
+
+func Started() bool {
+	return atomic.LoadInt32(&started) == 1
+}
+
+func checkSecrets() error {
+	if environment.GetAppTokenSecret() == "" {
+		return token.ErrNoSecret
+	}
+	return nil
+}
+
+//mongoCheck is the connection checkMongo pings, it's made on
+//the first check and kept for the next ones.
+var mongoCheck struct {
+	sync.Mutex
+	connection *mongo.Client
+}
+
+func checkMongo() error {
+	uri := environment.GetMongoConnectionString()
+	if uri == "" {
+		return fmt.Errorf("%s isn't set: %w", environment.MongoDBConnectionString, errNotConfigured)
+	}
+	mongoCheck.Lock()
+	if mongoCheck.connection == nil {
+		connection, err := database.GetMongoConnection(uri)
+		if err != nil {
+			mongoCheck.Unlock()
+			return err
+		}
+		mongoCheck.connection = connection
+	}
+	connection := mongoCheck.connection
+	mongoCheck.Unlock()
+	return database.Ping(connection, checkTimeout)
+}
+
+//runChecks runs the readiness checks side by side, a check
+//which doesn't finish in time fails.
+func runChecks() (map[string]checkResult, bool) {
+	results := map[string]checkResult{}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range readinessChecks {
+		wg.Add(1)
+		go func(check readinessCheck) {
+			defer wg.Done()
+			start := time.Now()
+			done := make(chan error, 1)
+			go func() {
+				done <- check.run()
+			}()
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(checkTimeout):
+				err = errCheckTimeout
+			}
+			result := checkResult{
+				Status:  "ok",
+				Latency: time.Since(start),
+			}
+			if err != nil {
+				result.Status = "failed"
+				result.Reason = failureReason(err)
+				result.Error = err.Error()
+			}
+			lock.Lock()
+			results[check.name] = result
+			lock.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	ready := true
+	for _, result := range results {
+		ready = ready && result.Status == "ok"
+	}
+	return results, ready
+}
+
+//lastChecks are the results /readyz answers with until they're
+//readyCacheTTL old. The lock is held while the checks run, so
+//that requests coming in meanwhile wait for them.
+var lastChecks struct {
+	sync.Mutex
+	results map[string]checkResult
+	ready   bool
+	ran     time.Time
+}
+
+//cachedChecks are the results of the checks run at most
+//readyCacheTTL ago, those which fail are logged when they run.
+func cachedChecks() (map[string]checkResult, bool) {
+	lastChecks.Lock()
+	defer lastChecks.Unlock()
+	if lastChecks.results == nil || time.Since(lastChecks.ran) >= readyCacheTTL {
+		lastChecks.results, lastChecks.ready = runChecks()
+		lastChecks.ran = time.Now()
+		for name, result := range lastChecks.results {
+			if result.Status != "ok" {
+				logging.Warnf("Readiness check %s failed after %v: %s", name, result.Latency, result.Error)
+			}
+		}
+	}
+	return lastChecks.results, lastChecks.ready
+}
+
+//WaitUntilReady runs the readiness checks until they all pass
+//and then lets traffic through StartupGate.
+func WaitUntilReady() {
+	for {
+		results, ready := runChecks()
+		if ready {
+			atomic.StoreInt32(&started, 1)
+			logging.Infof("Ready, serving requests")
+			return
+		}
+		for name, result := range results {
+			if result.Status != "ok" {
+				logging.Warnf("Not ready, %s check failed: %s", name, result.Error)
+			}
+		}
+		time.Sleep(startupRetry)
+	}
+}
+
+//StartupGate answers 503 until WaitUntilReady has seen every
+//dependency ready once.
+func StartupGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Started() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(startupRetry.Seconds())))
+			GenericResponse(&w, msgStartingUp, http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//Healthz answers as long as the process does, for liveness
+//probes.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		Message: msgOK,
+		Meta:    map[string]interface{}{"started": Started()},
+	}
+	GenericWriteResponse(&w, &resp)
+}
+
+//readyCheck is a check as /readyz reports it.
+type readyCheck struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+//Readyz reports whether each readiness check is ok, how long it
+//took and why it failed if it did, it answers 503 while any
+//fails. The checks are run again once their results are
+//readyCacheTTL old.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	results, ready := cachedChecks()
+	checks := map[string]readyCheck{}
+	for name, result := range results {
+		checks[name] = readyCheck{
+			Status:    result.Status,
+			LatencyMS: float64(result.Latency) / float64(time.Millisecond),
+			Reason:    result.Reason,
+		}
+	}
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		Message: msgReady,
+		Meta: map[string]interface{}{
+			"started": Started(),
+			"checks":  checks,
+		},
+	}
+	if !ready {
+		resp.Status = http.StatusServiceUnavailable
+		resp.Message = msgNotReady
+	}
+	GenericWriteResponse(&w, &resp)
+}
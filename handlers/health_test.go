@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"todolist/handlers/token"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"timed out", errCheckTimeout, reasonTimeout},
+		{"not configured", fmt.Errorf("uri isn't set: %w", errNotConfigured), reasonNotConfigured},
+		{"no secret", token.ErrNoSecret, reasonNotConfigured},
+		{"anything else", errors.New("dial tcp mongodb://user:secret@db: refused"), reasonUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := failureReason(test.err); got != test.want {
+				t.Errorf("reason %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+//TestReadyz checks each check is reported with its latency and
+//reason, and not with its error.
+func TestReadyz(t *testing.T) {
+	checks := readinessChecks
+	defer func() {
+		readinessChecks = checks
+		lastChecks.results = nil
+	}()
+	readinessChecks = []readinessCheck{
+		{"up", func() error { return nil }},
+		{"down", func() error { return errors.New("mongodb://user:secret@db refused") }},
+	}
+	lastChecks.results = nil
+	recorder := httptest.NewRecorder()
+	Readyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+	var body struct {
+		Extra struct {
+			Checks map[string]map[string]interface{} `json:"checks"`
+		} `json:"extra"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		check  string
+		status string
+		reason interface{}
+	}{
+		{"up", "ok", nil},
+		{"down", "failed", reasonUnavailable},
+	}
+	for _, test := range tests {
+		got := body.Extra.Checks[test.check]
+		if got["status"] != test.status || got["reason"] != test.reason {
+			t.Errorf("%s is %v, want status %s and reason %v", test.check, got, test.status, test.reason)
+		}
+		if _, ok := got["latency_ms"].(float64); !ok {
+			t.Errorf("%s has no latency, %v", test.check, got)
+		}
+		if _, ok := got["error"]; ok {
+			t.Errorf("%s tells its error, %v", test.check, got)
+		}
+	}
+}
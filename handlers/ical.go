@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/environment"
+	"todolist/ical"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/responses"
+	"todolist/utils"
+)
+
+//feedTokenBytes of randomness make a feed token.
+const feedTokenBytes = 32
+
+//The item priorities as iCalendar has them, 1 is the highest
+//and 9 the lowest, 0 is none.
+var icalPriorities = map[int]int{
+	model.PriorityNone:   0,
+	model.PriorityUrgent: 1,
+	model.PriorityHigh:   3,
+	model.PriorityMedium: 5,
+	model.PriorityLow:    9,
+}
+
+type feedTokenRequest struct {
+	Revoke bool `json:"revoke,omitempty"`
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+//AuthenticateFeed lets calendar apps, which can't send a
+//bearer token, in with the feed token in the query. Requests
+//without one go through Authenticate.
+func AuthenticateFeed(next http.Handler) http.Handler {
+	authenticate := Authenticate(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			authenticate.ServeHTTP(w, r)
+			return
+		}
+		user := model.GetUserForFeedToken(r.Context(), RequestDatabase(r), hashFeedToken(token))
+		if user == nil {
+			logging.FromContext(r.Context()).Infof("Unknown calendar feed token")
+			GenericResponseWithEC(&w, msgUnknownFeedToken, http.StatusUnauthorized, API_ERROR_CODE_INVALID_INPUT)
+			return
+		}
+		logging.SetField(r.Context(), "user_id", user.ID)
+		ctx := context.WithValue(r.Context(), principalKey{}, &Principal{UserID: user.ID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+//icalStatus maps the workflow states to the VTODO statuses,
+//states between the first and the last are in process.
+func icalStatus(status string) string {
+	wf := model.GetWorkflow()
+	switch status {
+	case wf.Done:
+		return "COMPLETED"
+	case "", wf.Initial:
+		return "NEEDS-ACTION"
+	case "cancelled", "canceled":
+		return "CANCELLED"
+	}
+	return "IN-PROCESS"
+}
+
+//itemStatus maps a VTODO or VEVENT status to a workflow state,
+//"" leaves it to the workflow.
+func itemStatus(status string) string {
+	wf := model.GetWorkflow()
+	switch strings.ToUpper(status) {
+	case "COMPLETED":
+		return wf.Done
+	case "IN-PROCESS":
+		for _, state := range wf.Transitions[wf.Initial] {
+			if state != wf.Done {
+				return state
+			}
+		}
+	case "CANCELLED":
+		for _, state := range []string{"cancelled", "canceled"} {
+			if wf.Valid(state) {
+				return state
+			}
+		}
+	}
+	return ""
+}
+
+func itemPriority(value string) int {
+	priority, err := strconv.Atoi(value)
+	switch {
+	case err != nil || priority <= 0:
+		return model.PriorityNone
+	case priority <= 2:
+		return model.PriorityUrgent
+	case priority <= 4:
+		return model.PriorityHigh
+	case priority == 5:
+		return model.PriorityMedium
+	}
+	return model.PriorityLow
+}
+
+//addItemTime adds an item time, RFC 3339 or a plain date, as a
+//DATE-TIME or DATE property. Others are left out.
+func addItemTime(component *ical.Component, name, value string) {
+	if value == "" {
+		return
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		component.Add(name, ical.FormatDateTime(t))
+		return
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		component.Add(name, ical.FormatDate(t), "VALUE", "DATE")
+		return
+	}
+	logging.Debugf("Leaving out %s %s, it's not RFC 3339", name, value)
+}
+
+//itemTime is the value of a DATE or DATE-TIME property the way
+//items keep times.
+func itemTime(property *ical.Property) (string, error) {
+	t, dateOnly, err := property.ParseTime()
+	if err != nil {
+		return "", err
+	}
+	if dateOnly {
+		return t.Format("2006-01-02"), nil
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+//itemDescription is what goes into DESCRIPTION, the
+//description in the content or else all of its text.
+func itemDescription(item *model.TodoItem) string {
+	if description, ok := item.Content["description"].(string); ok {
+		return description
+	}
+	return item.SearchText
+}
+
+func itemVTODO(item *model.TodoItem, stamp time.Time) *ical.Component {
+	todo := ical.NewComponent("VTODO")
+	todo.AddText("UID", item.ID)
+	todo.Add("DTSTAMP", ical.FormatDateTime(stamp))
+	todo.AddText("SUMMARY", item.Name)
+	if description := itemDescription(item); description != "" {
+		todo.AddText("DESCRIPTION", description)
+	}
+	addItemTime(todo, "DTSTART", item.StartTime)
+	addItemTime(todo, "DUE", item.EndTime)
+	todo.Add("STATUS", icalStatus(item.Status))
+	addItemTime(todo, "COMPLETED", item.CompletedAt)
+	if priority := icalPriorities[item.Priority]; priority > 0 {
+		todo.Add("PRIORITY", strconv.Itoa(priority))
+	}
+	if item.Recurrence != "" {
+		todo.Add("RRULE", item.Recurrence)
+	}
+	if len(item.Tags) > 0 {
+		categories := make([]string, len(item.Tags))
+		for idx, tag := range item.Tags {
+			categories[idx] = ical.EscapeText(tag)
+		}
+		todo.Add("CATEGORIES", strings.Join(categories, ","))
+	}
+	return todo
+}
+
+//componentItem turns a VTODO or VEVENT into an item. Those
+//without a UID get one made from their content, so that a file
+//imported again gives the same ids.
+func componentItem(component *ical.Component) (*model.TodoItem, *responses.FieldError) {
+	item := &model.TodoItem{
+		ID:         component.Text("UID"),
+		Name:       component.Text("SUMMARY"),
+		Status:     itemStatus(component.Text("STATUS")),
+		Priority:   itemPriority(component.Text("PRIORITY")),
+		Recurrence: component.Text("RRULE"),
+	}
+	if item.ID == "" {
+		var content strings.Builder
+		component.Encode(&content)
+		item.ID = contentID(content.String())
+	}
+	if description := component.Text("DESCRIPTION"); description != "" {
+		item.Content = map[string]interface{}{"description": description}
+	}
+	end := "DUE"
+	if component.Name == "VEVENT" {
+		end = "DTEND"
+	}
+	for name, value := range map[string]*string{"DTSTART": &item.StartTime, end: &item.EndTime} {
+		property := component.Get(name)
+		if property == nil {
+			continue
+		}
+		var err error
+		if *value, err = itemTime(property); err != nil {
+			return item, &responses.FieldError{Field: name, Reason: "isn't a DATE or DATE-TIME"}
+		}
+	}
+	for _, property := range component.Properties {
+		if property.Name == "CATEGORIES" {
+			item.Tags = append(item.Tags, ical.SplitText(property.Value)...)
+		}
+	}
+	return item, nil
+}
+
+//newCalendar is an empty VCALENDAR of ours.
+func newCalendar() *ical.Component {
+	calendar := ical.NewComponent("VCALENDAR")
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", "-//"+ical.EscapeText(environment.GetAppName())+"//todolist//EN")
+	calendar.Add("CALSCALE", "GREGORIAN")
+	return calendar
+}
+
+//ExportICS sends the user's items as VTODOs of a calendar.
+func ExportICS(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	items, err := model.GetOwnerItems(r.Context(), connection, userID, &model.ItemQuery{})
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't get items of user %s for the calendar: %v", userID, err)
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	calendar := newCalendar()
+	calendar.Add("METHOD", "PUBLISH")
+	calendar.AddText("X-WR-CALNAME", environment.GetAppName())
+	stamp := time.Now()
+	for idx := range items {
+		calendar.Components = append(calendar.Components, itemVTODO(&items[idx], stamp))
+	}
+	w.Header().Set("Content-Type", ical.MediaType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="todolist.ics"`)
+	if err = calendar.Encode(w); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't send calendar to user %s: %v", userID, err)
+	}
+}
+
+//feedURL is where calendar apps subscribe with token.
+func feedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get(utils.HerokuForwardedProto) == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/export/ics?token=%s", scheme, r.Host, url.QueryEscape(token))
+}
+
+//ICSFeedToken makes a new calendar feed token, the one before
+//stops working. With revoke the user is left without one.
+func ICSFeedToken(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	request := feedTokenRequest{}
+	if !decodeJSONBody(&w, r, &request) {
+		return
+	}
+	if request.Revoke {
+		if !model.SetFeedToken(r.Context(), connection, userID, "") {
+			GenericInternalServerError(&w, msgUnableToProcess)
+			return
+		}
+		GenericResponse(&w, msgCalendarFeedRevoked, http.StatusOK)
+		return
+	}
+	random := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(random); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't make a feed token: %v", err)
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	if !model.SetFeedToken(r.Context(), connection, userID, hashFeedToken(token)) {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		APICode: API_ERROR_CODE_OK,
+		Message: msgCalendarFeedCreated,
+		Meta:    map[string]interface{}{"url": feedURL(r, token)},
+	}
+	GenericWriteResponse(&w, &resp)
+}
+
+//ImportICS adds the VTODOs and VEVENTs of a calendar as items,
+//answering with a result for each like /post/batch does. Those
+//whose UID is already an item's id are duplicates, left as they
+//are, and dry_run=1 only checks them.
+func ImportICS(w http.ResponseWriter, r *http.Request) {
+	calendar, err := ical.Parse(r.Body)
+	if parseErr, ok := err.(*ical.ParseError); ok {
+		writeFieldErrors(&w, msgCalendarCantBeParsed,
+			responses.FieldError{Field: fmt.Sprintf("line %d", parseErr.Line), Reason: parseErr.Reason})
+		return
+	}
+	if err != nil {
+		writeBodyError(&w, err)
+		return
+	}
+	if calendar.Name != "VCALENDAR" {
+		writeFieldErrors(&w, msgNotICalendar, responses.FieldError{Field: "body", Reason: "must be a VCALENDAR"})
+		return
+	}
+	var records []importRecord
+	for _, component := range calendar.Components {
+		if component.Name != "VTODO" && component.Name != "VEVENT" {
+			continue
+		}
+		record := importRecord{}
+		var fieldErr *responses.FieldError
+		if record.Item, fieldErr = componentItem(component); fieldErr != nil {
+			record.Err = newFieldError(API_ERROR_CODE_INVALID_INPUT, msgComponentNotImportable,
+				fieldErr.Field, fieldErr.Reason)
+		}
+		records = append(records, record)
+	}
+	runImport(w, r, records)
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+	"todolist/environment"
+	"todolist/logging"
+	"todolist/responses"
+	"todolist/utils"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	defaultIdempotencyTTL   = 24 * time.Hour
+	//How often expired entries are looked for.
+	idempotencySweepInterval = time.Minute
+	//maxIdempotencyEntries bounds the store, the oldest keys
+	//are forgotten first when it's full.
+	maxIdempotencyEntries = 10000
+	//maxIdempotentResponseBytes is the largest response body
+	//kept, a larger one releases the key instead.
+	maxIdempotentResponseBytes = 64 << 10
+)
+
+//storedResponse is the first response to a request with an
+//Idempotency-Key, retries get it again as it was.
+type storedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (stored *storedResponse) writeTo(w http.ResponseWriter) {
+	for name, values := range stored.header {
+		//The retry keeps its own request id.
+		if name == utils.RequestIDHeader {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotencyReplayedHeader, "true")
+	w.WriteHeader(stored.status)
+	w.Write(stored.body)
+}
+
+//idempotentEntry is a key in use, response is nil while the
+//first request with it is still running.
+type idempotentEntry struct {
+	bodyHash [sha256.Size]byte
+	expires  time.Time
+	response *storedResponse
+}
+
+//idempotentClaim is a scope in the order it was claimed in.
+type idempotentClaim struct {
+	scope string
+	entry *idempotentEntry
+}
+
+type idempotencyStore struct {
+	sync.Mutex
+	entries map[string]*idempotentEntry
+	//order holds the claims oldest first, those whose entry has
+	//gone since are skipped.
+	order []idempotentClaim
+	swept time.Time
+}
+
+var idempotency = &idempotencyStore{entries: map[string]*idempotentEntry{}}
+
+var idempotencyTTLOnce sync.Once
+var idempotencyTTL time.Duration
+
+//getIdempotencyTTL is environment.IdempotencyTTL, or
+//defaultIdempotencyTTL if it isn't set or isn't a duration.
+func getIdempotencyTTL() time.Duration {
+	idempotencyTTLOnce.Do(func() {
+		idempotencyTTL = defaultIdempotencyTTL
+		value := environment.GetIdempotencyTTL()
+		if value == "" {
+			return
+		}
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			logging.Warnf("Ignoring %s=%s, using %v", environment.IdempotencyTTL, value, idempotencyTTL)
+			return
+		}
+		idempotencyTTL = ttl
+	})
+	return idempotencyTTL
+}
+
+//sweep drops expired entries, the caller holds the lock.
+func (store *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(store.swept) < idempotencySweepInterval {
+		return
+	}
+	store.swept = now
+	for scope, entry := range store.entries {
+		if entry.response != nil && now.After(entry.expires) {
+			delete(store.entries, scope)
+		}
+	}
+	order := store.order[:0]
+	for _, claim := range store.order {
+		if store.entries[claim.scope] == claim.entry {
+			order = append(order, claim)
+		}
+	}
+	store.order = order
+}
+
+//evict forgets the oldest keys until there's room for one
+//more, the caller holds the lock.
+func (store *idempotencyStore) evict() {
+	for len(store.entries) >= maxIdempotencyEntries && len(store.order) > 0 {
+		claim := store.order[0]
+		store.order = store.order[1:]
+		if store.entries[claim.scope] == claim.entry {
+			delete(store.entries, claim.scope)
+		}
+	}
+}
+
+//begin claims scope for a request with a body hashing to
+//bodyHash. A retry gets the stored response to replay, a
+//request which can't be run a message why not.
+func (store *idempotencyStore) begin(scope string, bodyHash [sha256.Size]byte) (*storedResponse, string) {
+	store.Lock()
+	defer store.Unlock()
+	now := time.Now()
+	store.sweep(now)
+	entry, ok := store.entries[scope]
+	if ok && (entry.response == nil || now.Before(entry.expires)) {
+		switch {
+		case entry.bodyHash != bodyHash:
+			return nil, msgIdempotencyKeyReused
+		case entry.response == nil:
+			return nil, msgIdempotencyKeyInProgress
+		}
+		return entry.response, ""
+	}
+	store.evict()
+	entry = &idempotentEntry{bodyHash: bodyHash}
+	store.entries[scope] = entry
+	store.order = append(store.order, idempotentClaim{scope, entry})
+	return nil, ""
+}
+
+//finish keeps response for the TTL, without one the key is
+//released so the request can be tried again.
+func (store *idempotencyStore) finish(scope string, response *storedResponse) {
+	store.Lock()
+	defer store.Unlock()
+	entry, ok := store.entries[scope]
+	if !ok {
+		return
+	}
+	if response == nil {
+		delete(store.entries, scope)
+		return
+	}
+	entry.response = response
+	entry.expires = time.Now().Add(getIdempotencyTTL())
+}
+
+//idempotentWriter keeps a copy of the response on its way to
+//the client, up to maxIdempotentResponseBytes of it.
+type idempotentWriter struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	tooLarge bool
+}
+
+func (iw *idempotentWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+func (iw *idempotentWriter) WriteHeader(code int) {
+	if iw.status == 0 {
+		iw.status = code
+		iw.header = iw.ResponseWriter.Header().Clone()
+	}
+	iw.ResponseWriter.WriteHeader(code)
+}
+
+func (iw *idempotentWriter) Write(data []byte) (int, error) {
+	if iw.status == 0 {
+		iw.WriteHeader(http.StatusOK)
+	}
+	if !iw.tooLarge {
+		iw.body.Write(data)
+		if iw.body.Len() > maxIdempotentResponseBytes {
+			iw.tooLarge = true
+			iw.body = bytes.Buffer{}
+		}
+	}
+	return iw.ResponseWriter.Write(data)
+}
+
+//response is what retries get, nil when nothing was written
+//or the server failed, those are worth trying again. Nor are
+//responses too large to keep.
+func (iw *idempotentWriter) response() *storedResponse {
+	if iw.status == 0 || iw.status >= http.StatusInternalServerError || iw.tooLarge {
+		return nil
+	}
+	return &storedResponse{status: iw.status, header: iw.header, body: iw.body.Bytes()}
+}
+
+func idempotentMethod(method string) bool {
+	return methodHasBody(method) || method == http.MethodDelete
+}
+
+//Idempotent runs a request with an Idempotency-Key once per
+//user, method and path. Retries with the same body get the
+//first response again, with another body a 409. Server errors
+//aren't kept. Requests without a user are run as they come
+//unless anonymous is set, as it is for /register. Their key
+//is then scoped to the body as well, so one client can't get
+//the response another got.
+//Keys are kept in memory, by the instance which served the
+//request. Behind several instances a retry which lands on
+//another one, or comes after a restart, is run again.
+func Idempotent(anonymous bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !idempotentMethod(r.Method) || (!anonymous && RequestPrincipal(r) == nil) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeFieldErrors(&w, msgIdempotencyKeyTooLong, responses.FieldError{Field: IdempotencyKeyHeader,
+					Reason: fmt.Sprintf("at most %d characters", maxIdempotencyKeyLength)})
+				return
+			}
+			//Routes without Requirements have no limit of their own.
+			body, err := ioutil.ReadAll(newLimitedBody(r.Body, requestBodyLimit(r)))
+			if err != nil {
+				writeBodyError(&w, err)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			bodyHash := sha256.Sum256(body)
+			scope := fmt.Sprintf("%s\x00%s %s\x00%s", RequestUserID(r), r.Method, r.URL.Path, key)
+			if RequestPrincipal(r) == nil {
+				//Without a user, only a retry of the very same
+				//request gets its response.
+				scope += fmt.Sprintf("\x00%x", bodyHash)
+			}
+			stored, conflict := idempotency.begin(scope, bodyHash)
+			if conflict != "" {
+				GenericResponseWithEC(&w, conflict, http.StatusConflict, API_ERROR_CODE_IDEMPOTENCY_KEY_REUSED)
+				return
+			}
+			if stored != nil {
+				stored.writeTo(w)
+				return
+			}
+			iw := &idempotentWriter{ResponseWriter: w}
+			//Also runs when the handler panics, the key is released.
+			defer func() {
+				idempotency.finish(scope, iw.response())
+			}()
+			next.ServeHTTP(iw, r)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{entries: map[string]*idempotentEntry{}}
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	body := sha256.Sum256([]byte("body"))
+	other := sha256.Sum256([]byte("other"))
+	done := &storedResponse{status: http.StatusCreated}
+	//step is a call of begin, after finish with response if
+	//finish is set.
+	type step struct {
+		finish   bool
+		response *storedResponse
+		bodyHash [sha256.Size]byte
+		replay   bool
+		conflict string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"first request runs", []step{
+			{bodyHash: body},
+		}},
+		{"retry while running", []step{
+			{bodyHash: body},
+			{bodyHash: body, conflict: msgIdempotencyKeyInProgress},
+		}},
+		{"retry gets the response", []step{
+			{bodyHash: body},
+			{finish: true, response: done, bodyHash: body, replay: true},
+		}},
+		{"other body is refused", []step{
+			{bodyHash: body},
+			{finish: true, response: done, bodyHash: other, conflict: msgIdempotencyKeyReused},
+		}},
+		{"failure releases the key", []step{
+			{bodyHash: body},
+			{finish: true, bodyHash: other},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newIdempotencyStore()
+			for idx, step := range test.steps {
+				if step.finish {
+					store.finish("scope", step.response)
+				}
+				stored, conflict := store.begin("scope", step.bodyHash)
+				if conflict != step.conflict {
+					t.Errorf("step %d conflict %q, want %q", idx, conflict, step.conflict)
+				}
+				if (stored != nil) != step.replay {
+					t.Errorf("step %d replays %v, want %v", idx, stored != nil, step.replay)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoreExpires(t *testing.T) {
+	store := newIdempotencyStore()
+	body := sha256.Sum256([]byte("body"))
+	store.begin("scope", body)
+	store.finish("scope", &storedResponse{status: http.StatusOK})
+	store.entries["scope"].expires = time.Now().Add(-time.Second)
+	if stored, conflict := store.begin("scope", body); stored != nil || conflict != "" {
+		t.Errorf("expired key replayed %v, conflict %q", stored, conflict)
+	}
+}
+
+func TestIdempotencyStoreEvictsOldest(t *testing.T) {
+	store := newIdempotencyStore()
+	body := sha256.Sum256([]byte("body"))
+	for idx := 0; idx <= maxIdempotencyEntries; idx++ {
+		scope := fmt.Sprint(idx)
+		store.begin(scope, body)
+		store.finish(scope, &storedResponse{status: http.StatusOK})
+	}
+	if len(store.entries) != maxIdempotencyEntries {
+		t.Errorf("store has %d entries, want %d", len(store.entries), maxIdempotencyEntries)
+	}
+	if _, ok := store.entries["0"]; ok {
+		t.Errorf("oldest key is still there")
+	}
+	if _, ok := store.entries[fmt.Sprint(maxIdempotencyEntries)]; !ok {
+		t.Errorf("newest key isn't there")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	tests := []struct {
+		name      string
+		anonymous bool
+		user      [2]string
+		body      [2]string
+		size      int
+		replayed  bool
+	}{
+		{"same user, same body", false, [2]string{"u", "u"}, [2]string{"a", "a"}, 10, true},
+		{"other users", false, [2]string{"u", "v"}, [2]string{"a", "a"}, 10, false},
+		{"without a user", false, [2]string{"", ""}, [2]string{"a", "a"}, 10, false},
+		{"anonymous, same body", true, [2]string{"", ""}, [2]string{"a", "a"}, 10, true},
+		{"anonymous, other body", true, [2]string{"", ""}, [2]string{"a", "b"}, 10, false},
+		{"response too large", false, [2]string{"u", "u"}, [2]string{"a", "a"}, maxIdempotentResponseBytes + 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idempotency = newIdempotencyStore()
+			runs := 0
+			handler := Idempotent(test.anonymous)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(strings.Repeat("x", test.size)))
+			}))
+			var last *httptest.ResponseRecorder
+			for idx := range test.user {
+				r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader(test.body[idx]))
+				r.Header.Set(IdempotencyKeyHeader, "key")
+				if test.user[idx] != "" {
+					ctx := context.WithValue(r.Context(), principalKey{}, &Principal{UserID: test.user[idx]})
+					r = r.WithContext(ctx)
+				}
+				last = httptest.NewRecorder()
+				handler.ServeHTTP(last, r)
+			}
+			replayed := last.Header().Get(IdempotencyReplayedHeader) == "true"
+			if replayed != test.replayed || (runs == 1) != test.replayed {
+				t.Errorf("replayed %v after %d runs, want replayed %v", replayed, runs, test.replayed)
+			}
+		})
+	}
+}
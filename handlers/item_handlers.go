@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"todolist/events"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/patch"
+	"todolist/responses"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//V2Endpoints are the REST surface of items,
+//	GET, POST		/v2/items
+//	GET, PUT, PATCH, DELETE	/v2/items/{id}
+//They run next to the /post/* routes older apps still use.
+func V2Endpoints() []Endpoint {
+	return []Endpoint{
+		{Method: http.MethodGet, Pattern: "/v2/items", Handler: ItemList,
+			Doc: Doc{Summary: "List items", Query: itemQueryParams}},
+		{Method: http.MethodPost, Pattern: "/v2/items", Handler: ItemCreate, Requirements: &jsonBody,
+			Doc: Doc{Summary: "Create an item", Body: model.TodoItem{}, Status: http.StatusCreated}},
+		{Method: http.MethodGet, Pattern: "/v2/items/{id}", Handler: ItemGet,
+			Doc: Doc{Summary: "Get an item"}},
+		{Method: http.MethodPut, Pattern: "/v2/items/{id}", Handler: ItemReplace, Requirements: &jsonBody,
+			Doc: Doc{Summary: "Replace an item", Description: "Fields left out are cleared.", Body: model.TodoItem{}}},
+		{Method: http.MethodPatch, Pattern: "/v2/items/{id}", Handler: ItemUpdate, Requirements: &patchBody,
+			Doc: Doc{Summary: "Change some fields of an item",
+				Description: "A JSON Merge Patch or a JSON Patch, as the content type says. Plain json " +
+					"replaces the top level fields in the body. The id, sharing and completion " +
+					"of the item can't be changed.",
+				Body:   map[string]interface{}{},
+				Bodies: map[string]interface{}{patch.MediaType: []patch.Operation{}}}},
+		{Method: http.MethodDelete, Pattern: "/v2/items/{id}", Handler: ItemDelete,
+			Doc: Doc{Summary: "Remove an item"}},
+	}
+}
+
+func V2Router() *Router {
+	return EndpointRouter(V2Endpoints())
+}
+
+//EndpointRouter checks the requirements of endpoints which
+//have their own, on top of those of the route.
+func EndpointRouter(endpoints []Endpoint) *Router {
+	router := NewRouter()
+	for _, endpoint := range endpoints {
+		handler := endpoint.Handler
+		if endpoint.Requirements != nil {
+			handler = Require(*endpoint.Requirements)(handler).ServeHTTP
+		}
+		router.Handle(endpoint.Method, endpoint.Pattern, handler)
+	}
+	return router
+}
+
+//getStoredItem writes a 404 if the user has no item with
+//the id in the path.
+func getStoredItem(w *http.ResponseWriter, r *http.Request, connection *mongo.Client, userID string) *model.TodoItem {
+	stored, err := model.GetOneTodoItemForOwner(r.Context(), connection, userID, PathParam(r, "id"))
+	if err != nil {
+		GenericResponseWithEC(w, msgItemNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+		return nil
+	}
+	return stored
+}
+
+func writeItem(w *http.ResponseWriter, item *model.TodoItem, httpStatusCode int, message string) {
+	resp := responses.Response{
+		Status:  httpStatusCode,
+		APICode: API_ERROR_CODE_OK,
+		Message: message,
+		Meta:    map[string]interface{}{"item": item},
+	}
+	GenericWriteResponse(w, &resp)
+}
+
+//ItemList takes the same query parameters as PostGet.
+func ItemList(w http.ResponseWriter, r *http.Request) {
+	itemQuery, paramErr := parseItemQuery(r.URL.Query())
+	if paramErr != nil {
+		writeInvalidParam(&w, paramErr)
+		return
+	}
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	writeItemPage(&w, r.Context(), connection, userID, itemQuery, false)
+}
+
+func ItemCreate(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	item := model.TodoItem{}
+	if !decodeJSONBody(&w, r, &item) {
+		return
+	}
+	if err := checkItemID(&item); err != nil {
+		writeBodyError(&w, err)
+		return
+	}
+	if _, err := model.GetOneTodoItemForOwner(r.Context(), connection, userID, item.ID); err == nil {
+		GenericResponseWithEC(&w, msgItemAlreadyExists, http.StatusConflict, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	item.Owner = userID
+	if !saveItem(&w, r.Context(), connection, &item, nil) {
+		return
+	}
+	w.Header().Set("Location", "/v2/items/"+item.ID)
+	writeItem(&w, &item, http.StatusCreated, msgItemAdded)
+}
+
+func ItemGet(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	stored := getStoredItem(&w, r, connection, userID)
+	if stored == nil {
+		return
+	}
+	writeItem(&w, stored, http.StatusOK, msgItemFetchComplete)
+}
+
+//ItemReplace replaces the whole item, fields left out of the
+//body are cleared except for the status and priority, which
+//are kept.
+func ItemReplace(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	stored := getStoredItem(&w, r, connection, userID)
+	if stored == nil {
+		return
+	}
+	item := model.TodoItem{Priority: model.PriorityUnset}
+	if !decodeJSONBody(&w, r, &item) {
+		return
+	}
+	item.Owner = userID
+	item.ID = stored.ID
+	if !saveItem(&w, r.Context(), connection, &item, stored) {
+		return
+	}
+	writeItem(&w, &item, http.StatusOK, msgItemModified)
+}
+
+//protectedItemFields are kept by the server, a patch has to
+//leave them as they are.
+var protectedItemFields = []string{"id", "owner", "sharedWith", "completed_at", "completed_by"}
+
+//itemDocument is item as clients see it, a json object.
+func itemDocument(item *model.TodoItem) (map[string]interface{}, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func writePatchError(w *http.ResponseWriter, err error) {
+	switch e := err.(type) {
+	case *patch.TestFailedError:
+		resp := responses.Response{
+			Status:  http.StatusConflict,
+			APICode: API_ERROR_CODE_PATCH_TEST_FAILED,
+			Message: msgPatchTestFailed,
+			Errors:  []responses.FieldError{{Field: fmt.Sprintf("[%d].value", e.Index), Reason: "differs from " + e.Path}},
+		}
+		GenericWriteResponse(w, &resp)
+	case *patch.Error:
+		writeFieldErrors(w, msgPatchCantBeApplied,
+			responses.FieldError{Field: fmt.Sprintf("[%d].%s", e.Index, e.Member), Reason: e.Reason})
+	default:
+		GenericBadRequest(w, msgPatchCantBeApplied)
+	}
+}
+
+//patchDocument applies the patch in the body to doc, the way
+//the content type says, and writes the response if it can't.
+func patchDocument(w *http.ResponseWriter, r *http.Request, doc map[string]interface{}) (map[string]interface{}, bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var patched interface{}
+	switch mediaType {
+	case patch.MediaType:
+		var ops []patch.Operation
+		if !decodeJSONBody(w, r, &ops) {
+			return nil, false
+		}
+		var err error
+		if patched, err = patch.Apply(doc, ops); err != nil {
+			writePatchError(w, err)
+			return nil, false
+		}
+	case patch.MergeMediaType:
+		var members interface{}
+		if !decodeJSONBody(w, r, &members) {
+			return nil, false
+		}
+		patched = patch.Merge(doc, members)
+	default:
+		//Plain json replaces the top level fields present.
+		members := map[string]interface{}{}
+		if !decodeJSONBody(w, r, &members) {
+			return nil, false
+		}
+		for field, value := range members {
+			doc[field] = value
+		}
+		patched = doc
+	}
+	object, ok := patched.(map[string]interface{})
+	if !ok {
+		writeFieldErrors(w, msgPatchedItemNotObject, responses.FieldError{Field: "body", Reason: "must leave an object"})
+		return nil, false
+	}
+	return object, true
+}
+
+//ItemUpdate patches the item and stores only the fields which
+//changed, the rest of the item is kept as stored.
+func ItemUpdate(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	stored := getStoredItem(&w, r, connection, userID)
+	if stored == nil {
+		return
+	}
+	//The patch changes the document it's applied to.
+	before, err := itemDocument(stored)
+	current, currentErr := itemDocument(stored)
+	if err != nil || currentErr != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't turn item %s into json: %v %v", stored.ID, err, currentErr)
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	patched, ok := patchDocument(&w, r, current)
+	if !ok {
+		return
+	}
+	var protected []responses.FieldError
+	for _, field := range protectedItemFields {
+		if !reflect.DeepEqual(before[field], patched[field]) {
+			protected = append(protected, responses.FieldError{Field: field, Reason: "can't be changed"})
+		}
+	}
+	if len(protected) > 0 {
+		writeFieldErrors(&w, msgProtectedFields, protected...)
+		return
+	}
+	merged, err := json.Marshal(patched)
+	if err != nil {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	item := model.TodoItem{}
+	if err = decodeJSON(bytes.NewReader(merged), &item, true, int64(len(merged))); err != nil {
+		writeBodyError(&w, err)
+		return
+	}
+	item.Owner = userID
+	item.ID = stored.ID
+	if !prepareItem(&w, &item, stored) {
+		return
+	}
+	after, err := itemDocument(&item)
+	if err == nil {
+		err = item.UpdateFields(r.Context(), connection, stored, patch.Diff(before, after))
+	}
+	if err == model.ErrItemChanged {
+		GenericResponseWithEC(&w, msgItemHasChanged, http.StatusConflict, API_ERROR_CODE_INVALID_TRANSITION)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't update ToDo Item %s for user %s", item.ID, userID)
+		GenericInternalServerError(&w, msgItemSaveFailed)
+		return
+	}
+	if !model.RegisterTags(r.Context(), connection, userID, item.Tags) {
+		logging.FromContext(r.Context()).Errorf("Couldn't register tags %v for user %s", item.Tags, userID)
+	}
+	publishItemEvent(r.Context(), connection, events.ItemUpdated, &item, stored)
+	writeItem(&w, &item, http.StatusOK, msgItemModified)
+}
+
+func ItemDelete(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	if !removeItem(&w, r.Context(), connection, userID, PathParam(r, "id")) {
+		return
+	}
+	GenericResponse(&w, msgItemRemoved, http.StatusOK)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+	"todolist/handlers/token"
+	"todolist/i18n"
+	"todolist/model"
+	"todolist/responses"
+)
+
+//localize translates the message and the API code description
+//of resp, those without a translation stay in english.
+func localize(w *http.ResponseWriter, resp *responses.Response, locale string) {
+	if locale == "" {
+		locale = i18n.DefaultLocale
+	}
+	(*w).Header().Set("Content-Language", locale)
+	resp.APICodeDescription = i18n.CodeDescription(locale, resp.APICode, resp.APICodeDescription)
+	resp.Message = i18n.Translate(locale, resp.Message, resp.MessageArgs...)
+}
+
+//responseLocale is the locale the response goes out in.
+func responseLocale(w *http.ResponseWriter) string {
+	if nw := findNegotiatedWriter(*w); nw != nil && nw.locale != "" {
+		return nw.locale
+	}
+	return i18n.DefaultLocale
+}
+
+//pluralMessage is the message id with count in the language
+//the response goes out in.
+func pluralMessage(w *http.ResponseWriter, id string, count int) string {
+	return i18n.Plural(responseLocale(w), id, count)
+}
+
+//translate is the message id in the language the response goes
+//out in, for messages which aren't the response's own.
+func translate(w *http.ResponseWriter, id string, args ...interface{}) string {
+	return i18n.Translate(responseLocale(w), id, args...)
+}
+
+const (
+	//userLocaleTTL is how long the locale of a user is kept
+	//before it's read from the database again.
+	userLocaleTTL = 5 * time.Minute
+	//maxUserLocales bounds the cache, expired entries are
+	//dropped when it's full.
+	maxUserLocales = 10000
+)
+
+type cachedLocale struct {
+	locale  string
+	expires time.Time
+}
+
+//userLocales caches the locale users registered with, so that
+//requests don't each look the user up.
+var userLocales = struct {
+	sync.Mutex
+	entries map[string]cachedLocale
+}{entries: map[string]cachedLocale{}}
+
+//registeredLocale is the locale the user registered with in
+//extra, from the cache while it's fresh.
+func registeredLocale(r *http.Request, userID string) string {
+	now := time.Now()
+	userLocales.Lock()
+	cached, ok := userLocales.entries[userID]
+	userLocales.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.locale
+	}
+	locale := ""
+	user := model.GetUserForId(r.Context(), RequestDatabase(r), userID)
+	if user != nil {
+		locale, _ = user.Meta["locale"].(string)
+	}
+	userLocales.Lock()
+	defer userLocales.Unlock()
+	if len(userLocales.entries) >= maxUserLocales {
+		for id, entry := range userLocales.entries {
+			if now.After(entry.expires) {
+				delete(userLocales.entries, id)
+			}
+		}
+		if len(userLocales.entries) >= maxUserLocales {
+			return locale
+		}
+	}
+	userLocales.entries[userID] = cachedLocale{locale: locale, expires: now.Add(userLocaleTTL)}
+	return locale
+}
+
+//storedLocale is the locale Google gave us for the user, or
+//the one the user registered with in extra.
+func storedLocale(r *http.Request) string {
+	principal := RequestPrincipal(r)
+	if principal == nil {
+		return ""
+	}
+	if claims, ok := principal.Claims.(*token.GoogleClaim); ok && claims.Locale != "" {
+		return claims.Locale
+	}
+	return registeredLocale(r, principal.UserID)
+}
+
+//UserLocale answers in the user's stored locale when
+//Accept-Language didn't name one we have. Legacy clients only
+//get another language if they ask for it.
+func UserLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if APIVersion(r) == LegacyAPIVersion {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if nw := findNegotiatedWriter(w); nw != nil && nw.locale == "" {
+			nw.locale = i18n.Match(storedLocale(r))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"todolist/metrics"
+)
+
+var (
+	requestsTotal = metrics.NewCounter("todolist_http_requests_total",
+		"Requests served, by route, method and status.", "route", "method", "status")
+	requestDuration = metrics.NewHistogram("todolist_http_request_duration_seconds",
+		"How long requests took to serve, by route and method.", nil, "route", "method")
+	requestsInFlight = metrics.NewGauge("todolist_http_requests_in_flight",
+		"Requests being served, event streams included.")
+	apiErrorsTotal = metrics.NewCounter("todolist_api_errors_total",
+		"Error responses, by API error code.", "apicode")
+	verificationsTotal = metrics.NewCounter("todolist_token_verifications_total",
+		"Bearer tokens verified, by provider and result.", "provider", "result")
+)
+
+//knownMethods are the methods labelled as they are on routes
+//taking any, those of HTTP and of the CalDAV clients.
+var knownMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions, "PROPFIND", "REPORT"}
+
+//methodLabel is method if the route takes it, "other" otherwise,
+//so methods made up by clients don't each start new series.
+func methodLabel(method string, methods []string) string {
+	if len(methods) == 0 {
+		methods = knownMethods
+	}
+	for _, allowed := range methods {
+		if method == allowed {
+			return method
+		}
+	}
+	return "other"
+}
+
+//Measure counts the requests of route, which takes methods or
+//any if there are none, and how long they take.
+func Measure(route string, methods ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestsInFlight.Inc()
+			defer requestsInFlight.Dec()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			method := methodLabel(r.Method, methods)
+			requestsTotal.Inc(route, method, strconv.Itoa(status))
+			requestDuration.Observe(time.Since(start).Seconds(), route, method)
+		})
+	}
+}
+
+//RegisterAdminRoutes serves what's for operators rather than
+//apps on mux, the main one or that of the admin port.
+func RegisterAdminRoutes(mux *http.ServeMux) {
+	mux.Handle("/metrics", metrics.Handler())
+}
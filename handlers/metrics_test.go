@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMethodLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		methods []string
+		want    string
+	}{
+		{"allowed", http.MethodPost, post, http.MethodPost},
+		{"not allowed", http.MethodDelete, post, "other"},
+		{"made up", "BREW", get, "other"},
+		{"any route, known", "PROPFIND", nil, "PROPFIND"},
+		{"any route, made up", "BREW", nil, "other"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := methodLabel(test.method, test.methods); got != test.want {
+				t.Errorf("labelled %s, want %s", got, test.want)
+			}
+		})
+	}
+}
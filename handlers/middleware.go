@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/database"
+	"todolist/environment"
+	"todolist/logging"
+	"todolist/tptverify"
+	"todolist/tracing"
+	"todolist/utils"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//Middleware wraps a handler with work that has to be done
+//before, or after, the handler runs. A middleware which has
+//already answered the request doesn't call next.
+type Middleware func(next http.Handler) http.Handler
+
+//Chain wraps handler so that the first of middlewares is the
+//first to see the request.
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for idx := len(middlewares) - 1; idx >= 0; idx-- {
+		handler = middlewares[idx](handler)
+	}
+	return handler
+}
+
+//Principal is the authenticated user of a request, put into
+//the request context by Authenticate.
+type Principal struct {
+	UserID string
+	Claims interface{}
+	tptverify.Verifier
+}
+
+const (
+	requestIDBytes     = 8
+	maxRequestIDLength = 128
+)
+
+type principalKey struct{}
+type databaseKey struct{}
+
+//RequestPrincipal returns the user Authenticate verified, it's
+//nil on routes which don't authenticate.
+func RequestPrincipal(r *http.Request) *Principal {
+	principal, _ := r.Context().Value(principalKey{}).(*Principal)
+	return principal
+}
+
+//RequestUserID is the id of RequestPrincipal.
+func RequestUserID(r *http.Request) string {
+	if principal := RequestPrincipal(r); principal != nil {
+		return principal.UserID
+	}
+	return ""
+}
+
+//RequestDatabase returns the connection WithDatabase got for
+//the request, it's released once the handler returns.
+func RequestDatabase(r *http.Request) *mongo.Client {
+	connection, _ := r.Context().Value(databaseKey{}).(*mongo.Client)
+	return connection
+}
+
+//statusWriter remembers whether anything was written, so
+//that RecoverPanic knows if it can still send a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(bytes []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(bytes)
+}
+
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Hijack hands the connection over, nothing can be written to
+//it here after that.
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	sw.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+//RecoverPanic turns a panicking handler into a 500 instead of
+//a dropped connection, and logs where it happened.
+func RecoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			if err := recover(); err != nil {
+				logging.FromContext(r.Context()).Errorf("panic serving %s: %v\n%s", r.URL.Path, err, debug.Stack())
+				if sw.status == 0 {
+					var rw http.ResponseWriter = sw
+					GenericInternalServerError(&rw, msgInternalServerError)
+				}
+			}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+//LogRequests gives every request a logger with its id, method,
+//route and path, and logs an entry once it's been served with
+//the status and how long it took. The id is the X-Request-ID
+//the client sent, or a new one, and goes back in the response.
+func LogRequests(route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(utils.RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(utils.RequestIDHeader, id)
+			ctx := logging.NewContext(utils.WithRequestID(r.Context(), id))
+			logging.SetField(ctx, "request_id", id)
+			logging.SetField(ctx, "method", r.Method)
+			logging.SetField(ctx, "route", route)
+			logging.SetField(ctx, "path", r.URL.Path)
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := logging.Info
+			if status >= http.StatusInternalServerError {
+				level = logging.Error
+			}
+			logging.FromContext(ctx).Log(level, "Served request",
+				logging.Field{Key: "status", Value: status},
+				logging.Field{Key: "latency_ms", Value: float64(time.Since(start).Microseconds()) / 1000})
+		})
+	}
+}
+
+//newRequestID is a random id to tell the entries of requests
+//apart.
+func newRequestID() string {
+	random := make([]byte, requestIDBytes)
+	if _, err := rand.Read(random); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(random)
+}
+
+//validRequestID takes ids clients send if they're short and
+//made of characters which can't break a log line or a header.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:/+=", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+//Trace serves the request in a server span, under the span of
+//the caller if it sent a traceparent. What's done for the request
+//is traced under it, and its entries carry the trace id.
+func Trace(route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if remote, ok := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader)); ok {
+				ctx = tracing.ContextWithRemoteParent(ctx, remote)
+			}
+			ctx, span := tracing.Start(ctx, r.Method+" "+route, tracing.Server)
+			defer span.End()
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("http.target", r.URL.RequestURI())
+			span.SetAttribute("request_id", utils.GetRequestID(ctx))
+			logging.SetField(ctx, "trace_id", span.SpanContext().TraceID.String())
+			logging.SetField(ctx, "span_id", span.SpanContext().SpanID.String())
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(tracing.StatusError, http.StatusText(status))
+			}
+		})
+	}
+}
+
+func RedirectHTTPS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if redirectToHTTPS(&w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//AllowMethods answers any other method with a 405 and the
+//Allow header.
+func AllowMethods(methods ...string) Middleware {
+	allowed := strings.Join(methods, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, method := range methods {
+				if r.Method == method {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.Header().Set("Allow", allowed)
+			GenericResponseWithEC(&w, msgMethodNotSupported,
+				http.StatusMethodNotAllowed, API_ERROR_CODE_GENERIC_ERROR)
+		})
+	}
+}
+
+//Authenticate verifies the bearer token with the verifier named
+//by X-Resource-Auth, ours if there's none, and puts the user into
+//the request context.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, response := VerifyBearerToken(r)
+		if claims.Claims == nil || claims.Verifier == nil {
+			logging.FromContext(r.Context()).Infof("Couldn't verify bearer token")
+			GenericWriteResponse(&w, &response)
+			return
+		}
+		userID, err := claims.UserId(claims.Claims)
+		if err != nil {
+			logging.FromContext(r.Context()).Infof("UserID doesn't exist")
+			GenericBadRequest(&w, msgUserIDMissing)
+			return
+		}
+		principal := &Principal{
+			UserID:   userID,
+			Claims:   claims.Claims,
+			Verifier: claims.Verifier,
+		}
+		logging.SetField(r.Context(), "user_id", userID)
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+//WithDatabase gets a mongo connection for the handler and
+//releases it once the handler is done.
+func WithDatabase(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connection, err := database.GetMongoConnectionContext(r.Context(), environment.GetMongoConnectionString())
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Couldn't get MongoDB Connection")
+			GenericInternalServerError(&w, msgInternalServerError)
+			return
+		}
+		defer database.ReleaseMongoConnection(connection)
+		ctx := context.WithValue(r.Context(), databaseKey{}, connection)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
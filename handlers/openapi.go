@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"todolist/environment"
+	"todolist/logging"
+	"todolist/openapi"
+	"todolist/responses"
+	"todolist/tracing"
+	"todolist/utils"
+)
+
+var documentOnce sync.Once
+var document []byte
+
+//apiErrorCodes are all codes ApiErrorCodeToString knows.
+func apiErrorCodes() []int64 {
+	codes := []int64{API_ERROR_CODE_OK}
+	for code := API_ERROR_CODE_GENERIC_ERROR; ; code++ {
+		if strings.HasPrefix(ApiErrorCodeToString(code), "unknown error code") {
+			return codes
+		}
+		codes = append(codes, code)
+	}
+}
+
+func apiCodeSchema() *openapi.Schema {
+	var values []interface{}
+	var descriptions []string
+	for _, code := range apiErrorCodes() {
+		values = append(values, code)
+		descriptions = append(descriptions, fmt.Sprintf("%d: %s", code, ApiErrorCodeToString(code)))
+	}
+	schema := openapi.Enum("integer", strings.Join(descriptions, "\n"), values...)
+	schema.Format = "int64"
+	return schema
+}
+
+//operation describes method on pattern, reqs are the
+//requirements of the route it's served by. anonymous is the
+//AnonymousIdempotency of the route.
+func operation(schemas *openapi.Schemas, method, pattern string, doc *Doc, reqs *Requirements,
+	anonymous bool) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: openapi.OperationID(method, pattern),
+		Summary:     doc.Summary,
+		Description: doc.Description,
+		Tags:        []string{strings.Split(strings.Trim(pattern, "/"), "/")[0]},
+		Responses:   map[string]*openapi.Response{},
+		Security:    []map[string][]string{},
+	}
+	for _, name := range openapi.PathParams(pattern) {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name: name, In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+	for _, param := range doc.Query {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name: param.Name, In: "query", Description: param.Description, Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+	op.Parameters = append(op.Parameters, openapi.Parameter{
+		Name: APIVersionHeader, In: "header", Schema: &openapi.Schema{Type: "integer"},
+		Description: fmt.Sprintf("API version the app was built against, %d if left out", LegacyAPIVersion),
+	})
+	op.Parameters = append(op.Parameters, openapi.Parameter{
+		Name: utils.RequestIDHeader, In: "header", Schema: &openapi.Schema{Type: "string"},
+		Description: "id of the request, sent back in the response and logged with it",
+	}, openapi.Parameter{
+		Name: tracing.TraceparentHeader, In: "header", Schema: &openapi.Schema{Type: "string"},
+		Description: "W3C trace context of the caller, the request is traced as part of it",
+	})
+	contentTypes := []string{"application/json"}
+	if reqs != nil {
+		for _, header := range reqs.Headers {
+			if header.Name == bearerHeader.Name {
+				op.Security = append(op.Security, map[string][]string{"bearer": {}})
+				continue
+			}
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name: header.Name, In: "header", Required: header.Required, Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+		if len(reqs.ContentTypes) > 0 {
+			contentTypes = reqs.ContentTypes
+		}
+	}
+	//Only signed in users' keys are kept, see Idempotent.
+	if idempotentMethod(method) && (anonymous || len(op.Security) > 0) {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name: IdempotencyKeyHeader, In: "header", Schema: &openapi.Schema{Type: "string"},
+			Description: "retries with the same key get the first response again",
+		})
+	}
+	if doc.Body != nil {
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{}}
+		for _, contentType := range contentTypes {
+			body := doc.Body
+			if typed, ok := doc.Bodies[contentType]; ok {
+				body = typed
+			}
+			op.RequestBody.Content[contentType] = openapi.MediaType{Schema: schemas.For(body)}
+		}
+	}
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(status)] = &openapi.Response{
+		Description: http.StatusText(status),
+		Content:     map[string]openapi.MediaType{"application/json": {Schema: openapi.Ref("Response")}},
+	}
+	if doc.Produces != "" {
+		content := map[string]openapi.MediaType{}
+		for _, mediaType := range strings.Split(doc.Produces, ",") {
+			content[mediaType] = openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+		}
+		op.Responses[strconv.Itoa(status)].Content = content
+	}
+	op.Responses["default"] = &openapi.Response{
+		Description: "An error, as a problem when Accept asks for " + responses.ProblemMediaType,
+		Content: map[string]openapi.MediaType{
+			"application/json":         {Schema: openapi.Ref("Response")},
+			responses.ProblemMediaType: {Schema: openapi.Ref("Problem")},
+		},
+	}
+	return op
+}
+
+//APIDocument describes all the routes with their Doc and all
+//the endpoints of routes which have them.
+func APIDocument() *openapi.Document {
+	schemas := openapi.NewSchemas()
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:   environment.GetAppName(),
+			Version: strconv.Itoa(LatestAPIVersion),
+		},
+		Paths: map[string]openapi.PathItem{},
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	for _, route := range Routes() {
+		add := func(method, pattern string, routeDoc *Doc, reqs *Requirements) {
+			if doc.Paths[pattern] == nil {
+				doc.Paths[pattern] = openapi.PathItem{}
+			}
+			doc.Paths[pattern][strings.ToLower(method)] = operation(schemas, method, pattern, routeDoc, reqs,
+				route.AnonymousIdempotency)
+		}
+		for idx := range route.Endpoints {
+			endpoint := &route.Endpoints[idx]
+			add(endpoint.Method, endpoint.Pattern, &endpoint.Doc, endpoint.requirements(route.Requirements))
+		}
+		if route.Doc == nil {
+			continue
+		}
+		for _, method := range route.Methods {
+			add(method, route.Path, route.Doc, route.Requirements)
+		}
+	}
+	for _, envelope := range []interface{}{responses.Response{}, responses.Problem{}} {
+		schemas.For(envelope)
+	}
+	schemas.Components["APICode"] = apiCodeSchema()
+	schemas.Components["Response"].Properties["apicode"] = openapi.Ref("APICode")
+	schemas.Components["Problem"].Properties["apicode"] = openapi.Ref("APICode")
+	doc.Components.Schemas = schemas.Components
+	return doc
+}
+
+//OpenAPI serves APIDocument, it's built on the first request.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	documentOnce.Do(func() {
+		var err error
+		document, err = json.Marshal(APIDocument())
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Couldn't marshal API document: %v", err)
+		}
+	})
+	if document == nil {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(document)
+}
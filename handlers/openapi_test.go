@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"todolist/model"
+	"todolist/openapi"
+	"todolist/patch"
+)
+
+//decodedBodies are what the handlers decode the body of each
+//operation into, by content type. "" is the type of the body
+//the content type has no entry for.
+var decodedBodies = map[string]map[string]interface{}{
+	"POST /login":              {"": model.User{}},
+	"POST /tptverify":          {"": deviceInfo{}},
+	"POST /register":           {"": model.User{}},
+	"POST /post/add":           {"": model.TodoItem{}},
+	"POST /post/remove":        {"": itemIDRequest{}},
+	"POST /post/edit":          {"": model.TodoItem{}},
+	"POST /post/batch":         {"": batchRequest{}},
+	"POST /tags/add":           {"": model.Tag{}},
+	"POST /tags/edit":          {"": model.Tag{}},
+	"POST /tags/remove":        {"": tagNameRequest{}},
+	"POST /tags/rename":        {"": tagRenameRequest{}},
+	"POST /tags/merge":         {"": tagMergeRequest{}},
+	"POST /export/ics/token":   {"": feedTokenRequest{}},
+	"POST /import/ics":         {"": ""},
+	"POST /import":             {"": []model.TodoItem{}, "text/csv": "", "text/plain": ""},
+	"POST /v2/items":           {"": model.TodoItem{}},
+	"PUT /v2/items/{id}":       {"": model.TodoItem{}},
+	"PATCH /v2/items/{id}":     {"": map[string]interface{}{}, patch.MediaType: []patch.Operation{}},
+	"POST /user/data/requests": {"": dataRequestBody{}},
+}
+
+//documented is an operation of Routes, with its route.
+type documented struct {
+	method, pattern string
+	doc             *Doc
+	reqs            *Requirements
+}
+
+func documentedOperations(t *testing.T) []documented {
+	var operations []documented
+	for _, route := range Routes() {
+		switch {
+		case len(route.Methods) > 0 && route.Doc == nil:
+			t.Errorf("%s has no Doc", route.Path)
+		case len(route.Methods) == 0 && route.Doc != nil:
+			t.Errorf("%s documents no methods", route.Path)
+		case route.Doc != nil:
+			for _, method := range route.Methods {
+				operations = append(operations, documented{method, route.Path, route.Doc, route.Requirements})
+			}
+		}
+		for idx := range route.Endpoints {
+			endpoint := &route.Endpoints[idx]
+			if !strings.HasPrefix(endpoint.Pattern, route.Path) {
+				t.Errorf("%s isn't under %s", endpoint.Pattern, route.Path)
+			}
+			if endpoint.Handler == nil {
+				t.Errorf("%s %s has no handler", endpoint.Method, endpoint.Pattern)
+			}
+			operations = append(operations, documented{endpoint.Method, endpoint.Pattern, &endpoint.Doc,
+				endpoint.requirements(route.Requirements)})
+		}
+	}
+	return operations
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	ids := map[string]string{}
+	for _, op := range documentedOperations(t) {
+		where := op.method + " " + op.pattern
+		if op.doc.Summary == "" {
+			t.Errorf("%s has no summary", where)
+		}
+		if methodHasBody(op.method) && op.doc.Body == nil {
+			t.Errorf("%s doesn't document its body", where)
+		}
+		if !methodHasBody(op.method) && op.doc.Body != nil {
+			t.Errorf("%s documents a body it doesn't take", where)
+		}
+		id := openapi.OperationID(op.method, op.pattern)
+		if other, ok := ids[id]; ok {
+			t.Errorf("operation %s is both %s and %s", id, other, where)
+		}
+		ids[id] = where
+	}
+}
+
+func TestDocumentedBodiesAreDecoded(t *testing.T) {
+	seen := map[string]bool{}
+	for _, op := range documentedOperations(t) {
+		if op.doc.Body == nil {
+			continue
+		}
+		where := op.method + " " + op.pattern
+		seen[where] = true
+		decoded, ok := decodedBodies[where]
+		if !ok {
+			t.Errorf("%s isn't in decodedBodies", where)
+			continue
+		}
+		if reflect.TypeOf(op.doc.Body) != reflect.TypeOf(decoded[""]) {
+			t.Errorf("%s documents a %T body, its handler decodes a %T", where, op.doc.Body, decoded[""])
+		}
+		for contentType, body := range op.doc.Bodies {
+			if reflect.TypeOf(body) != reflect.TypeOf(decoded[contentType]) {
+				t.Errorf("%s documents a %T body as %s, its handler decodes a %T",
+					where, body, contentType, decoded[contentType])
+			}
+		}
+		for contentType := range decoded {
+			if _, ok := op.doc.Bodies[contentType]; contentType != "" && !ok {
+				t.Errorf("%s doesn't document its %s body", where, contentType)
+			}
+		}
+	}
+	for where := range decodedBodies {
+		if !seen[where] {
+			t.Errorf("%s is in decodedBodies but isn't documented with a body", where)
+		}
+	}
+}
+
+//TestAPIDocument checks the document has every operation, with
+//the schema of what its handler decodes for each content type
+//it takes.
+func TestAPIDocument(t *testing.T) {
+	document := APIDocument()
+	schemas := openapi.NewSchemas()
+	for _, op := range documentedOperations(t) {
+		where := op.method + " " + op.pattern
+		operation := document.Paths[op.pattern][strings.ToLower(op.method)]
+		if operation == nil {
+			t.Errorf("%s isn't in the document", where)
+			continue
+		}
+		if operation.OperationID != openapi.OperationID(op.method, op.pattern) {
+			t.Errorf("%s has operation id %s", where, operation.OperationID)
+		}
+		decoded, ok := decodedBodies[where]
+		if !ok {
+			if operation.RequestBody != nil {
+				t.Errorf("%s has a request body in the document", where)
+			}
+			continue
+		}
+		if operation.RequestBody == nil {
+			t.Errorf("%s has no request body in the document", where)
+			continue
+		}
+		contentTypes := []string{"application/json"}
+		if op.reqs != nil && len(op.reqs.ContentTypes) > 0 {
+			contentTypes = op.reqs.ContentTypes
+		}
+		for _, contentType := range contentTypes {
+			body, ok := decoded[contentType]
+			if !ok {
+				body = decoded[""]
+			}
+			mediaType, ok := operation.RequestBody.Content[contentType]
+			if !ok {
+				t.Errorf("%s doesn't document its %s body", where, contentType)
+				continue
+			}
+			want, _ := json.Marshal(schemas.For(body))
+			got, _ := json.Marshal(mediaType.Schema)
+			if string(want) != string(got) {
+				t.Errorf("%s documents its %s body as %s, its handler decodes %s", where, contentType, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"todolist/i18n"
+	"todolist/responses"
+)
+
+//negotiatedWriter is put in front of the handlers by
+//Negotiate, GenericWriteResponse finds it to know how the
+//client wants errors and in which language.
+type negotiatedWriter struct {
+	http.ResponseWriter
+	problem  bool
+	instance string
+	locale   string
+}
+
+func (nw *negotiatedWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
+
+//Flush and Hijack are passed on, for event streams.
+func (nw *negotiatedWriter) Flush() {
+	if flusher, ok := nw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (nw *negotiatedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := nw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	return hijacker.Hijack()
+}
+
+//acceptsProblem is true when Accept prefers problem+json to
+//plain json. Clients which don't mention it get the legacy
+//response.
+func acceptsProblem(accept string) bool {
+	problemQ, jsonQ := 0.0, 0.0
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case responses.ProblemMediaType:
+			problemQ = q
+		case "application/json":
+			jsonQ = q
+		}
+	}
+	return problemQ > 0 && problemQ >= jsonQ
+}
+
+//Negotiate picks the error format from Accept, the legacy
+//response stays the default, and the language of messages
+//from Accept-Language. UserLocale may pick the language later
+//for clients which didn't ask for one we have.
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept, Accept-Language")
+		nw := &negotiatedWriter{
+			ResponseWriter: w,
+			problem:        acceptsProblem(r.Header.Get("Accept")),
+			instance:       r.URL.Path,
+			locale:         i18n.Match(i18n.ParseAcceptLanguage(r.Header.Get("Accept-Language"))...),
+		}
+		next.ServeHTTP(nw, r)
+	})
+}
+
+//unwrapper is a writer wrapped around another one, as
+//http.ResponseController expects them to be.
+type unwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+//findNegotiatedWriter looks through the writers the
+//middlewares wrapped around it, each of them must implement
+//unwrapper for it to be found.
+func findNegotiatedWriter(w http.ResponseWriter) *negotiatedWriter {
+	for {
+		if writer, ok := w.(*negotiatedWriter); ok {
+			return writer
+		}
+		wrapper, ok := w.(unwrapper)
+		if !ok {
+			return nil
+		}
+		w = wrapper.Unwrap()
+	}
+}
+
+func newProblem(resp *responses.Response, instance string) *responses.Problem {
+	problem := &responses.Problem{
+		Type:      fmt.Sprintf("/problems/%d", resp.APICode),
+		Title:     resp.APICodeDescription,
+		Status:    resp.Status,
+		Detail:    resp.Message,
+		Instance:  instance,
+		APICode:   resp.APICode,
+		Errors:    resp.Errors,
+		Meta:      resp.Meta,
+		RequestID: resp.RequestID,
+	}
+	if resp.APICode == API_ERROR_CODE_GENERIC_ERROR {
+		problem.Type = "about:blank"
+		problem.Title = http.StatusText(resp.Status)
+	}
+	return problem
+}
+
+//writeFieldErrors answers with a 400 listing what was wrong
+//with each field.
+func writeFieldErrors(w *http.ResponseWriter, message string, errors ...responses.FieldError) {
+	response := responses.Response{
+		Status:  http.StatusBadRequest,
+		APICode: API_ERROR_CODE_INVALID_INPUT,
+		Message: message,
+		Errors:  errors,
+	}
+	GenericWriteResponse(w, &response)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/model"
+	"todolist/responses"
+	"todolist/utils"
+)
+
+const (
+	maxSortKeys     = 4
+	maxQueryTextLen = 256
+	maxPageSize     = 500
+)
+
+//invalidParamError names the query parameter that couldn't
+//be understood so that clients know what to fix.
+type invalidParamError struct {
+	Param  string
+	Reason string
+}
+
+func (e *invalidParamError) Error() string {
+	return fmt.Sprintf("invalid parameter %s: %s", e.Param, e.Reason)
+}
+
+func writeInvalidParam(w *http.ResponseWriter, err *invalidParamError) {
+	response := responses.Response{
+		Status:      http.StatusBadRequest,
+		APICode:     API_ERROR_CODE_INVALID_INPUT,
+		Message:     msgInvalidParam,
+		MessageArgs: []interface{}{err.Param, err.Reason},
+		Errors:      []responses.FieldError{{Field: err.Param, Reason: err.Reason}},
+	}
+	GenericWriteResponse(w, &response)
+}
+
+//listParam collects a parameter that may be repeated as well
+//as hold comma separated values. Empty values are dropped.
+func listParam(values url.Values, param string) []string {
+	var result []string
+	for _, value := range values[param] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+func timeParam(values url.Values, param string) (string, *invalidParamError) {
+	value := values.Get(param)
+	if value == "" {
+		return "", nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", &invalidParamError{param, "must be an RFC3339 timestamp"}
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+//itemQueryParams documents the parameters of parseItemQuery.
+var itemQueryParams = []QueryParam{
+	{"shared", "1 for only the items of the user shared with the user as well"},
+	{"tag", "items carrying the tags, comma separated"},
+	{"tagmode", "any or all of tag, any if left out"},
+	{"status", "items in any of the states"},
+	{"priority", "items with any of the priorities"},
+	{"list", "items filed under any of the lists"},
+	{"due_after", "RFC3339, items ending after"},
+	{"due_before", "RFC3339, items ending before"},
+	{"q", "case insensitive match on name"},
+	{"sort", "sort keys, - in front for descending"},
+	{"fields", "only send back these fields"},
+	{"count", fmt.Sprintf("page size, 1 to %d", maxPageSize)},
+	{"cursor", "continue after a previous page"},
+	{"offset", "legacy paging, not with cursor"},
+	{"total", "1 to also count all matching items"},
+}
+
+//parseItemQuery understands the following parameters, all
+//of them optional.
+//	shared=1			only items also shared with their owner
+//	tag=a,b&tagmode=any|all		items carrying the tags
+//	status=todo,doing		items in any of the states
+//	priority=1,2			items with any of the priorities
+//	list=work			items filed under any of the lists
+//	due_after, due_before		RFC3339 range on end_time
+//	q=text				case insensitive match on name
+//	sort=-priority,name		sort keys, - for descending
+//	fields=name,status		only send back these fields
+//	count				page size, at most maxPageSize
+//	cursor				continue after a previous page
+//	offset				legacy paging, not with cursor
+//	total=1				also count all matching items
+func parseItemQuery(values url.Values) (*model.ItemQuery, *invalidParamError) {
+	var err error
+	query := &model.ItemQuery{}
+
+	query.Shared = values.Get("shared") == "1"
+	query.Tags.Tags, err = model.NormalizeTagNames(listParam(values, "tag"))
+	if err != nil {
+		return nil, &invalidParamError{"tag", err.Error()}
+	}
+	switch values.Get("tagmode") {
+	case "", "any":
+	case "all":
+		query.Tags.MatchAll = true
+	default:
+		return nil, &invalidParamError{"tagmode", "must be any or all"}
+	}
+	query.Statuses = listParam(values, "status")
+	for _, status := range query.Statuses {
+		if !model.GetWorkflow().Valid(status) {
+			return nil, &invalidParamError{"status", fmt.Sprintf("unknown status %s", status)}
+		}
+	}
+	for _, priority := range listParam(values, "priority") {
+		p, err := strconv.Atoi(priority)
+		if err != nil || !model.ValidPriority(p) {
+			return nil, &invalidParamError{"priority", fmt.Sprintf("unknown priority %s", priority)}
+		}
+		query.Priorities = append(query.Priorities, p)
+	}
+	query.Lists = listParam(values, "list")
+
+	var paramErr *invalidParamError
+	if query.DueAfter, paramErr = timeParam(values, "due_after"); paramErr != nil {
+		return nil, paramErr
+	}
+	if query.DueBefore, paramErr = timeParam(values, "due_before"); paramErr != nil {
+		return nil, paramErr
+	}
+	if query.DueAfter != "" && query.DueBefore != "" && query.DueAfter > query.DueBefore {
+		return nil, &invalidParamError{"due_before", "must not be earlier than due_after"}
+	}
+
+	query.Text = strings.TrimSpace(values.Get("q"))
+	if len(query.Text) > maxQueryTextLen {
+		return nil, &invalidParamError{"q", fmt.Sprintf("must be at most %d characters", maxQueryTextLen)}
+	}
+
+	sortKeys := listParam(values, "sort")
+	if len(sortKeys) > maxSortKeys {
+		return nil, &invalidParamError{"sort", fmt.Sprintf("at most %d keys are allowed", maxSortKeys)}
+	}
+	var seen utils.StringSlice
+	for _, key := range sortKeys {
+		sortKey := model.SortKey{Field: strings.TrimPrefix(key, "-")}
+		sortKey.Descending = sortKey.Field != key
+		if !utils.StringSlice(model.SortableItemFields).Contains(sortKey.Field) {
+			return nil, &invalidParamError{"sort", fmt.Sprintf("can't sort on %s", sortKey.Field)}
+		}
+		if seen.Contains(sortKey.Field) {
+			return nil, &invalidParamError{"sort", fmt.Sprintf("%s given more than once", sortKey.Field)}
+		}
+		seen = append(seen, sortKey.Field)
+		query.Sort = append(query.Sort, sortKey)
+	}
+
+	for _, field := range listParam(values, "fields") {
+		if _, ok := model.ItemFields[field]; !ok {
+			return nil, &invalidParamError{"fields", fmt.Sprintf("unknown field %s", field)}
+		}
+		query.Fields = append(query.Fields, field)
+	}
+
+	if offset := values.Get("offset"); offset != "" {
+		value, err := strconv.ParseUint(offset, 10, 32)
+		if err != nil {
+			return nil, &invalidParamError{"offset", "must be a whole number"}
+		}
+		query.Offset = uint(value)
+	}
+	if count := values.Get("count"); count != "" {
+		value, err := strconv.ParseUint(count, 10, 32)
+		if err != nil || value == 0 || value > maxPageSize {
+			return nil, &invalidParamError{"count", fmt.Sprintf("must be a whole number from 1 to %d", maxPageSize)}
+		}
+		query.Count = uint(value)
+	}
+	query.Cursor = values.Get("cursor")
+	if query.Cursor != "" && query.Offset > 0 {
+		return nil, &invalidParamError{"cursor", "can't be combined with offset"}
+	}
+	query.WithTotal = values.Get("total") == "1"
+	return query, nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"todolist/model"
+)
+
+func TestParseItemQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   string
+		want     *model.ItemQuery
+		badParam string
+	}{
+		{"nothing", "", &model.ItemQuery{}, ""},
+		{"shared", "shared=1", &model.ItemQuery{Shared: true}, ""},
+		{"tags, repeated and comma separated", "tag=a,b&tag=+c+&tag=a&tagmode=all",
+			&model.ItemQuery{Tags: model.TagFilter{Tags: []string{"a", "b", "c"}, MatchAll: true}}, ""},
+		{"unknown tag mode", "tagmode=some", nil, "tagmode"},
+		{"unknown status", "status=lost", nil, "status"},
+		{"priorities", "priority=1,2", &model.ItemQuery{Priorities: []int{1, 2}}, ""},
+		{"unknown priority", "priority=high", nil, "priority"},
+		{"due range", "due_after=2020-01-01T00:00:00Z&due_before=2020-02-01T01:00:00%2B01:00",
+			&model.ItemQuery{DueAfter: "2020-01-01T00:00:00Z", DueBefore: "2020-02-01T00:00:00Z"}, ""},
+		{"due range backwards", "due_after=2020-02-01T00:00:00Z&due_before=2020-01-01T00:00:00Z", nil, "due_before"},
+		{"due not a time", "due_after=tomorrow", nil, "due_after"},
+		{"text is trimmed", "q=+milk+", &model.ItemQuery{Text: "milk"}, ""},
+		{"sorted", "sort=-priority,name",
+			&model.ItemQuery{Sort: []model.SortKey{{Field: "priority", Descending: true}, {Field: "name"}}}, ""},
+		{"sort on unknown field", "sort=owner", nil, "sort"},
+		{"sort key twice", "sort=name,-name", nil, "sort"},
+		{"too many sort keys", "sort=id,name,status,list,priority", nil, "sort"},
+		{"fields", "fields=name,status", &model.ItemQuery{Fields: []string{"name", "status"}}, ""},
+		{"unknown field", "fields=password", nil, "fields"},
+		{"paged", "offset=20&count=10&total=1", &model.ItemQuery{Offset: 20, Count: 10, WithTotal: true}, ""},
+		{"largest page", "count=" + strconv.Itoa(maxPageSize), &model.ItemQuery{Count: maxPageSize}, ""},
+		{"page too large", "count=" + strconv.Itoa(maxPageSize+1), nil, "count"},
+		{"empty page", "count=0", nil, "count"},
+		{"count not a number", "count=ten", nil, "count"},
+		{"negative count", "count=-1", nil, "count"},
+		{"offset not a number", "offset=x", nil, "offset"},
+		{"negative offset", "offset=-5", nil, "offset"},
+		{"cursor", "cursor=abc", &model.ItemQuery{Cursor: "abc"}, ""},
+		{"cursor with offset", "cursor=abc&offset=5", nil, "cursor"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := url.ParseQuery(test.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, paramErr := parseItemQuery(values)
+			if test.badParam != "" {
+				if paramErr == nil || paramErr.Param != test.badParam {
+					t.Errorf("got %v, want %s refused", paramErr, test.badParam)
+				}
+				return
+			}
+			if paramErr != nil {
+				t.Fatalf("refused %v", paramErr)
+			}
+			if !reflect.DeepEqual(query, test.want) {
+				t.Errorf("got %+v, want %+v", query, test.want)
+			}
+		})
+	}
+}
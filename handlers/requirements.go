@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"todolist/logging"
+	"todolist/responses"
+	"todolist/utils"
+)
+
+//defaultMaxBodyBytes is the body size limit of routes which
+//don't set one, unless environment.MaxBodyBytes changes it.
+const defaultMaxBodyBytes = 1 << 20
+
+//HeaderRequirement describes one request header. When Prefix
+//is set the value must start with it, when Values is set the
+//value must be one of them.
+type HeaderRequirement struct {
+	Name     string
+	Required bool
+	Prefix   string
+	Values   []string
+}
+
+//Requirements a request must meet before its handler runs.
+//ContentTypes and Charsets only apply to requests carrying a
+//body, the charset parameter may be left out and is then taken
+//to be utf-8, the json default.
+type Requirements struct {
+	Headers      []HeaderRequirement
+	ContentTypes []string
+	Charsets     []string
+	MaxBodyBytes int64
+}
+
+//RequirementFailure names one requirement the request didn't
+//meet, it's sent back to the client in the extra member.
+type RequirementFailure struct {
+	Requirement string `json:"requirement"`
+	Name        string `json:"name,omitempty"`
+	Reason      string `json:"reason"`
+	Expected    string `json:"expected,omitempty"`
+	Found       string `json:"found,omitempty"`
+}
+
+var (
+	bearerHeader   = HeaderRequirement{Name: "Authorization", Required: true, Prefix: "Bearer "}
+	resourceHeader = HeaderRequirement{Name: "X-Resource-Auth"}
+)
+
+func methodHasBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+func hasBody(r *http.Request) bool {
+	return methodHasBody(r.Method) || r.ContentLength > 0
+}
+
+func (header *HeaderRequirement) check(r *http.Request) *RequirementFailure {
+	value := r.Header.Get(header.Name)
+	if value == "" {
+		if !header.Required {
+			return nil
+		}
+		return &RequirementFailure{Requirement: "header", Name: header.Name, Reason: "missing"}
+	}
+	if header.Prefix != "" && !strings.HasPrefix(value, header.Prefix) {
+		//The value isn't echoed back, it may be a credential.
+		return &RequirementFailure{Requirement: "header", Name: header.Name,
+			Reason: "unexpected value", Expected: header.Prefix + "..."}
+	}
+	if len(header.Values) > 0 && !utils.StringSlice(header.Values).Contains(value) {
+		return &RequirementFailure{Requirement: "header", Name: header.Name,
+			Reason: "unexpected value", Expected: strings.Join(header.Values, ", "), Found: value}
+	}
+	return nil
+}
+
+//checkContentType parses Content-Type with its parameters,
+//application/json; charset=UTF-8 matches application/json and
+//the charset utf-8.
+func (reqs *Requirements) checkContentType(r *http.Request) []RequirementFailure {
+	var failures []RequirementFailure
+	if len(reqs.ContentTypes) == 0 || !hasBody(r) {
+		return failures
+	}
+	value := r.Header.Get("Content-Type")
+	if value == "" {
+		return append(failures, RequirementFailure{Requirement: "content-type", Name: "Content-Type",
+			Reason: "missing", Expected: strings.Join(reqs.ContentTypes, ", ")})
+	}
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return append(failures, RequirementFailure{Requirement: "content-type", Name: "Content-Type",
+			Reason: "malformed", Found: value})
+	}
+	if !utils.StringSlice(reqs.ContentTypes).Contains(mediaType) {
+		failures = append(failures, RequirementFailure{Requirement: "content-type", Name: "Content-Type",
+			Reason: "unsupported media type", Expected: strings.Join(reqs.ContentTypes, ", "), Found: mediaType})
+	}
+	charset, ok := params["charset"]
+	if ok && len(reqs.Charsets) > 0 && !utils.StringSlice(reqs.Charsets).Contains(strings.ToLower(charset)) {
+		failures = append(failures, RequirementFailure{Requirement: "charset", Name: "Content-Type",
+			Reason: "unsupported charset", Expected: strings.Join(reqs.Charsets, ", "), Found: charset})
+	}
+	return failures
+}
+
+//Check gives every requirement r fails, along with the status
+//to answer with, 413 for a too large body, 415 for a content
+//type or charset problem and 400 for the rest.
+func (reqs *Requirements) Check(r *http.Request) ([]RequirementFailure, int) {
+	var failures []RequirementFailure
+	status := http.StatusBadRequest
+	for idx := range reqs.Headers {
+		if failure := reqs.Headers[idx].check(r); failure != nil {
+			failures = append(failures, *failure)
+		}
+	}
+	if contentFailures := reqs.checkContentType(r); len(contentFailures) > 0 {
+		failures = append(failures, contentFailures...)
+		status = http.StatusUnsupportedMediaType
+	}
+	if max := reqs.maxBodyBytes(); r.ContentLength > max {
+		failures = append(failures, RequirementFailure{Requirement: "body-size",
+			Reason: "body too large", Expected: fmt.Sprintf("at most %d bytes", max),
+			Found: fmt.Sprintf("%d bytes", r.ContentLength)})
+		status = http.StatusRequestEntityTooLarge
+	}
+	return failures, status
+}
+
+func (reqs *Requirements) maxBodyBytes() int64 {
+	if reqs.MaxBodyBytes > 0 {
+		return reqs.MaxBodyBytes
+	}
+	return configuredMaxBodyBytes()
+}
+
+func writeRequirementFailures(w *http.ResponseWriter, failures []RequirementFailure, status int) {
+	apiCode := API_ERROR_CODE_INVALID_INPUT
+	message := msgRequirementsNotMet
+	switch status {
+	case http.StatusUnsupportedMediaType:
+		apiCode = API_ERROR_CODE_UNSUPPORTED_MEDIA_TYPE
+		message = msgUnsupportedContentType
+	case http.StatusRequestEntityTooLarge:
+		apiCode = API_ERROR_CODE_REQUEST_TOO_LARGE
+		message = msgRequestBodyTooLarge
+	}
+	errors := make([]responses.FieldError, 0, len(failures))
+	for _, failure := range failures {
+		field := failure.Name
+		if field == "" {
+			field = failure.Requirement
+		}
+		errors = append(errors, responses.FieldError{Field: field, Reason: failure.Reason})
+	}
+	response := responses.Response{
+		Status:  status,
+		APICode: apiCode,
+		Message: message,
+		Meta:    map[string]interface{}{"failed": failures},
+		Errors:  errors,
+	}
+	GenericWriteResponse(w, &response)
+}
+
+//Require answers requests not meeting reqs with the list of
+//what failed. Bodies are limited to the size allowed even when
+//the client didn't send a Content-Length.
+func Require(reqs Requirements) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			failures, status := reqs.Check(r)
+			if len(failures) > 0 {
+				logging.FromContext(r.Context()).Infof("Request to %s failed requirements %v", r.URL.Path, failures)
+				writeRequirementFailures(&w, failures, status)
+				return
+			}
+			limit := reqs.maxBodyBytes()
+			r.Body = newLimitedBody(r.Body, limit)
+			ctx := context.WithValue(r.Context(), bodyLimitKey{}, limit)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type pathParamsKey struct{}
+
+//Router dispatches on the path and the HTTP method. Patterns
+//are paths in which a segment of the form {name} matches any
+//single segment, which is then available via PathParam.
+//A path that matches but not for the request method gets a
+//405 along with the Allow header.
+type Router struct {
+	routes []*route
+}
+
+type route struct {
+	segments []string
+	handlers map[string]http.HandlerFunc
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+//Handle registers handler for method on pattern, a pattern
+//can be registered for as many methods as needed.
+func (router *Router) Handle(method, pattern string, handler http.HandlerFunc) {
+	segments := splitPath(pattern)
+	for _, rt := range router.routes {
+		if strings.Join(rt.segments, "/") == strings.Join(segments, "/") {
+			rt.handlers[method] = handler
+			return
+		}
+	}
+	router.routes = append(router.routes, &route{
+		segments: segments,
+		handlers: map[string]http.HandlerFunc{method: handler},
+	})
+}
+
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for idx, segment := range rt.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[idx] == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = segments[idx]
+			continue
+		}
+		if segment != segments[idx] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (rt *route) allowed() string {
+	var methods []string
+	for method := range rt.handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	for _, rt := range router.routes {
+		params, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		handler, ok := rt.handlers[r.Method]
+		if !ok {
+			w.Header().Set("Allow", rt.allowed())
+			GenericResponseWithEC(&w, msgMethodNotSupported,
+				http.StatusMethodNotAllowed, API_ERROR_CODE_GENERIC_ERROR)
+			return
+		}
+		ctx := context.WithValue(r.Context(), pathParamsKey{}, params)
+		handler(w, r.WithContext(ctx))
+		return
+	}
+	GenericResponseWithEC(&w, msgNotFound, http.StatusNotFound, API_ERROR_CODE_GENERIC_ERROR)
+}
+
+//PathParam gives the value of the {name} segment the request
+//path matched, or "" if there's no such segment.
+func PathParam(r *http.Request, name string) string {
+	params, ok := r.Context().Value(pathParamsKey{}).(map[string]string)
+	if !ok {
+		return ""
+	}
+	return params[name]
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"net/http"
+	"todolist/ical"
+	"todolist/model"
+	"todolist/patch"
+	"todolist/webdav"
+)
+
+//Route declares a path, the methods it answers to and the
+//middlewares its handler runs behind, in order. Every route
+//runs behind RecoverPanic, RedirectHTTPS, CheckAPIVersion,
+//AllowMethods and Require, for routes with Requirements, first,
+//and Idempotent last, once the user is known.
+//Routes with Endpoints dispatch further on the path themselves.
+//Doc and Endpoints are what APIDocument is made of.
+//Probe routes report on the process itself, they're served
+//before StartupGate lets the others through.
+//AnonymousIdempotency keeps Idempotency-Key for requests
+//without a user, which only signed in users' requests have
+//otherwise.
+type Route struct {
+	Path                 string
+	Methods              []string
+	Handler              http.Handler
+	Middlewares          []Middleware
+	Requirements         *Requirements
+	Doc                  *Doc
+	Endpoints            []Endpoint
+	Probe                bool
+	AnonymousIdempotency bool
+}
+
+//Endpoint is a method and path pattern served by a Router.
+//Requirements are checked after those of the route, they may
+//narrow down its content types for instance.
+type Endpoint struct {
+	Method       string
+	Pattern      string
+	Handler      http.HandlerFunc
+	Requirements *Requirements
+	Doc          Doc
+}
+
+//Doc describes an operation for the OpenAPI document. Body is
+//a value of the type the request body is decoded into, nil for
+//operations without a body.
+type Doc struct {
+	Summary     string
+	Description string
+	Body        interface{}
+	//Bodies are the types of bodies sent as the content types
+	//they're keyed by, if it's not Body.
+	Bodies map[string]interface{}
+	Query  []QueryParam
+	//Status is the status of a successful response, 200 if
+	//it's not set.
+	Status int
+	//Produces is the content type of a successful response
+	//which isn't a Response, or several separated by commas.
+	Produces string
+}
+
+type QueryParam struct {
+	Name        string
+	Description string
+}
+
+var (
+	get  = []string{http.MethodGet}
+	post = []string{http.MethodPost}
+
+	jsonBody = Requirements{
+		ContentTypes: []string{"application/json"},
+		Charsets:     []string{"utf-8"},
+	}
+	tptVerifyRequest = Requirements{
+		Headers:      []HeaderRequirement{bearerHeader, {Name: "X-Resource-Auth", Required: true}},
+		ContentTypes: jsonBody.ContentTypes,
+		Charsets:     jsonBody.Charsets,
+	}
+	authenticatedRequest = Requirements{
+		Headers:      []HeaderRequirement{bearerHeader, resourceHeader},
+		ContentTypes: jsonBody.ContentTypes,
+		Charsets:     jsonBody.Charsets,
+	}
+	//patchBody is for PATCH, which also takes patch documents.
+	patchBody = Requirements{
+		ContentTypes: []string{"application/json", patch.MergeMediaType, patch.MediaType},
+		Charsets:     jsonBody.Charsets,
+	}
+	//The route takes everything its endpoints do, they narrow
+	//it down.
+	itemsRequest = Requirements{
+		Headers:      authenticatedRequest.Headers,
+		ContentTypes: patchBody.ContentTypes,
+		Charsets:     jsonBody.Charsets,
+	}
+	//feedRequest takes the calendar feed token in the query in
+	//place of the bearer token.
+	feedRequest = Requirements{
+		Headers: []HeaderRequirement{{Name: "Authorization", Prefix: "Bearer "}, resourceHeader},
+	}
+	calendarBody = Requirements{
+		Headers:      authenticatedRequest.Headers,
+		ContentTypes: []string{ical.MediaType},
+		Charsets:     jsonBody.Charsets,
+	}
+	//importRequest takes the files of other tools, which are
+	//larger than requests usually are.
+	importRequest = Requirements{
+		Headers:      authenticatedRequest.Headers,
+		ContentTypes: []string{"application/json", "text/csv", "text/plain"},
+		Charsets:     jsonBody.Charsets,
+		MaxBodyBytes: importMaxBodyBytes,
+	}
+	//davRequest allows Basic authentication, and the bodies of
+	//PROPFIND, REPORT and PUT.
+	davRequest = Requirements{
+		Headers:      []HeaderRequirement{{Name: "Authorization"}, resourceHeader},
+		ContentTypes: []string{webdav.MediaType, "text/xml", ical.MediaType},
+		Charsets:     jsonBody.Charsets,
+	}
+	//authenticated routes get the Principal and a database
+	//connection in the request context.
+	authenticated = []Middleware{Authenticate, WithDatabase, UserLocale}
+)
+
+func Routes() []Route {
+	return []Route{
+		{Path: "/login", Methods: post, Handler: http.HandlerFunc(Login),
+			Requirements: &jsonBody, Middlewares: []Middleware{WithDatabase},
+			Doc: &Doc{Summary: "Log in with user id and password",
+				Description: "The token is sent back in the Authorization header.",
+				Body:        model.User{}}},
+		{Path: "/tptverify", Methods: post, Handler: http.HandlerFunc(TPTVerify),
+			Requirements: &tptVerifyRequest, Middlewares: []Middleware{WithDatabase},
+			Doc: &Doc{Summary: "Log in with a third party token",
+				Description: "X-Resource-Auth names the provider, users new to us are registered.",
+				Body:        deviceInfo{}}},
+		{Path: "/register", Methods: post, Handler: http.HandlerFunc(Register),
+			Requirements: &jsonBody, Middlewares: []Middleware{WithDatabase}, AnonymousIdempotency: true,
+			Doc: &Doc{Summary: "Register a user", Body: model.User{}}},
+		{Path: "/user", Handler: http.HandlerFunc(User)},
+		{Path: "/user/data/", Handler: EndpointRouter(DataRequestEndpoints()), Endpoints: DataRequestEndpoints(),
+			Requirements: &authenticatedRequest, Middlewares: authenticated},
+		//Apps declaring version 2 get the REST handlers' responses,
+		//the created item or a cursor paged listing, on the old paths.
+		{Path: "/post/add", Methods: post, Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Handler: Versioned(map[int]http.HandlerFunc{
+				LegacyAPIVersion: PostAdd,
+				LatestAPIVersion: ItemCreate,
+			}),
+			Doc: &Doc{Summary: "Add an item", Body: model.TodoItem{},
+				Description: "Version 2 answers like POST /v2/items."}},
+		{Path: "/post/remove", Methods: post, Handler: http.HandlerFunc(PostRemove),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Remove an item, or stop sharing one",
+				Description: "Items shared with the user are only removed from what's shared with them.",
+				Body:        itemIDRequest{}}},
+		{Path: "/post/edit", Methods: post, Handler: http.HandlerFunc(PostEdit),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Replace an item", Body: model.TodoItem{}}},
+		{Path: "/post/get", Methods: get, Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Handler: Versioned(map[int]http.HandlerFunc{
+				LegacyAPIVersion: PostGet,
+				LatestAPIVersion: ItemList,
+			}),
+			Doc: &Doc{Summary: "List items",
+				Description: "Version 2 answers like GET /v2/items.",
+				Query: append([]QueryParam{{Name: "postid", Description: "only the item with this id"}},
+					itemQueryParams...)}},
+		{Path: "/post/batch", Methods: post, Handler: http.HandlerFunc(PostBatch),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Add, edit and remove items in one request",
+				Description: "Operations are applied in order, with atomic all or none of them.",
+				Body:        batchRequest{}}},
+		{Path: "/post/search", Methods: get, Handler: http.HandlerFunc(PostSearch),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Full text search over items",
+				Query: []QueryParam{
+					{Name: "q", Description: "the search text"},
+					{Name: "count", Description: "the most results to send back"},
+				}}},
+		{Path: "/workflow", Methods: get, Handler: http.HandlerFunc(Workflow),
+			Doc: &Doc{Summary: "Item states, transitions and priorities"}},
+		{Path: "/tags", Methods: get, Handler: http.HandlerFunc(TagList),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "List the tag catalog"}},
+		{Path: "/tags/add", Methods: post, Handler: http.HandlerFunc(TagAdd),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Add a tag", Body: model.Tag{}}},
+		{Path: "/tags/edit", Methods: post, Handler: http.HandlerFunc(TagEdit),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Change the color of a tag", Body: model.Tag{}}},
+		{Path: "/tags/remove", Methods: post, Handler: http.HandlerFunc(TagRemove),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Remove a tag from the catalog and all items", Body: tagNameRequest{}}},
+		{Path: "/tags/rename", Methods: post, Handler: http.HandlerFunc(TagRename),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Rename a tag", Body: tagRenameRequest{}}},
+		{Path: "/tags/merge", Methods: post, Handler: http.HandlerFunc(TagMerge),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Merge tags into another", Body: tagMergeRequest{}}},
+		//The router answers 405 per path itself.
+		{Path: "/v2/", Handler: V2Router(), Endpoints: V2Endpoints(),
+			Requirements: &itemsRequest, Middlewares: authenticated},
+		//Streams outlive requests, they don't hold a database
+		//connection.
+		{Path: "/events", Methods: get, Handler: http.HandlerFunc(Events),
+			Requirements: &authenticatedRequest, Middlewares: []Middleware{Authenticate},
+			Doc: &Doc{Summary: "Stream changes to items",
+				Description: "Server-sent events, or a WebSocket when the request asks for an upgrade, " +
+					"of items the user owns or has been shared being created, updated, deleted " +
+					"and shared. Reconnecting clients send Last-Event-ID to get what they missed.",
+				Query:    []QueryParam{{Name: "last_event_id", Description: "for clients which can't send Last-Event-ID"}},
+				Produces: "text/event-stream"}},
+		//Calendar apps subscribe to the feed URL, which has a token
+		//in place of the Authorization header.
+		{Path: "/export/ics", Methods: get, Handler: http.HandlerFunc(ExportICS),
+			Requirements: &feedRequest, Middlewares: []Middleware{WithDatabase, AuthenticateFeed, UserLocale},
+			Doc: &Doc{Summary: "Export the user's items as an iCalendar of VTODOs",
+				Query:    []QueryParam{{Name: "token", Description: "the calendar feed token, for requests without Authorization"}},
+				Produces: ical.MediaType}},
+		{Path: "/export/ics/token", Methods: post, Handler: http.HandlerFunc(ICSFeedToken),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Create or revoke the calendar feed URL",
+				Description: "A new URL stops the one before from working.",
+				Body:        feedTokenRequest{}}},
+		{Path: "/import/ics", Methods: post, Handler: http.HandlerFunc(ImportICS),
+			Requirements: &calendarBody, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Import the VTODOs and VEVENTs of an iCalendar as items",
+				Description: "Those whose UID is already an item's id are reported as duplicates and left alone.",
+				Body:        "",
+				Query:       []QueryParam{dryRunParam}}},
+		{Path: "/export", Methods: get, Handler: http.HandlerFunc(Export),
+			Requirements: &authenticatedRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Export the user's items as JSON, CSV or todo.txt",
+				Description: "The items are those GET /v2/items selects with the same parameters, " +
+					"streamed as they're read.",
+				Query:    append(append([]QueryParam{}, transferParams...), itemQueryParams...),
+				Produces: "application/json,text/csv,text/plain"}},
+		{Path: "/import", Methods: post, Handler: http.HandlerFunc(Import),
+			Requirements: &importRequest, Middlewares: authenticated,
+			Doc: &Doc{Summary: "Import items from JSON, CSV or todo.txt",
+				Description: "Items whose id is already an item's are reported as duplicates and left alone. " +
+					"The format is taken from the content type unless format is given.",
+				Body:   []model.TodoItem{},
+				Bodies: map[string]interface{}{"text/csv": "", "text/plain": ""},
+				Query:  append(append([]QueryParam{}, transferParams...), dryRunParam)}},
+		//CalDAV clients, which answer to methods OpenAPI has no
+		//words for, start from the well known URL.
+		{Path: "/.well-known/caldav", Handler: http.RedirectHandler(davPrefix, http.StatusMovedPermanently)},
+		{Path: davPrefix, Handler: http.HandlerFunc(CalDAV),
+			Requirements: &davRequest, Middlewares: []Middleware{WithDatabase, AuthenticateDAV, UserLocale}},
+		{Path: "/openapi.json", Methods: get, Handler: http.HandlerFunc(OpenAPI),
+			Doc: &Doc{Summary: "This document"}},
+		{Path: "/healthz", Methods: get, Handler: http.HandlerFunc(Healthz), Probe: true,
+			Doc: &Doc{Summary: "Whether the process is up, for liveness probes"}},
+		{Path: "/readyz", Methods: get, Handler: http.HandlerFunc(Readyz), Probe: true,
+			Doc: &Doc{Summary: "Whether the process can serve requests",
+				Description: "Checks the secrets, MongoDB and Google's certificates and reports the status, " +
+					"latency_ms and, for those which failed, the reason of each in extra.checks, the status is 503 " +
+					"while any fails. Reasons are not_configured, timeout or unavailable. " +
+					"Results are reused for a few seconds."}},
+		{Path: "/", Handler: http.HandlerFunc(GenericNotImplemented)},
+	}
+}
+
+//requirements are those of the route, with the content types
+//of the endpoint if it has its own.
+func (endpoint *Endpoint) requirements(route *Requirements) *Requirements {
+	if endpoint.Requirements == nil || len(endpoint.Requirements.ContentTypes) == 0 {
+		return route
+	}
+	reqs := Requirements{ContentTypes: endpoint.Requirements.ContentTypes}
+	if route != nil {
+		reqs.Headers = route.Headers
+	}
+	return &reqs
+}
+
+//Chain builds the middleware chain of the route.
+func (route *Route) Chain() http.Handler {
+	middlewares := []Middleware{LogRequests(route.Path), Measure(route.Path, route.Methods...),
+		Trace(route.Path), Negotiate, RecoverPanic}
+	if !route.Probe {
+		middlewares = append(middlewares, StartupGate)
+	}
+	middlewares = append(middlewares, RedirectHTTPS, CheckAPIVersion)
+	if len(route.Methods) > 0 {
+		middlewares = append(middlewares, AllowMethods(route.Methods...))
+	}
+	if route.Requirements != nil {
+		middlewares = append(middlewares, Require(*route.Requirements))
+	}
+	middlewares = append(middlewares, route.Middlewares...)
+	middlewares = append(middlewares, Idempotent(route.AnonymousIdempotency))
+	return Chain(route.Handler, middlewares...)
+}
+
+func RegisterRoutes(mux *http.ServeMux) {
+	for _, route := range Routes() {
+		mux.Handle(route.Path, route.Chain())
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/responses"
+)
+
+//TagList returns the tag catalog of the user.
+func TagList(w http.ResponseWriter, r *http.Request) {
+	tags, err := model.GetTagsForOwner(r.Context(), RequestDatabase(r), RequestUserID(r))
+	if err != nil {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		APICode: API_ERROR_CODE_OK,
+		Message: msgTagsFetchComplete,
+		Meta:    map[string]interface{}{"count": len(tags), "tags": tags},
+	}
+	GenericWriteResponse(&w, &resp)
+}
+
+type tagNameRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type tagRenameRequest struct {
+	From string `json:"from" validate:"required"`
+	To   string `json:"to" validate:"required"`
+}
+
+type tagMergeRequest struct {
+	From []string `json:"from" validate:"required"`
+	Into string   `json:"into" validate:"required"`
+}
+
+//JSON body contains the tag name and an optional color.
+func TagAdd(w http.ResponseWriter, r *http.Request) {
+	tagAddOrModify(&w, r, false)
+}
+
+//JSON body contains the tag name and the new color.
+func TagEdit(w http.ResponseWriter, r *http.Request) {
+	tagAddOrModify(&w, r, true)
+}
+
+func tagAddOrModify(w *http.ResponseWriter, r *http.Request, modify bool) {
+	expected := model.Tag{}
+	if !decodeJSONBody(w, r, &expected) {
+		return
+	}
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	name, err := model.NormalizeTagName(expected.Name)
+	if err != nil {
+		writeFieldErrors(w, msgInvalidTagName, responses.FieldError{Field: "name", Reason: err.Error()})
+		return
+	}
+	if expected.Color != "" && !model.ValidTagColor(expected.Color) {
+		writeFieldErrors(w, msgInvalidTagColor,
+			responses.FieldError{Field: "color", Reason: "must be of the form #rrggbb"})
+		return
+	}
+	tag := model.Tag{Owner: userID, Name: name, Color: expected.Color}
+	if modify {
+		if tag.Color == "" || !tag.Modify(r.Context(), connection) {
+			GenericResponseWithEC(w, msgTagNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+			return
+		}
+		GenericResponse(w, msgTagModified, http.StatusOK)
+		return
+	}
+	if !tag.Add(r.Context(), connection) {
+		GenericResponseWithEC(w, msgTagAlreadyExists, http.StatusConflict, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	GenericResponse(w, msgTagAdded, http.StatusOK)
+}
+
+//JSON body contains the name of the tag to remove, the
+//tag is removed from all the items of the user as well.
+func TagRemove(w http.ResponseWriter, r *http.Request) {
+	expected := tagNameRequest{}
+	if !decodeJSONBody(&w, r, &expected) {
+		return
+	}
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	tag := model.Tag{Owner: userID, Name: expected.Name}
+	if err := tag.Remove(r.Context(), connection); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't remove tag %s for user %s: %v", expected.Name, userID, err)
+		GenericResponseWithEC(&w, msgTagRemoveFailed, http.StatusBadRequest, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	GenericResponse(&w, msgTagRemoved, http.StatusOK)
+}
+
+//JSON body contains from and to, every item tagged with
+//from is tagged with to instead.
+func TagRename(w http.ResponseWriter, r *http.Request) {
+	expected := tagRenameRequest{}
+	if !decodeJSONBody(&w, r, &expected) {
+		return
+	}
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	to, err := model.NormalizeTagName(expected.To)
+	if err != nil {
+		writeFieldErrors(&w, msgInvalidTagName, responses.FieldError{Field: "to", Reason: err.Error()})
+		return
+	}
+	if err = model.RenameTag(r.Context(), connection, userID, expected.From, to); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't rename tag %s to %s for user %s: %v", expected.From, to, userID, err)
+		GenericResponseWithEC(&w, msgTagRenameFailed, http.StatusBadRequest, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	GenericResponse(&w, msgTagRenamed, http.StatusOK)
+}
+
+//JSON body contains a list of tags in from and an existing
+//tag into, which replaces all of them.
+func TagMerge(w http.ResponseWriter, r *http.Request) {
+	expected := tagMergeRequest{}
+	if !decodeJSONBody(&w, r, &expected) {
+		return
+	}
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	if err := model.MergeTags(r.Context(), connection, userID, expected.From, expected.Into); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't merge tags %v into %s for user %s: %v", expected.From, expected.Into, userID, err)
+		GenericResponseWithEC(&w, msgTagMergeFailed, http.StatusBadRequest, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	GenericResponse(&w, msgTagsMerged, http.StatusOK)
+}
@@ -6,13 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"todolist/environment"
+	"todolist/logging"
+	"todolist/metrics"
 	"todolist/model"
 
 	"github.com/dgrijalva/jwt-go"
@@ -21,6 +22,7 @@ import (
 const (
 	maxExpirationMins    = time.Minute * 15
 	googleCertificateURL = "https://www.googleapis.com/oauth2/v1/certs"
+	certFetchTimeout     = 10 * time.Second
 )
 
 type googleTokenVerifier struct {
@@ -31,6 +33,17 @@ type googleTokenVerifier struct {
 
 var googleToken googleTokenVerifier
 
+//certClient gives up on Google instead of holding the lock on
+//the certificates for as long as it takes.
+var certClient = &http.Client{Timeout: certFetchTimeout}
+
+var (
+	certCacheTotal = metrics.NewCounter("todolist_google_cert_cache_total",
+		"Lookups of Google's signing certificates, by whether they were cached.", "result")
+	certRefreshesTotal = metrics.NewCounter("todolist_google_cert_refreshes_total",
+		"Fetches of Google's signing certificates, by result.", "result")
+)
+
 func init() {
 	googleToken = googleTokenVerifier{}
 }
@@ -58,8 +71,16 @@ type GoogleClaim struct {
 	Locale        string `json:"locale,omitempty"`
 }
 
-func getSigningKey() string {
-	return base64.StdEncoding.EncodeToString([]byte(environment.GetAppTokenSecret()))
+//ErrNoSecret is returned instead of signing or verifying tokens
+//with an empty key.
+var ErrNoSecret = errors.New(environment.AppTokenSecret + " isn't set")
+
+func getSigningKey() (string, error) {
+	secret := environment.GetAppTokenSecret()
+	if secret == "" {
+		return "", ErrNoSecret
+	}
+	return base64.StdEncoding.EncodeToString([]byte(secret)), nil
 }
 
 func GenerateTokenWithTimeout(user *model.User, timeout int64, tokenType model.LoginType) (string, error) {
@@ -73,18 +94,22 @@ func GenerateTokenWithTimeout(user *model.User, timeout int64, tokenType model.L
 			Subject:   user.ID,
 		},
 	}
+	key, err := getSigningKey()
+	if err != nil {
+		logging.Errorf("Not signing token for user %s: %v", user.ID, err)
+		return "", err
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, appClaim)
-	signedToken, err := token.SignedString([]byte(getSigningKey()))
+	signedToken, err := token.SignedString([]byte(key))
 	if err != nil {
 		return "", err
 	}
-	log.Printf("Generated %s for user %s", signedToken, user.ID)
+	logging.Debugf("Generated token for user %s", user.ID)
 	return signedToken, nil
 }
 
 func GetBearerToken(r *http.Request) string {
 	var bearerToken = ""
-	log.Printf("request headers for %s are %v \n", r.RequestURI, r.Header)
 	if r.Header != nil {
 		headerValues := r.Header["Authorization"]
 		if len(headerValues) > 0 {
@@ -94,7 +119,6 @@ func GetBearerToken(r *http.Request) string {
 			}
 		}
 	}
-	log.Printf("Bearer token is %s\n", bearerToken)
 	return bearerToken
 }
 
@@ -104,67 +128,97 @@ func GenerateToken(user *model.User, loginType model.LoginType) (string, error)
 
 func GetUserClaims(tokenString string) (*AppClaim, error) {
 	appClaim := AppClaim{}
-
+	key, err := getSigningKey()
+	if err != nil {
+		logging.Errorf("Not verifying token: %v", err)
+		return nil, err
+	}
 	token, err := jwt.ParseWithClaims(tokenString, &appClaim, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
 		}
-		return ([]byte(getSigningKey())), nil
+		return ([]byte(key)), nil
 	})
 	if err != nil {
-		log.Printf("Error validating token")
+		logging.Infof("Token isn't valid: %v", err)
 		return nil, err
 	}
 	if claims, ok := token.Claims.(*AppClaim); ok && token.Valid {
-		log.Printf("Claims are valid %v", claims)
+		logging.Debugf("Claims of user %s are valid", claims.Id)
 		return &appClaim, nil
 	}
 	return nil, nil
 }
 
-func GetGoogleClaims(tokenString string) (*GoogleClaim, error) {
-	googleClaim := GoogleClaim{}
+//LoadGoogleCerts makes sure Google's signing certificates are
+//at hand, they're fetched unless they're cached.
+func LoadGoogleCerts() error {
 	googleToken.Lock()
 	defer googleToken.Unlock()
-check_again:
-	if googleToken.certs == nil || len(googleToken.certs) == 0 {
-		resp, err := http.Get(googleCertificateURL)
-		if err != nil {
-			log.Printf("Error connecting to %s", googleCertificateURL)
-			return nil, err
-		}
-		defer resp.Body.Close()
-		bytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading from %s", googleCertificateURL)
-			return nil, err
-		}
-		err = json.Unmarshal(bytes, &googleToken.certs)
-		if err != nil {
-			log.Printf("Unable to unmarshal from %s", googleCertificateURL)
-			return nil, err
-		}
-		for _, value := range resp.Header.Values("Cache-Control") {
-			if strings.Contains(value, "max-age") {
-				allValues := strings.Split(value, ",")
-				var maxAgeVal string
-				for _, val := range allValues {
-					if strings.Contains(val, "max-age") {
-						maxAgeVal = val
-						break
-					}
-				}
-				val, err := strconv.ParseInt(strings.Split(maxAgeVal, "=")[1], 10, 64)
-				if err != nil {
-					val = 0
+	return googleToken.load()
+}
+
+//load fetches the certificates once those cached have expired,
+//the caller holds the lock.
+func (verifier *googleTokenVerifier) load() error {
+	if len(verifier.certs) > 0 && verifier.timeout > time.Now().Unix() {
+		certCacheTotal.Inc("hit")
+		return nil
+	}
+	certCacheTotal.Inc("miss")
+	verifier.certs = nil
+	resp, err := certClient.Get(googleCertificateURL)
+	if err != nil {
+		logging.Errorf("Error connecting to %s", googleCertificateURL)
+		certRefreshesTotal.Inc("failure")
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logging.Errorf("Error fetching %s: %s", googleCertificateURL, resp.Status)
+		certRefreshesTotal.Inc("failure")
+		return fmt.Errorf("fetching google certificates: %s", resp.Status)
+	}
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logging.Errorf("Error reading from %s", googleCertificateURL)
+		certRefreshesTotal.Inc("failure")
+		return err
+	}
+	err = json.Unmarshal(bytes, &verifier.certs)
+	if err != nil {
+		logging.Errorf("Unable to unmarshal from %s", googleCertificateURL)
+		certRefreshesTotal.Inc("failure")
+		return err
+	}
+	certRefreshesTotal.Inc("success")
+	for _, value := range resp.Header.Values("Cache-Control") {
+		if strings.Contains(value, "max-age") {
+			allValues := strings.Split(value, ",")
+			var maxAgeVal string
+			for _, val := range allValues {
+				if strings.Contains(val, "max-age") {
+					maxAgeVal = val
+					break
 				}
-				log.Printf("Setting google's cert timeout value to %d seconds\n", val)
-				googleToken.timeout = time.Now().Unix() + val
 			}
+			val, err := strconv.ParseInt(strings.Split(maxAgeVal, "=")[1], 10, 64)
+			if err != nil {
+				val = 0
+			}
+			logging.Infof("Setting google's cert timeout value to %d seconds", val)
+			verifier.timeout = time.Now().Unix() + val
 		}
-	} else if googleToken.timeout <= time.Now().Unix() {
-		googleToken.certs = nil
-		goto check_again
+	}
+	return nil
+}
+
+func GetGoogleClaims(tokenString string) (*GoogleClaim, error) {
+	googleClaim := GoogleClaim{}
+	googleToken.Lock()
+	defer googleToken.Unlock()
+	if err := googleToken.load(); err != nil {
+		return nil, err
 	}
 	//ParseWithClaims requires either a signing key or
 	//the public key. Since we're using the PEM format
@@ -184,7 +238,7 @@ check_again:
 			if ok {
 				publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicCert))
 				if err != nil {
-					log.Printf("Not a valid RSA Key %s\n", publicCert)
+					logging.Warnf("Not a valid RSA Key %s", publicCert)
 					return nil, errors.New("invalid RSA key found")
 				}
 				return publicKey, nil
@@ -193,12 +247,11 @@ check_again:
 				token.Header["kid"].(string))
 		})
 	if err != nil {
-		log.Printf("error parsing %v\n", err)
-		log.Printf("token = %v\n", token)
+		logging.Infof("Google token isn't valid: %v", err)
 		return nil, errors.New("invalid google token")
 	}
 	if claims, ok := token.Claims.(*GoogleClaim); ok && token.Valid {
-		log.Printf("Claims are valid %v", claims)
+		logging.Debugf("Google claims of %s are valid", claims.Subject)
 		return &googleClaim, nil
 	}
 	return nil, errors.New("invalid google token")
@@ -0,0 +1,811 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/responses"
+	"todolist/utils"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//Formats of /export and /import.
+const (
+	formatJSON    = "json"
+	formatCSV     = "csv"
+	formatTodoTxt = "todotxt"
+)
+
+const (
+	//importMaxBodyBytes is what /import takes, files moved over
+	//from other tools are larger than requests usually are.
+	importMaxBodyBytes = 32 << 20
+	//exportFlushItems are written between flushes of exports.
+	exportFlushItems = 100
+	maxTodoTxtLine   = 64 << 10
+)
+
+var transferContentTypes = map[string]string{
+	formatJSON:    "application/json",
+	formatCSV:     "text/csv",
+	formatTodoTxt: "text/plain",
+}
+
+var transferFileNames = map[string]string{
+	formatJSON:    "todolist.json",
+	formatCSV:     "todolist.csv",
+	formatTodoTxt: "todo.txt",
+}
+
+//csvFields are the item fields CSV columns hold, in the order
+//exports have them.
+var csvFields = []string{"id", "name", "status", "priority", "list", "tags", "start_time", "end_time",
+	"recurrence", "completed_at", "completed_by", "content"}
+
+//todo.txt has priorities A to Z, A is the highest.
+var todoTxtPriorities = map[int]string{
+	model.PriorityUrgent: "A",
+	model.PriorityHigh:   "B",
+	model.PriorityMedium: "C",
+	model.PriorityLow:    "D",
+}
+
+//transferParams document the parameters of the formats.
+var transferParams = []QueryParam{
+	{Name: "format", Description: "json, csv or todotxt"},
+	{Name: "columns", Description: "CSV columns as field or field:Header, comma separated"},
+	{Name: "separator", Description: "the CSV separator, a comma if it's not given"},
+}
+
+var dryRunParam = QueryParam{Name: "dry_run", Description: "1 to only check what would be imported"}
+
+//csvColumn is a column of a CSV file and the item field it
+//holds.
+type csvColumn struct {
+	Field  string
+	Header string
+}
+
+//parseCSVColumns reads columns, each field or field:Header, the
+//header being the field if it's left out.
+func parseCSVColumns(values url.Values) ([]csvColumn, *invalidParamError) {
+	var columns []csvColumn
+	for _, spec := range listParam(values, "columns") {
+		column := csvColumn{Field: spec, Header: spec}
+		if idx := strings.Index(spec, ":"); idx >= 0 {
+			column = csvColumn{Field: strings.TrimSpace(spec[:idx]), Header: strings.TrimSpace(spec[idx+1:])}
+		}
+		if !utils.StringSlice(csvFields).Contains(column.Field) {
+			return nil, &invalidParamError{"columns", fmt.Sprintf("unknown field %s", column.Field)}
+		}
+		if column.Header == "" {
+			return nil, &invalidParamError{"columns", fmt.Sprintf("field %s has an empty header", column.Field)}
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func csvSeparator(values url.Values) (rune, *invalidParamError) {
+	value := values.Get("separator")
+	if value == "" {
+		return ',', nil
+	}
+	separator, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || separator == utf8.RuneError || strings.ContainsRune("\"\r\n", separator) {
+		return 0, &invalidParamError{"separator", "must be one character, not a quote or a line break"}
+	}
+	return separator, nil
+}
+
+//contentID is the id of items imported without one, made from
+//what they were read from so that importing them again finds
+//them.
+func contentID(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:16])
+}
+
+//transferTime checks an imported time, a date or an RFC 3339
+//time which is kept in UTC.
+func transferTime(value string) (string, bool) {
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return value, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format(time.RFC3339), true
+	}
+	return "", false
+}
+
+//parsePriority takes a priority by number or by name.
+func parsePriority(value string) (int, bool) {
+	if priority, err := strconv.Atoi(value); err == nil {
+		return priority, model.ValidPriority(priority)
+	}
+	for priority, name := range model.PriorityNames {
+		if strings.EqualFold(name, value) {
+			return priority, true
+		}
+	}
+	return 0, false
+}
+
+func recordError(field, reason string) error {
+	return newFieldError(API_ERROR_CODE_INVALID_INPUT, msgRecordCantBeImported, field, reason)
+}
+
+func csvValue(item *model.TodoItem, field string) string {
+	switch field {
+	case "id":
+		return item.ID
+	case "name":
+		return item.Name
+	case "status":
+		return item.Status
+	case "priority":
+		return model.PriorityNames[item.Priority]
+	case "list":
+		return item.List
+	case "tags":
+		return strings.Join(item.Tags, ",")
+	case "start_time":
+		return item.StartTime
+	case "end_time":
+		return item.EndTime
+	case "recurrence":
+		return item.Recurrence
+	case "completed_at":
+		return item.CompletedAt
+	case "completed_by":
+		return item.CompletedBy
+	case "content":
+		if len(item.Content) == 0 {
+			return ""
+		}
+		data, err := json.Marshal(item.Content)
+		if err != nil {
+			logging.Errorf("Couldn't marshal content of item %s: %v", item.ID, err)
+		}
+		return string(data)
+	}
+	return ""
+}
+
+//setCSVValue sets field of item from a CSV cell. Columns which
+//aren't a field go into the content under their header, the
+//completion is left to the workflow.
+func setCSVValue(item *model.TodoItem, field, header, value string) error {
+	switch field {
+	case "":
+		if item.Content == nil {
+			item.Content = map[string]interface{}{}
+		}
+		item.Content[header] = value
+	case "id":
+		item.ID = value
+	case "name":
+		item.Name = value
+	case "status":
+		item.Status = value
+	case "priority":
+		priority, ok := parsePriority(value)
+		if !ok {
+			return recordError(header, "unknown priority "+value)
+		}
+		item.Priority = priority
+	case "list":
+		item.List = value
+	case "tags":
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+	case "start_time", "end_time":
+		t, ok := transferTime(value)
+		if !ok {
+			return recordError(header, "must be a date or an RFC 3339 time")
+		}
+		if field == "start_time" {
+			item.StartTime = t
+		} else {
+			item.EndTime = t
+		}
+	case "recurrence":
+		item.Recurrence = value
+	case "content":
+		content := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(value), &content); err != nil {
+			return recordError(header, "must be a json object")
+		}
+		if item.Content == nil {
+			item.Content = map[string]interface{}{}
+		}
+		for key, member := range content {
+			item.Content[key] = member
+		}
+	}
+	return nil
+}
+
+//todoTxtLine is item as a todo.txt task. The list is the
+//project and the tags are contexts, spaces in them become
+//underscores.
+func todoTxtLine(item *model.TodoItem) string {
+	wf := model.GetWorkflow()
+	var parts []string
+	priority, hasPriority := todoTxtPriorities[item.Priority]
+	if item.Status == wf.Done {
+		parts = append(parts, "x")
+		if t, err := time.Parse(time.RFC3339, item.CompletedAt); err == nil {
+			parts = append(parts, t.Format("2006-01-02"))
+		}
+	} else if hasPriority {
+		parts = append(parts, "("+priority+")")
+	}
+	parts = append(parts, strings.Fields(item.Name)...)
+	if item.List != "" {
+		parts = append(parts, "+"+strings.Join(strings.Fields(item.List), "_"))
+	}
+	for _, tag := range item.Tags {
+		parts = append(parts, "@"+strings.Join(strings.Fields(tag), "_"))
+	}
+	if item.Status == wf.Done && hasPriority {
+		parts = append(parts, "pri:"+priority)
+	}
+	if item.StartTime != "" {
+		parts = append(parts, "t:"+item.StartTime)
+	}
+	if item.EndTime != "" {
+		parts = append(parts, "due:"+item.EndTime)
+	}
+	if item.Status != "" && item.Status != wf.Initial && item.Status != wf.Done {
+		parts = append(parts, "status:"+item.Status)
+	}
+	if item.Recurrence != "" {
+		parts = append(parts, "rrule:"+item.Recurrence)
+	}
+	parts = append(parts, "id:"+item.ID)
+	return strings.Join(parts, " ")
+}
+
+func isTodoTxtDate(word string) bool {
+	_, err := time.Parse("2006-01-02", word)
+	return err == nil
+}
+
+func todoTxtPriority(letter string) int {
+	for priority, name := range todoTxtPriorities {
+		if name == letter {
+			return priority
+		}
+	}
+	return model.PriorityLow
+}
+
+//todoTxtItem reads a todo.txt task. Its first project is the
+//list, contexts are tags, and key:value pairs we have no field
+//for go into the content.
+func todoTxtItem(line string) (*model.TodoItem, error) {
+	item := &model.TodoItem{}
+	words := strings.Fields(line)
+	if len(words) > 0 && words[0] == "x" {
+		item.Status = model.GetWorkflow().Done
+		words = words[1:]
+		//The completion date and the creation date, the workflow
+		//sets when it was completed.
+		for count := 0; count < 2 && len(words) > 0 && isTodoTxtDate(words[0]); count++ {
+			words = words[1:]
+		}
+	} else {
+		if len(words) > 0 && len(words[0]) == 3 && words[0][0] == '(' && words[0][2] == ')' &&
+			words[0][1] >= 'A' && words[0][1] <= 'Z' {
+			item.Priority = todoTxtPriority(words[0][1:2])
+			words = words[1:]
+		}
+		if len(words) > 0 && isTodoTxtDate(words[0]) {
+			words = words[1:]
+		}
+	}
+	var name []string
+	for _, word := range words {
+		colon := strings.Index(word, ":")
+		switch {
+		case len(word) > 1 && word[0] == '+' && item.List == "":
+			item.List = word[1:]
+		case len(word) > 1 && word[0] == '@':
+			item.Tags = append(item.Tags, word[1:])
+		case colon > 0 && colon < len(word)-1 && !strings.HasPrefix(word[colon+1:], "//"):
+			key, value := word[:colon], word[colon+1:]
+			switch key {
+			case "id":
+				item.ID = value
+			case "due", "t":
+				t, ok := transferTime(value)
+				if !ok {
+					return item, recordError(key, "must be a date or an RFC 3339 time")
+				}
+				if key == "due" {
+					item.EndTime = t
+				} else {
+					item.StartTime = t
+				}
+			case "pri":
+				item.Priority = todoTxtPriority(strings.ToUpper(value))
+			case "status":
+				item.Status = value
+			case "rrule":
+				item.Recurrence = value
+			default:
+				if item.Content == nil {
+					item.Content = map[string]interface{}{}
+				}
+				item.Content[key] = value
+			}
+		default:
+			name = append(name, word)
+		}
+	}
+	item.Name = strings.Join(name, " ")
+	if item.ID == "" {
+		item.ID = contentID(line)
+	}
+	return item, nil
+}
+
+//itemEncoder writes the items of an export one at a time.
+type itemEncoder interface {
+	begin() error
+	encode(item *model.TodoItem) error
+	end() error
+}
+
+type jsonItemEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (enc *jsonItemEncoder) begin() error {
+	_, err := io.WriteString(enc.w, "[")
+	return err
+}
+
+func (enc *jsonItemEncoder) encode(item *model.TodoItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	separator := "\n"
+	if enc.count > 0 {
+		separator = ",\n"
+	}
+	enc.count++
+	if _, err = io.WriteString(enc.w, separator); err != nil {
+		return err
+	}
+	_, err = enc.w.Write(data)
+	return err
+}
+
+func (enc *jsonItemEncoder) end() error {
+	_, err := io.WriteString(enc.w, "\n]\n")
+	return err
+}
+
+type csvItemEncoder struct {
+	writer  *csv.Writer
+	columns []csvColumn
+}
+
+func (enc *csvItemEncoder) begin() error {
+	header := make([]string, len(enc.columns))
+	for idx, column := range enc.columns {
+		header[idx] = column.Header
+	}
+	return enc.writer.Write(header)
+}
+
+func (enc *csvItemEncoder) encode(item *model.TodoItem) error {
+	record := make([]string, len(enc.columns))
+	for idx, column := range enc.columns {
+		record[idx] = csvValue(item, column.Field)
+	}
+	return enc.writer.Write(record)
+}
+
+func (enc *csvItemEncoder) end() error {
+	enc.writer.Flush()
+	return enc.writer.Error()
+}
+
+type todoTxtEncoder struct {
+	w io.Writer
+}
+
+func (enc *todoTxtEncoder) begin() error {
+	return nil
+}
+
+func (enc *todoTxtEncoder) encode(item *model.TodoItem) error {
+	_, err := fmt.Fprintln(enc.w, todoTxtLine(item))
+	return err
+}
+
+func (enc *todoTxtEncoder) end() error {
+	return nil
+}
+
+func transferFormat(values url.Values) (string, *invalidParamError) {
+	format := values.Get("format")
+	if _, ok := transferContentTypes[format]; !ok {
+		return "", &invalidParamError{"format", "must be json, csv or todotxt"}
+	}
+	return format, nil
+}
+
+//Export streams the user's items, those the query selects as it
+//does for GET /v2/items, as a JSON array, CSV or todo.txt. Items
+//are written as they're read, once the first is the status
+//can't change, a failure cuts the export short.
+func Export(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	values := r.URL.Query()
+	if values.Get("format") == "" {
+		values.Set("format", formatJSON)
+	}
+	format, paramErr := transferFormat(values)
+	if paramErr != nil {
+		writeInvalidParam(&w, paramErr)
+		return
+	}
+	itemQuery, paramErr := parseItemQuery(values)
+	if paramErr != nil {
+		writeInvalidParam(&w, paramErr)
+		return
+	}
+	var encoder itemEncoder
+	switch format {
+	case formatJSON:
+		encoder = &jsonItemEncoder{w: w}
+	case formatCSV:
+		columns, paramErr := parseCSVColumns(values)
+		if paramErr == nil && len(columns) == 0 {
+			for _, field := range csvFields {
+				columns = append(columns, csvColumn{Field: field, Header: field})
+			}
+		}
+		separator, sepErr := csvSeparator(values)
+		if paramErr == nil {
+			paramErr = sepErr
+		}
+		if paramErr != nil {
+			writeInvalidParam(&w, paramErr)
+			return
+		}
+		writer := csv.NewWriter(w)
+		writer.Comma = separator
+		encoder = &csvItemEncoder{writer: writer, columns: columns}
+	case formatTodoTxt:
+		encoder = &todoTxtEncoder{w: w}
+	}
+	flusher, _ := w.(http.Flusher)
+	count := 0
+	started := false
+	start := func() error {
+		w.Header().Set("Content-Type", transferContentTypes[format]+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, transferFileNames[format]))
+		started = true
+		return encoder.begin()
+	}
+	err := model.EachOwnerItem(r.Context(), connection, userID, itemQuery, func(item *model.TodoItem) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := encoder.encode(item); err != nil {
+			return err
+		}
+		if count++; count%exportFlushItems == 0 {
+			if csvEncoder, ok := encoder.(*csvItemEncoder); ok {
+				csvEncoder.writer.Flush()
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil && !started {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = encoder.end()
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warnf("Export of user %s stopped after %d items: %v", userID, count, err)
+	}
+}
+
+//importRecord is an item read from an import, Err says why it
+//can't be imported if it can't. Row is where the record is in
+//the file, 0 if that's not known.
+type importRecord struct {
+	Row  int
+	Item *model.TodoItem
+	Err  error
+}
+
+//importResult is what became of a record.
+type importResult struct {
+	Row int `json:"row,omitempty"`
+	batchResult
+}
+
+//skipBOM drops the byte order mark spreadsheets put in front of
+//their files.
+func skipBOM(body io.Reader) io.Reader {
+	reader := bufio.NewReader(body)
+	if start, err := reader.Peek(3); err == nil && string(start) == "\xef\xbb\xbf" {
+		reader.Discard(3)
+	}
+	return reader
+}
+
+//readJSONImport reads an array of items, like exports have them.
+//Items which can't be decoded are records with an error, data
+//which isn't an array of objects fails the import.
+func readJSONImport(body io.Reader, strict bool, limit int64) ([]importRecord, error) {
+	dec := json.NewDecoder(skipBOM(body))
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, newFieldError(API_ERROR_CODE_INVALID_FIELD_TYPE, msgBodyWrongType,
+			"body", "must be an array of items")
+	}
+	var records []importRecord
+	for dec.More() {
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		item := &model.TodoItem{}
+		record := importRecord{Row: len(records) + 1, Item: item}
+		record.Err = decodeJSON(bytes.NewReader(raw), item, strict, limit)
+		records = append(records, record)
+	}
+	if _, err = dec.Token(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+//readCSVImport reads a CSV file with a header. Columns are
+//matched to fields by the headers of columns, or else by their
+//field name. Rows are counted from the header, which is 1.
+func readCSVImport(body io.Reader, columns []csvColumn, separator rune) ([]importRecord, error) {
+	reader := csv.NewReader(skipBOM(body))
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, newFieldError(API_ERROR_CODE_INVALID_INPUT, msgImportCantBeRead, "line 1", "the header is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(header))
+	for idx := range header {
+		header[idx] = strings.TrimSpace(header[idx])
+		for _, column := range columns {
+			if strings.EqualFold(column.Header, header[idx]) {
+				fields[idx] = column.Field
+			}
+		}
+		if name := strings.ToLower(header[idx]); fields[idx] == "" && utils.StringSlice(csvFields).Contains(name) {
+			fields[idx] = name
+		}
+	}
+	var records []importRecord
+	for row := 2; ; row++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		item := &model.TodoItem{}
+		record := importRecord{Row: row, Item: item}
+		for idx, value := range values {
+			if value = strings.TrimSpace(value); idx >= len(header) || value == "" {
+				continue
+			}
+			if record.Err = setCSVValue(item, fields[idx], header[idx], value); record.Err != nil {
+				break
+			}
+		}
+		if item.ID == "" {
+			item.ID = contentID(strings.Join(values, "\x00"))
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readTodoTxtImport(body io.Reader) ([]importRecord, error) {
+	scanner := bufio.NewScanner(skipBOM(body))
+	scanner.Buffer(nil, maxTodoTxtLine)
+	var records []importRecord
+	row := 0
+	for scanner.Scan() {
+		row++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		item, err := todoTxtItem(line)
+		records = append(records, importRecord{Row: row, Item: item, Err: err})
+	}
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		return nil, newFieldError(API_ERROR_CODE_INVALID_INPUT, msgImportCantBeRead,
+			fmt.Sprintf("line %d", row+1), fmt.Sprintf("is longer than %d bytes", maxTodoTxtLine))
+	} else if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+//checkImportItem is an add of applyBatchOperation which stops
+//short of saving.
+func checkImportItem(w *http.ResponseWriter, ctx context.Context, connection *mongo.Client, userID string, item *model.TodoItem) bool {
+	if err := checkRequired(item); err != nil {
+		writeBodyError(w, err)
+		return false
+	}
+	if err := checkItemID(item); err != nil {
+		writeBodyError(w, err)
+		return false
+	}
+	if _, err := model.GetOneTodoItemForOwner(ctx, connection, userID, item.ID); err == nil {
+		GenericResponseWithEC(w, msgItemAlreadyExists, http.StatusConflict, API_ERROR_CODE_INVALID_INPUT)
+		return false
+	}
+	item.Owner = userID
+	return prepareItem(w, item, nil)
+}
+
+//runImport adds the records as items, answering with a result
+//for each like /post/batch does. Records whose id is already an
+//item's are duplicates, left as they are. With dry_run=1 the
+//records are only checked, nothing is saved.
+func runImport(w http.ResponseWriter, r *http.Request, records []importRecord) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	dryRun := r.URL.Query().Get("dry_run") == "1"
+	results := make([]importResult, 0, len(records))
+	imported, duplicates := 0, 0
+	seen := map[string]bool{}
+	for idx := range records {
+		record := &records[idx]
+		op := batchOperation{Op: batchOpAdd, Item: record.Item}
+		rec, opWriter := batchWriter(w)
+		ok := false
+		switch {
+		case record.Err != nil:
+			writeBodyError(&opWriter, record.Err)
+		case seen[record.Item.ID]:
+			//Saved already, or would be.
+			GenericResponseWithEC(&opWriter, msgItemAlreadyExists, http.StatusConflict, API_ERROR_CODE_INVALID_INPUT)
+		case dryRun:
+			ok = checkImportItem(&opWriter, r.Context(), connection, userID, record.Item)
+		default:
+			ok = applyBatchOperation(&opWriter, r.Context(), connection, userID, &op)
+		}
+		if record.Err == nil && record.Item.ID != "" {
+			seen[record.Item.ID] = true
+		}
+		result := importResult{Row: record.Row, batchResult: batchResult{Index: idx, Op: op.Op, ID: op.itemID(),
+			Status: http.StatusOK, APICode: API_ERROR_CODE_OK}}
+		switch {
+		case ok:
+			imported++
+		case rec.status == http.StatusConflict:
+			duplicates++
+			fallthrough
+		default:
+			result.batchResult = rec.result(idx, &op, op.itemID())
+		}
+		results = append(results, result)
+	}
+	message := msgImportComplete
+	if dryRun {
+		message = msgImportChecked
+	}
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		APICode: API_ERROR_CODE_OK,
+		Message: message,
+		Meta: map[string]interface{}{
+			"dry_run":    dryRun,
+			"results":    results,
+			"imported":   imported,
+			"duplicates": duplicates,
+			"failed":     len(results) - imported - duplicates,
+		},
+	}
+	GenericWriteResponse(&w, &resp)
+}
+
+//Import adds the items of a JSON array, a CSV file or a todo.txt
+//file. The format is taken from the content type unless format
+//says otherwise.
+func Import(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	if values.Get("format") == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		for format, contentType := range transferContentTypes {
+			if contentType == mediaType {
+				values.Set("format", format)
+			}
+		}
+	}
+	format, paramErr := transferFormat(values)
+	if paramErr != nil {
+		writeInvalidParam(&w, paramErr)
+		return
+	}
+	var records []importRecord
+	var err error
+	switch format {
+	case formatJSON:
+		records, err = readJSONImport(r.Body, APIVersion(r) != LegacyAPIVersion, requestBodyLimit(r))
+	case formatCSV:
+		columns, paramErr := parseCSVColumns(values)
+		separator, sepErr := csvSeparator(values)
+		if paramErr == nil {
+			paramErr = sepErr
+		}
+		if paramErr != nil {
+			writeInvalidParam(&w, paramErr)
+			return
+		}
+		records, err = readCSVImport(r.Body, columns, separator)
+	case formatTodoTxt:
+		records, err = readTodoTxtImport(r.Body)
+	}
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		writeFieldErrors(&w, msgImportCantBeRead,
+			responses.FieldError{Field: fmt.Sprintf("line %d", parseErr.Line), Reason: parseErr.Err.Error()})
+		return
+	}
+	if err != nil {
+		writeBodyError(&w, err)
+		return
+	}
+	runImport(w, r, records)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+	"todolist/model"
+)
+
+//transferItems are exported and imported again by the round
+//trip tests, with what each format keeps of them.
+func transferItems() []model.TodoItem {
+	return []model.TodoItem{
+		{ID: "1", Name: "Buy milk, eggs", Status: "todo", Priority: model.PriorityHigh, List: "groceries",
+			Tags: []string{"home", "errand"}, StartTime: "2020-01-01", EndTime: "2020-01-02T10:00:00Z"},
+		{ID: "2", Name: "Weekly \"review\"", Status: "doing", Recurrence: "FREQ=WEEKLY;BYDAY=MO"},
+		{ID: "3", Name: "Done", Status: "done", Priority: model.PriorityUrgent,
+			CompletedAt: "2020-01-03T00:00:00Z", CompletedBy: "a"},
+	}
+}
+
+func exportItems(t *testing.T, enc itemEncoder, items []model.TodoItem) {
+	if err := enc.begin(); err != nil {
+		t.Fatal(err)
+	}
+	for idx := range items {
+		if err := enc.encode(&items[idx]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.end(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func importedItems(t *testing.T, records []importRecord) []model.TodoItem {
+	var items []model.TodoItem
+	for _, record := range records {
+		if record.Err != nil {
+			t.Fatalf("row %d: %v", record.Row, record.Err)
+		}
+		items = append(items, *record.Item)
+	}
+	return items
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	items := transferItems()
+	var data bytes.Buffer
+	exportItems(t, &jsonItemEncoder{w: &data}, items)
+	records, err := readJSONImport(&data, true, defaultMaxBodyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := importedItems(t, records); !reflect.DeepEqual(got, items) {
+		t.Errorf("imported %+v, want %+v", got, items)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	items := transferItems()
+	items[1].Content = map[string]interface{}{"note": "a;b", "count": 2.0}
+	columns := make([]csvColumn, len(csvFields))
+	for idx, field := range csvFields {
+		columns[idx] = csvColumn{Field: field, Header: field}
+	}
+	//The header of a column is what it's matched by.
+	columns[1].Header = "Title"
+	var data bytes.Buffer
+	writer := csv.NewWriter(&data)
+	writer.Comma = ';'
+	exportItems(t, &csvItemEncoder{writer: writer, columns: columns}, items)
+	records, err := readCSVImport(&data, columns, ';')
+	if err != nil {
+		t.Fatal(err)
+	}
+	//The workflow fills in the completion again.
+	for idx := range items {
+		items[idx].CompletedAt = ""
+		items[idx].CompletedBy = ""
+	}
+	if got := importedItems(t, records); !reflect.DeepEqual(got, items) {
+		t.Errorf("imported %+v, want %+v", got, items)
+	}
+}
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	items := transferItems()
+	var data bytes.Buffer
+	exportItems(t, &todoTxtEncoder{w: &data}, items)
+	records, err := readTodoTxtImport(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//The initial status is left out, as is the completion.
+	items[0].Status = ""
+	items[2].CompletedAt = ""
+	items[2].CompletedBy = ""
+	if got := importedItems(t, records); !reflect.DeepEqual(got, items) {
+		t.Errorf("imported %+v, want %+v", got, items)
+	}
+}
+
+func TestTodoTxtItem(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		want  model.TodoItem
+		fails bool
+	}{
+		{"plain", "Call mom id:1", model.TodoItem{ID: "1", Name: "Call mom"}, false},
+		{"priority and creation date", "(A) 2020-01-01 Call mom +family @phone id:1",
+			model.TodoItem{ID: "1", Name: "Call mom", Priority: model.PriorityUrgent, List: "family",
+				Tags: []string{"phone"}}, false},
+		{"letter past D", "(F) Call id:1", model.TodoItem{ID: "1", Name: "Call", Priority: model.PriorityLow}, false},
+		{"done with dates", "x 2020-01-02 2020-01-01 Call pri:b id:1",
+			model.TodoItem{ID: "1", Name: "Call", Status: "done", Priority: model.PriorityHigh}, false},
+		{"second project is a word", "Call +a +b id:1", model.TodoItem{ID: "1", Name: "Call +b", List: "a"}, false},
+		{"urls and unknown keys", "See http://a.b/c key:value id:1",
+			model.TodoItem{ID: "1", Name: "See http://a.b/c", Content: map[string]interface{}{"key": "value"}}, false},
+		{"no id", "Call mom", model.TodoItem{ID: contentID("Call mom"), Name: "Call mom"}, false},
+		{"bad due date", "Call due:soon id:1", model.TodoItem{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item, err := todoTxtItem(test.line)
+			if (err != nil) != test.fails {
+				t.Fatalf("error %v, want failure %v", err, test.fails)
+			}
+			if err == nil && !reflect.DeepEqual(*item, test.want) {
+				t.Errorf("read %+v, want %+v", *item, test.want)
+			}
+		})
+	}
+}
+
+func TestReadCSVImport(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		want  []model.TodoItem
+		field string
+	}{
+		{"by field name and BOM", "\xef\xbb\xbfID,Name,Extra\n1,Call, note \n",
+			[]model.TodoItem{{ID: "1", Name: "Call", Content: map[string]interface{}{"Extra": "note"}}}, ""},
+		{"short and long rows", "id,name\n1\n2,Call,more\n",
+			[]model.TodoItem{{ID: "1"}, {ID: "2", Name: "Call"}}, ""},
+		{"bad priority", "id,priority\n1,highest\n", nil, "priority"},
+		{"bad time", "id,end_time\n1,tomorrow\n", nil, "end_time"},
+		{"content not an object", "id,content\n1,[1]\n", nil, "content"},
+		{"no header", "", nil, "line 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records, err := readCSVImport(strings.NewReader(test.data), nil, ',')
+			if test.field != "" {
+				if err == nil && len(records) == 1 {
+					err = records[0].Err
+				}
+				e := toBodyError(err)
+				if e == nil || len(e.errors) != 1 || e.errors[0].Field != test.field {
+					t.Errorf("error %v, want one for %s", err, test.field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := importedItems(t, records); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("imported %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
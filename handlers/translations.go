@@ -0,0 +1,627 @@
+package handlers
+
+import "todolist/i18n"
+
+//Ids of the messages responses carry, GenericWriteResponse
+//translates them. Text which isn't an id goes out as it is.
+const (
+	msgLoginSuccessful            = "login_successful"
+	msgUserRegistered             = "user_registered"
+	msgUserNotFound               = "user_not_found"
+	msgUserNotFoundLegacy         = "user_not_found_legacy"
+	msgItemNotFound               = "item_not_found"
+	msgItemAlreadyExists          = "item_already_exists"
+	msgItemAdded                  = "item_added"
+	msgItemModified               = "item_modified"
+	msgItemRemoved                = "item_removed"
+	msgTagNotFound                = "tag_not_found"
+	msgTagAlreadyExists           = "tag_already_exists"
+	msgTagsFetchComplete          = "tags_fetch_complete"
+	msgSearchComplete             = "search_complete"
+	msgBatchComplete              = "batch_complete"
+	msgIdempotencyKeyTooLong      = "idempotency_key_too_long"
+	msgIdempotencyKeyReused       = "idempotency_key_reused"
+	msgIdempotencyKeyInProgress   = "idempotency_key_in_progress"
+	msgPatchTestFailed            = "patch_test_failed"
+	msgPatchCantBeApplied         = "patch_cant_be_applied"
+	msgPatchedItemNotObject       = "patched_item_not_object"
+	msgProtectedFields            = "protected_fields"
+	msgInvalidRecurrenceRule      = "invalid_recurrence_rule"
+	msgUnknownFeedToken           = "unknown_feed_token"
+	msgCalendarFeedCreated        = "calendar_feed_created"
+	msgCalendarFeedRevoked        = "calendar_feed_revoked"
+	msgCalendarCantBeParsed       = "calendar_cant_be_parsed"
+	msgComponentNotImportable     = "component_not_importable"
+	msgNotICalendar               = "not_icalendar"
+	msgImportComplete             = "import_complete"
+	msgImportChecked              = "import_checked"
+	msgImportCantBeRead           = "import_cant_be_read"
+	msgRecordCantBeImported       = "record_cant_be_imported"
+	msgDataRequestAccepted        = "data_request_accepted"
+	msgDataRequestFetchComplete   = "data_request_fetch_complete"
+	msgDataRequestsFetchComplete  = "data_requests_fetch_complete"
+	msgDataRequestNotFound        = "data_request_not_found"
+	msgArchiveNotReady            = "archive_not_ready"
+	msgErasureNotConfirmed        = "erasure_not_confirmed"
+	msgUnknownDataRequestType     = "unknown_data_request_type"
+	msgDataRequestInProgress      = "data_request_in_progress"
+	msgStartingUp                 = "starting_up"
+	msgReady                      = "ready"
+	msgNotReady                   = "not_ready"
+	msgResourceNotFound           = "resource_not_found"
+	msgOtherUsersCalendars        = "other_users_calendars"
+	msgAuthenticationRequired     = "authentication_required"
+	msgItemHasChanged             = "item_has_changed"
+	msgUIDMismatch                = "uid_mismatch"
+	msgRequestBodyNotXML          = "request_body_not_xml"
+	msgUnableToProcess            = "unable_to_process"
+	msgInternalServerError        = "internal_server_error"
+	msgMethodNotSupported         = "method_not_supported"
+	msgItemsFound                 = "items_found"
+	msgItemsFetchComplete         = "items_fetch_complete"
+	msgTokenVerified              = "token_verified"
+	msgInvalidTransition          = "invalid_transition"
+	msgUnknownBatchOperation      = "unknown_batch_operation"
+	msgBatchTooLarge              = "batch_too_large"
+	msgBatchRolledBack            = "batch_rolled_back"
+	msgRolledBack                 = "rolled_back"
+	msgBodyMissesMembers          = "body_misses_members"
+	msgBodyLargerThan             = "body_larger_than"
+	msgBodyEmpty                  = "body_empty"
+	msgBodyTrailingData           = "body_trailing_data"
+	msgBodyDuplicateMember        = "body_duplicate_member"
+	msgBodyUnknownMembers         = "body_unknown_members"
+	msgBodyMalformed              = "body_malformed"
+	msgBodyWrongType              = "body_wrong_type"
+	msgBodyIncomplete             = "body_incomplete"
+	msgBodyTooLarge               = "body_too_large"
+	msgBodyUnreadable             = "body_unreadable"
+	msgRequirementsNotMet         = "requirements_not_met"
+	msgUnsupportedContentType     = "unsupported_content_type"
+	msgRequestBodyTooLarge        = "request_body_too_large"
+	msgInvalidTagName             = "invalid_tag_name"
+	msgInvalidTagColor            = "invalid_tag_color"
+	msgTagAdded                   = "tag_added"
+	msgTagModified                = "tag_modified"
+	msgTagRemoved                 = "tag_removed"
+	msgTagRenamed                 = "tag_renamed"
+	msgTagsMerged                 = "tags_merged"
+	msgTagRemoveFailed            = "tag_remove_failed"
+	msgTagRenameFailed            = "tag_rename_failed"
+	msgTagMergeFailed             = "tag_merge_failed"
+	msgNotFound                   = "not_found"
+	msgNotImplemented             = "not_implemented"
+	msgUserIDMissing              = "user_id_missing"
+	msgItemFetchComplete          = "item_fetch_complete"
+	msgItemSaveFailed             = "item_save_failed"
+	msgOK                         = "ok"
+	msgServerError                = "server_error"
+	msgAuthorizationHeaderMissing = "authorization_header_missing"
+	msgUnknownAuthProvider        = "unknown_auth_provider"
+	msgTokenExpired               = "token_expired"
+	msgWorkflowFetchComplete      = "workflow_fetch_complete"
+	msgItemRequired               = "item_required"
+	msgIDRequired                 = "id_required"
+	msgUnknownStatus              = "unknown_status"
+	msgUnknownPriority            = "unknown_priority"
+	msgInvalidTags                = "invalid_tags"
+	msgInvalidParam               = "invalid_param"
+	msgWebSocketUpgradeFailed     = "websocket_upgrade_failed"
+	msgAPIVersionInvalid          = "api_version_invalid"
+	msgAPIVersionUnsupported      = "api_version_unsupported"
+	msgAPIVersionUnknown          = "api_version_unknown"
+	msgAPIVersionNotServed        = "api_version_not_served"
+	msgPostNotShared              = "post_not_shared"
+)
+
+//Messages are keyed by their id, a message missing from a
+//catalog goes out in english.
+func init() {
+	i18n.Register(i18n.DefaultLocale, i18n.Catalog{
+		Messages: map[string]i18n.Message{
+			msgLoginSuccessful:            {Other: "Login Successful"},
+			msgUserRegistered:             {Other: "User Registration Successful."},
+			msgUserNotFound:               {Other: "User not found"},
+			msgUserNotFoundLegacy:         {Other: "User not found."},
+			msgItemNotFound:               {Other: "Item not found"},
+			msgItemAlreadyExists:          {Other: "Item already exists"},
+			msgItemAdded:                  {Other: "add Todo Item succeeded"},
+			msgItemModified:               {Other: "modify Todo Item succeeded"},
+			msgItemRemoved:                {Other: "Removed ToDo Item"},
+			msgTagNotFound:                {Other: "Tag not found"},
+			msgTagAlreadyExists:           {Other: "Tag already exists"},
+			msgTagsFetchComplete:          {Other: "Tags fetch complete"},
+			msgSearchComplete:             {Other: "Search complete"},
+			msgBatchComplete:              {Other: "Batch complete"},
+			msgIdempotencyKeyTooLong:      {Other: "Idempotency-Key is too long"},
+			msgIdempotencyKeyReused:       {Other: "Idempotency-Key was already used with another request"},
+			msgIdempotencyKeyInProgress:   {Other: "A request with this Idempotency-Key is still being processed"},
+			msgPatchTestFailed:            {Other: "Patch test failed"},
+			msgPatchCantBeApplied:         {Other: "Patch can't be applied"},
+			msgPatchedItemNotObject:       {Other: "The patched item isn't an object"},
+			msgProtectedFields:            {Other: "Protected fields can't be changed"},
+			msgInvalidRecurrenceRule:      {Other: "invalid recurrence rule"},
+			msgUnknownFeedToken:           {Other: "Unknown calendar feed token"},
+			msgCalendarFeedCreated:        {Other: "Calendar feed created"},
+			msgCalendarFeedRevoked:        {Other: "Calendar feed revoked"},
+			msgCalendarCantBeParsed:       {Other: "Calendar can't be parsed"},
+			msgComponentNotImportable:     {Other: "Calendar component can't be imported"},
+			msgNotICalendar:               {Other: "Not an iCalendar"},
+			msgImportComplete:             {Other: "Import complete"},
+			msgImportChecked:              {Other: "Import checked"},
+			msgImportCantBeRead:           {Other: "Import can't be read"},
+			msgRecordCantBeImported:       {Other: "Record can't be imported"},
+			msgDataRequestAccepted:        {Other: "Data request accepted"},
+			msgDataRequestFetchComplete:   {Other: "Data request fetch complete"},
+			msgDataRequestsFetchComplete:  {Other: "Data requests fetch complete"},
+			msgDataRequestNotFound:        {Other: "Data request not found"},
+			msgArchiveNotReady:            {Other: "Archive isn't ready"},
+			msgErasureNotConfirmed:        {Other: "Erasure isn't confirmed"},
+			msgUnknownDataRequestType:     {Other: "Unknown data request type"},
+			msgDataRequestInProgress:      {Other: "A data request of this type is in progress"},
+			msgStartingUp:                 {Other: "Starting up, try again shortly"},
+			msgReady:                      {Other: "Ready"},
+			msgNotReady:                   {Other: "Not ready"},
+			msgResourceNotFound:           {Other: "Resource not found"},
+			msgOtherUsersCalendars:        {Other: "Calendars of other users can't be accessed"},
+			msgAuthenticationRequired:     {Other: "Authentication required"},
+			msgItemHasChanged:             {Other: "Item has changed"},
+			msgUIDMismatch:                {Other: "UID doesn't match the resource name"},
+			msgRequestBodyNotXML:          {Other: "Request body isn't XML"},
+			msgUnableToProcess:            {Other: "Unable to process request."},
+			msgInternalServerError:        {Other: "An Internal Server Error occured"},
+			msgMethodNotSupported:         {Other: "HTTP Method Not Supported"},
+			msgItemsFound:                 {One: "%d item found", Other: "%d items found"},
+			msgItemsFetchComplete:         {Other: "Items fetch complete"},
+			msgTokenVerified:              {Other: "Verified %s Token"},
+			msgInvalidTransition:          {Other: "can't change status from %s to %s"},
+			msgUnknownBatchOperation:      {Other: "unknown operation %s"},
+			msgBatchTooLarge:              {Other: "a batch may have at most %d operations"},
+			msgBatchRolledBack:            {Other: "Batch rolled back, operation %d failed"},
+			msgRolledBack:                 {Other: "rolled back"},
+			msgBodyMissesMembers:          {Other: "json body misses required members."},
+			msgBodyLargerThan:             {Other: "json body is larger than %d bytes."},
+			msgBodyEmpty:                  {Other: "json body is empty."},
+			msgBodyTrailingData:           {Other: "json body has data after offset %d."},
+			msgBodyDuplicateMember:        {Other: "json body has a member more than once."},
+			msgBodyUnknownMembers:         {Other: "json body has unknown members."},
+			msgBodyMalformed:              {Other: "malformed json body at offset %d: %v"},
+			msgBodyWrongType:              {Other: "json body has members of the wrong type."},
+			msgBodyIncomplete:             {Other: "json body is incomplete."},
+			msgBodyTooLarge:               {Other: "json body is too large."},
+			msgBodyUnreadable:             {Other: "Unable to read request body."},
+			msgRequirementsNotMet:         {Other: "Request requirements not met."},
+			msgUnsupportedContentType:     {Other: "Unsupported request content type."},
+			msgRequestBodyTooLarge:        {Other: "Request body too large."},
+			msgInvalidTagName:             {Other: "Invalid tag name"},
+			msgInvalidTagColor:            {Other: "color must be of the form #rrggbb"},
+			msgTagAdded:                   {Other: "add Tag succeeded"},
+			msgTagModified:                {Other: "modify Tag succeeded"},
+			msgTagRemoved:                 {Other: "Removed Tag"},
+			msgTagRenamed:                 {Other: "Renamed Tag"},
+			msgTagsMerged:                 {Other: "Merged Tags"},
+			msgTagRemoveFailed:            {Other: "Unable to remove tag"},
+			msgTagRenameFailed:            {Other: "Unable to rename tag"},
+			msgTagMergeFailed:             {Other: "Unable to merge tags"},
+			msgNotFound:                   {Other: "not found"},
+			msgNotImplemented:             {Other: "not implemented"},
+			msgUserIDMissing:              {Other: "User ID doesn't exists"},
+			msgItemFetchComplete:          {Other: "Item fetch complete"},
+			msgItemSaveFailed:             {Other: "A Server Error occured trying to modify / add TodoItem."},
+			msgOK:                         {Other: "OK"},
+			msgServerError:                {Other: "Internal server error."},
+			msgAuthorizationHeaderMissing: {Other: "Required Authorization header missing."},
+			msgUnknownAuthProvider:        {Other: "Unknown auth provider"},
+			msgTokenExpired:               {Other: "expired token"},
+			msgWorkflowFetchComplete:      {Other: "Workflow fetch complete"},
+			msgItemRequired:               {Other: "item is required"},
+			msgIDRequired:                 {Other: "id is required"},
+			msgUnknownStatus:              {Other: "unknown status"},
+			msgUnknownPriority:            {Other: "unknown priority"},
+			msgInvalidTags:                {Other: "Invalid tags"},
+			msgInvalidParam:               {Other: "invalid parameter %s: %s"},
+			msgWebSocketUpgradeFailed:     {Other: "Connection can't be upgraded to a websocket"},
+			msgAPIVersionInvalid:          {Other: "invalid api version %s"},
+			msgAPIVersionUnsupported:      {Other: "api version %d is no longer supported"},
+			msgAPIVersionUnknown:          {Other: "api version %d is unknown"},
+			msgAPIVersionNotServed:        {Other: "api version %d is not served here"},
+			msgPostNotShared:              {Other: "Post not shared with user"},
+		},
+	})
+	i18n.Register("es", i18n.Catalog{
+		Codes: map[int64]string{
+			API_ERROR_CODE_OK:                     "la api se ejecutó correctamente",
+			API_ERROR_CODE_GENERIC_ERROR:          "la solicitud contenía errores. Revise las cabeceras de la respuesta",
+			API_ERROR_CODE_TOKEN_EXPIRED:          "token caducado",
+			API_ERROR_CODE_INVALID_INPUT:          "datos no válidos en la solicitud",
+			API_ERROR_CODE_INVALID_VERSION:        "versión de la api no soportada, actualice la aplicación",
+			API_ERROR_CODE_UNKNOWN_AUTH_PROVIDER:  "proveedor de autenticación desconocido",
+			API_ERROR_CODE_INVALID_PUBLIC_CERT:    "formato de certificado público no válido",
+			API_ERROR_CODE_INVALID_RSA_KEY:        "formato de clave RSA no válido",
+			API_ERROR_CODE_NOT_IMPLEMENTED:        "la api no está implementada actualmente",
+			API_ERROR_CODE_INVALID_TRANSITION:     "el flujo de trabajo no permite ese cambio de estado",
+			API_ERROR_CODE_UNSUPPORTED_MEDIA_TYPE: "tipo de contenido o juego de caracteres no soportado",
+			API_ERROR_CODE_REQUEST_TOO_LARGE:      "el cuerpo de la solicitud es demasiado grande",
+			API_ERROR_CODE_MALFORMED_JSON:         "el cuerpo de la solicitud no es json válido",
+			API_ERROR_CODE_UNKNOWN_FIELD:          "el cuerpo de la solicitud tiene un campo desconocido",
+			API_ERROR_CODE_DUPLICATE_FIELD:        "el cuerpo de la solicitud repite un campo",
+			API_ERROR_CODE_MISSING_FIELD:          "al cuerpo de la solicitud le falta un campo obligatorio",
+			API_ERROR_CODE_INVALID_FIELD_TYPE:     "el cuerpo de la solicitud tiene un campo de tipo incorrecto",
+			API_ERROR_CODE_IDEMPOTENCY_KEY_REUSED: "la clave de idempotencia ya se usó para otra solicitud",
+			API_ERROR_CODE_PATCH_TEST_FAILED:      "falló una operación test del parche",
+		},
+		Messages: map[string]i18n.Message{
+			msgLoginSuccessful:            {Other: "Inicio de sesión correcto"},
+			msgUserRegistered:             {Other: "Registro de usuario correcto."},
+			msgUserNotFound:               {Other: "Usuario no encontrado"},
+			msgUserNotFoundLegacy:         {Other: "Usuario no encontrado."},
+			msgItemNotFound:               {Other: "Elemento no encontrado"},
+			msgItemAlreadyExists:          {Other: "El elemento ya existe"},
+			msgItemAdded:                  {Other: "Elemento añadido"},
+			msgItemModified:               {Other: "Elemento modificado"},
+			msgItemRemoved:                {Other: "Elemento eliminado"},
+			msgTagNotFound:                {Other: "Etiqueta no encontrada"},
+			msgTagAlreadyExists:           {Other: "La etiqueta ya existe"},
+			msgTagsFetchComplete:          {Other: "Etiquetas obtenidas"},
+			msgSearchComplete:             {Other: "Búsqueda completada"},
+			msgBatchComplete:              {Other: "Lote completado"},
+			msgIdempotencyKeyTooLong:      {Other: "Idempotency-Key es demasiado larga"},
+			msgIdempotencyKeyReused:       {Other: "Idempotency-Key ya se usó con otra solicitud"},
+			msgIdempotencyKeyInProgress:   {Other: "Todavía se está procesando una solicitud con esta Idempotency-Key"},
+			msgPatchTestFailed:            {Other: "Falló la prueba del parche"},
+			msgPatchCantBeApplied:         {Other: "No se puede aplicar el parche"},
+			msgPatchedItemNotObject:       {Other: "El elemento parcheado no es un objeto"},
+			msgProtectedFields:            {Other: "Los campos protegidos no se pueden cambiar"},
+			msgInvalidRecurrenceRule:      {Other: "regla de recurrencia no válida"},
+			msgUnknownFeedToken:           {Other: "Token de calendario desconocido"},
+			msgCalendarFeedCreated:        {Other: "Calendario creado"},
+			msgCalendarFeedRevoked:        {Other: "Calendario revocado"},
+			msgCalendarCantBeParsed:       {Other: "No se puede leer el calendario"},
+			msgComponentNotImportable:     {Other: "No se puede importar el componente del calendario"},
+			msgNotICalendar:               {Other: "No es un iCalendar"},
+			msgImportComplete:             {Other: "Importación completada"},
+			msgImportChecked:              {Other: "Importación comprobada"},
+			msgImportCantBeRead:           {Other: "No se puede leer la importación"},
+			msgRecordCantBeImported:       {Other: "No se puede importar el registro"},
+			msgDataRequestAccepted:        {Other: "Solicitud de datos aceptada"},
+			msgDataRequestFetchComplete:   {Other: "Solicitud de datos obtenida"},
+			msgDataRequestsFetchComplete:  {Other: "Solicitudes de datos obtenidas"},
+			msgDataRequestNotFound:        {Other: "Solicitud de datos no encontrada"},
+			msgArchiveNotReady:            {Other: "El archivo aún no está listo"},
+			msgErasureNotConfirmed:        {Other: "El borrado no está confirmado"},
+			msgUnknownDataRequestType:     {Other: "Tipo de solicitud de datos desconocido"},
+			msgDataRequestInProgress:      {Other: "Ya hay una solicitud de datos de este tipo en curso"},
+			msgStartingUp:                 {Other: "Iniciando, inténtelo de nuevo en breve"},
+			msgReady:                      {Other: "Listo"},
+			msgNotReady:                   {Other: "No está listo"},
+			msgResourceNotFound:           {Other: "Recurso no encontrado"},
+			msgOtherUsersCalendars:        {Other: "No se puede acceder a los calendarios de otros usuarios"},
+			msgAuthenticationRequired:     {Other: "Se requiere autenticación"},
+			msgItemHasChanged:             {Other: "El elemento ha cambiado"},
+			msgUIDMismatch:                {Other: "El UID no coincide con el nombre del recurso"},
+			msgRequestBodyNotXML:          {Other: "El cuerpo de la solicitud no es XML"},
+			msgUnableToProcess:            {Other: "No se pudo procesar la solicitud."},
+			msgInternalServerError:        {Other: "Se produjo un error interno del servidor"},
+			msgMethodNotSupported:         {Other: "Método HTTP no soportado"},
+			msgItemsFound:                 {One: "%d elemento encontrado", Other: "%d elementos encontrados"},
+			msgItemsFetchComplete:         {Other: "Elementos obtenidos"},
+			msgTokenVerified:              {Other: "Token de %s verificado"},
+			msgInvalidTransition:          {Other: "no se puede cambiar el estado de %s a %s"},
+			msgUnknownBatchOperation:      {Other: "operación desconocida %s"},
+			msgBatchTooLarge:              {Other: "un lote puede tener como máximo %d operaciones"},
+			msgBatchRolledBack:            {Other: "Lote revertido, falló la operación %d"},
+			msgRolledBack:                 {Other: "revertida"},
+			msgBodyMissesMembers:          {Other: "al cuerpo json le faltan miembros obligatorios."},
+			msgBodyLargerThan:             {Other: "el cuerpo json ocupa más de %d bytes."},
+			msgBodyEmpty:                  {Other: "el cuerpo json está vacío."},
+			msgBodyTrailingData:           {Other: "el cuerpo json tiene datos tras la posición %d."},
+			msgBodyDuplicateMember:        {Other: "el cuerpo json tiene un miembro repetido."},
+			msgBodyUnknownMembers:         {Other: "el cuerpo json tiene miembros desconocidos."},
+			msgBodyMalformed:              {Other: "cuerpo json mal formado en la posición %d: %v"},
+			msgBodyWrongType:              {Other: "el cuerpo json tiene miembros de un tipo incorrecto."},
+			msgBodyIncomplete:             {Other: "el cuerpo json está incompleto."},
+			msgBodyTooLarge:               {Other: "el cuerpo json es demasiado grande."},
+			msgBodyUnreadable:             {Other: "No se pudo leer el cuerpo de la solicitud."},
+			msgRequirementsNotMet:         {Other: "La solicitud no cumple los requisitos."},
+			msgUnsupportedContentType:     {Other: "Tipo de contenido de la solicitud no soportado."},
+			msgRequestBodyTooLarge:        {Other: "El cuerpo de la solicitud es demasiado grande."},
+			msgInvalidTagName:             {Other: "Nombre de etiqueta no válido"},
+			msgInvalidTagColor:            {Other: "el color debe tener la forma #rrggbb"},
+			msgTagAdded:                   {Other: "Etiqueta añadida"},
+			msgTagModified:                {Other: "Etiqueta modificada"},
+			msgTagRemoved:                 {Other: "Etiqueta eliminada"},
+			msgTagRenamed:                 {Other: "Etiqueta renombrada"},
+			msgTagsMerged:                 {Other: "Etiquetas fusionadas"},
+			msgTagRemoveFailed:            {Other: "No se pudo eliminar la etiqueta"},
+			msgTagRenameFailed:            {Other: "No se pudo renombrar la etiqueta"},
+			msgTagMergeFailed:             {Other: "No se pudieron fusionar las etiquetas"},
+			msgNotFound:                   {Other: "no encontrado"},
+			msgNotImplemented:             {Other: "no implementado"},
+			msgUserIDMissing:              {Other: "El ID de usuario no existe"},
+			msgItemFetchComplete:          {Other: "Elemento obtenido"},
+			msgItemSaveFailed:             {Other: "Error del servidor al añadir o modificar el elemento."},
+			msgOK:                         {Other: "OK"},
+			msgServerError:                {Other: "Error interno del servidor."},
+			msgAuthorizationHeaderMissing: {Other: "Falta la cabecera de autorización obligatoria."},
+			msgUnknownAuthProvider:        {Other: "Proveedor de autenticación desconocido"},
+			msgTokenExpired:               {Other: "token caducado"},
+			msgWorkflowFetchComplete:      {Other: "Flujo de trabajo obtenido"},
+			msgItemRequired:               {Other: "item es obligatorio"},
+			msgIDRequired:                 {Other: "id es obligatorio"},
+			msgUnknownStatus:              {Other: "estado desconocido"},
+			msgUnknownPriority:            {Other: "prioridad desconocida"},
+			msgInvalidTags:                {Other: "Etiquetas no válidas"},
+			msgInvalidParam:               {Other: "parámetro no válido %s: %s"},
+			msgWebSocketUpgradeFailed:     {Other: "La conexión no se puede convertir en websocket"},
+			msgAPIVersionInvalid:          {Other: "versión de la api no válida %s"},
+			msgAPIVersionUnsupported:      {Other: "la versión %d de la api ya no está soportada"},
+			msgAPIVersionUnknown:          {Other: "la versión %d de la api es desconocida"},
+			msgAPIVersionNotServed:        {Other: "la versión %d de la api no se sirve aquí"},
+			msgPostNotShared:              {Other: "Publicación no compartida con el usuario"},
+		},
+	})
+	i18n.Register("fr", i18n.Catalog{
+		Codes: map[int64]string{
+			API_ERROR_CODE_OK:                     "l'api s'est exécutée avec succès",
+			API_ERROR_CODE_GENERIC_ERROR:          "la requête contenait des erreurs. Vérifiez les en-têtes de la réponse",
+			API_ERROR_CODE_TOKEN_EXPIRED:          "jeton expiré",
+			API_ERROR_CODE_INVALID_INPUT:          "données invalides dans la requête",
+			API_ERROR_CODE_INVALID_VERSION:        "version de l'api non prise en charge, mettez l'application à jour",
+			API_ERROR_CODE_UNKNOWN_AUTH_PROVIDER:  "fournisseur d'authentification inconnu",
+			API_ERROR_CODE_INVALID_PUBLIC_CERT:    "format de certificat public invalide",
+			API_ERROR_CODE_INVALID_RSA_KEY:        "format de clé RSA invalide",
+			API_ERROR_CODE_NOT_IMPLEMENTED:        "l'api n'est pas encore implémentée",
+			API_ERROR_CODE_INVALID_TRANSITION:     "changement de statut non autorisé par le workflow",
+			API_ERROR_CODE_UNSUPPORTED_MEDIA_TYPE: "type de contenu ou jeu de caractères non pris en charge",
+			API_ERROR_CODE_REQUEST_TOO_LARGE:      "corps de la requête trop volumineux",
+			API_ERROR_CODE_MALFORMED_JSON:         "le corps de la requête n'est pas du json valide",
+			API_ERROR_CODE_UNKNOWN_FIELD:          "le corps de la requête contient un champ inconnu",
+			API_ERROR_CODE_DUPLICATE_FIELD:        "le corps de la requête contient un champ en double",
+			API_ERROR_CODE_MISSING_FIELD:          "il manque un champ obligatoire dans le corps de la requête",
+			API_ERROR_CODE_INVALID_FIELD_TYPE:     "le corps de la requête contient un champ du mauvais type",
+			API_ERROR_CODE_IDEMPOTENCY_KEY_REUSED: "la clé d'idempotence a déjà servi pour une autre requête",
+			API_ERROR_CODE_PATCH_TEST_FAILED:      "une opération test du patch a échoué",
+		},
+		Messages: map[string]i18n.Message{
+			msgLoginSuccessful:            {Other: "Connexion réussie"},
+			msgUserRegistered:             {Other: "Inscription réussie."},
+			msgUserNotFound:               {Other: "Utilisateur introuvable"},
+			msgUserNotFoundLegacy:         {Other: "Utilisateur introuvable."},
+			msgItemNotFound:               {Other: "Élément introuvable"},
+			msgItemAlreadyExists:          {Other: "L'élément existe déjà"},
+			msgItemAdded:                  {Other: "Élément ajouté"},
+			msgItemModified:               {Other: "Élément modifié"},
+			msgItemRemoved:                {Other: "Élément supprimé"},
+			msgTagNotFound:                {Other: "Étiquette introuvable"},
+			msgTagAlreadyExists:           {Other: "L'étiquette existe déjà"},
+			msgTagsFetchComplete:          {Other: "Étiquettes récupérées"},
+			msgSearchComplete:             {Other: "Recherche terminée"},
+			msgBatchComplete:              {Other: "Lot terminé"},
+			msgIdempotencyKeyTooLong:      {Other: "Idempotency-Key est trop longue"},
+			msgIdempotencyKeyReused:       {Other: "Idempotency-Key a déjà servi pour une autre requête"},
+			msgIdempotencyKeyInProgress:   {Other: "Une requête avec cette Idempotency-Key est encore en cours de traitement"},
+			msgPatchTestFailed:            {Other: "Le test du patch a échoué"},
+			msgPatchCantBeApplied:         {Other: "Le patch ne peut pas être appliqué"},
+			msgPatchedItemNotObject:       {Other: "L'élément patché n'est pas un objet"},
+			msgProtectedFields:            {Other: "Les champs protégés ne peuvent pas être modifiés"},
+			msgInvalidRecurrenceRule:      {Other: "règle de récurrence invalide"},
+			msgUnknownFeedToken:           {Other: "Jeton de calendrier inconnu"},
+			msgCalendarFeedCreated:        {Other: "Calendrier créé"},
+			msgCalendarFeedRevoked:        {Other: "Calendrier révoqué"},
+			msgCalendarCantBeParsed:       {Other: "Impossible de lire le calendrier"},
+			msgComponentNotImportable:     {Other: "Impossible d'importer le composant du calendrier"},
+			msgNotICalendar:               {Other: "Ce n'est pas un iCalendar"},
+			msgImportComplete:             {Other: "Importation terminée"},
+			msgImportChecked:              {Other: "Importation vérifiée"},
+			msgImportCantBeRead:           {Other: "Impossible de lire l'importation"},
+			msgRecordCantBeImported:       {Other: "Impossible d'importer l'enregistrement"},
+			msgDataRequestAccepted:        {Other: "Demande de données acceptée"},
+			msgDataRequestFetchComplete:   {Other: "Demande de données récupérée"},
+			msgDataRequestsFetchComplete:  {Other: "Demandes de données récupérées"},
+			msgDataRequestNotFound:        {Other: "Demande de données introuvable"},
+			msgArchiveNotReady:            {Other: "L'archive n'est pas encore prête"},
+			msgErasureNotConfirmed:        {Other: "L'effacement n'est pas confirmé"},
+			msgUnknownDataRequestType:     {Other: "Type de demande de données inconnu"},
+			msgDataRequestInProgress:      {Other: "Une demande de données de ce type est déjà en cours"},
+			msgStartingUp:                 {Other: "Démarrage en cours, réessayez sous peu"},
+			msgReady:                      {Other: "Prêt"},
+			msgNotReady:                   {Other: "Pas prêt"},
+			msgResourceNotFound:           {Other: "Ressource introuvable"},
+			msgOtherUsersCalendars:        {Other: "Les calendriers des autres utilisateurs ne sont pas accessibles"},
+			msgAuthenticationRequired:     {Other: "Authentification requise"},
+			msgItemHasChanged:             {Other: "L'élément a changé"},
+			msgUIDMismatch:                {Other: "L'UID ne correspond pas au nom de la ressource"},
+			msgRequestBodyNotXML:          {Other: "Le corps de la requête n'est pas du XML"},
+			msgUnableToProcess:            {Other: "Impossible de traiter la requête."},
+			msgInternalServerError:        {Other: "Une erreur interne du serveur s'est produite"},
+			msgMethodNotSupported:         {Other: "Méthode HTTP non prise en charge"},
+			msgItemsFound:                 {One: "%d élément trouvé", Other: "%d éléments trouvés"},
+			msgItemsFetchComplete:         {Other: "Éléments récupérés"},
+			msgTokenVerified:              {Other: "Jeton %s vérifié"},
+			msgInvalidTransition:          {Other: "impossible de passer du statut %s à %s"},
+			msgUnknownBatchOperation:      {Other: "opération inconnue %s"},
+			msgBatchTooLarge:              {Other: "un lot peut contenir au plus %d opérations"},
+			msgBatchRolledBack:            {Other: "Lot annulé, l'opération %d a échoué"},
+			msgRolledBack:                 {Other: "annulée"},
+			msgBodyMissesMembers:          {Other: "il manque des membres obligatoires au corps json."},
+			msgBodyLargerThan:             {Other: "le corps json dépasse %d octets."},
+			msgBodyEmpty:                  {Other: "le corps json est vide."},
+			msgBodyTrailingData:           {Other: "le corps json contient des données après la position %d."},
+			msgBodyDuplicateMember:        {Other: "le corps json contient un membre plusieurs fois."},
+			msgBodyUnknownMembers:         {Other: "le corps json contient des membres inconnus."},
+			msgBodyMalformed:              {Other: "corps json mal formé à la position %d : %v"},
+			msgBodyWrongType:              {Other: "le corps json contient des membres du mauvais type."},
+			msgBodyIncomplete:             {Other: "le corps json est incomplet."},
+			msgBodyTooLarge:               {Other: "le corps json est trop volumineux."},
+			msgBodyUnreadable:             {Other: "Impossible de lire le corps de la requête."},
+			msgRequirementsNotMet:         {Other: "La requête ne remplit pas les conditions."},
+			msgUnsupportedContentType:     {Other: "Type de contenu de la requête non pris en charge."},
+			msgRequestBodyTooLarge:        {Other: "Le corps de la requête est trop volumineux."},
+			msgInvalidTagName:             {Other: "Nom d'étiquette non valide"},
+			msgInvalidTagColor:            {Other: "la couleur doit être de la forme #rrggbb"},
+			msgTagAdded:                   {Other: "Étiquette ajoutée"},
+			msgTagModified:                {Other: "Étiquette modifiée"},
+			msgTagRemoved:                 {Other: "Étiquette supprimée"},
+			msgTagRenamed:                 {Other: "Étiquette renommée"},
+			msgTagsMerged:                 {Other: "Étiquettes fusionnées"},
+			msgTagRemoveFailed:            {Other: "Impossible de supprimer l'étiquette"},
+			msgTagRenameFailed:            {Other: "Impossible de renommer l'étiquette"},
+			msgTagMergeFailed:             {Other: "Impossible de fusionner les étiquettes"},
+			msgNotFound:                   {Other: "introuvable"},
+			msgNotImplemented:             {Other: "non implémenté"},
+			msgUserIDMissing:              {Other: "L'identifiant d'utilisateur n'existe pas"},
+			msgItemFetchComplete:          {Other: "Élément récupéré"},
+			msgItemSaveFailed:             {Other: "Erreur du serveur lors de l'ajout ou de la modification de l'élément."},
+			msgOK:                         {Other: "OK"},
+			msgServerError:                {Other: "Erreur interne du serveur."},
+			msgAuthorizationHeaderMissing: {Other: "L'en-tête d'autorisation obligatoire est absent."},
+			msgUnknownAuthProvider:        {Other: "Fournisseur d'authentification inconnu"},
+			msgTokenExpired:               {Other: "jeton expiré"},
+			msgWorkflowFetchComplete:      {Other: "Flux de travail récupéré"},
+			msgItemRequired:               {Other: "item est obligatoire"},
+			msgIDRequired:                 {Other: "id est obligatoire"},
+			msgUnknownStatus:              {Other: "statut inconnu"},
+			msgUnknownPriority:            {Other: "priorité inconnue"},
+			msgInvalidTags:                {Other: "Étiquettes non valides"},
+			msgInvalidParam:               {Other: "paramètre non valide %s : %s"},
+			msgWebSocketUpgradeFailed:     {Other: "La connexion ne peut pas passer en websocket"},
+			msgAPIVersionInvalid:          {Other: "version d'api non valide %s"},
+			msgAPIVersionUnsupported:      {Other: "la version d'api %d n'est plus prise en charge"},
+			msgAPIVersionUnknown:          {Other: "la version d'api %d est inconnue"},
+			msgAPIVersionNotServed:        {Other: "la version d'api %d n'est pas servie ici"},
+			msgPostNotShared:              {Other: "Publication non partagée avec l'utilisateur"},
+		},
+	})
+	i18n.Register("de", i18n.Catalog{
+		Codes: map[int64]string{
+			API_ERROR_CODE_OK:                     "API erfolgreich ausgeführt",
+			API_ERROR_CODE_GENERIC_ERROR:          "die Anfrage enthielt Fehler. Prüfen Sie die Antwort-Header",
+			API_ERROR_CODE_TOKEN_EXPIRED:          "Token abgelaufen",
+			API_ERROR_CODE_INVALID_INPUT:          "ungültige Eingabe in der Anfrage",
+			API_ERROR_CODE_INVALID_VERSION:        "API-Version wird nicht unterstützt, bitte die App aktualisieren",
+			API_ERROR_CODE_UNKNOWN_AUTH_PROVIDER:  "unbekannter Authentifizierungsanbieter",
+			API_ERROR_CODE_INVALID_PUBLIC_CERT:    "ungültiges Format des öffentlichen Zertifikats",
+			API_ERROR_CODE_INVALID_RSA_KEY:        "ungültiges Format des RSA-Schlüssels",
+			API_ERROR_CODE_NOT_IMPLEMENTED:        "die API ist derzeit nicht implementiert",
+			API_ERROR_CODE_INVALID_TRANSITION:     "Statuswechsel vom Workflow nicht erlaubt",
+			API_ERROR_CODE_UNSUPPORTED_MEDIA_TYPE: "Inhaltstyp oder Zeichensatz wird nicht unterstützt",
+			API_ERROR_CODE_REQUEST_TOO_LARGE:      "Anfragetext zu groß",
+			API_ERROR_CODE_MALFORMED_JSON:         "der Anfragetext ist kein gültiges JSON",
+			API_ERROR_CODE_UNKNOWN_FIELD:          "der Anfragetext enthält ein unbekanntes Feld",
+			API_ERROR_CODE_DUPLICATE_FIELD:        "der Anfragetext enthält ein Feld mehrfach",
+			API_ERROR_CODE_MISSING_FIELD:          "im Anfragetext fehlt ein Pflichtfeld",
+			API_ERROR_CODE_INVALID_FIELD_TYPE:     "der Anfragetext enthält ein Feld mit falschem Typ",
+			API_ERROR_CODE_IDEMPOTENCY_KEY_REUSED: "der Idempotenzschlüssel wurde für eine andere Anfrage verwendet",
+			API_ERROR_CODE_PATCH_TEST_FAILED:      "eine Test-Operation des Patches ist fehlgeschlagen",
+		},
+		Messages: map[string]i18n.Message{
+			msgLoginSuccessful:            {Other: "Anmeldung erfolgreich"},
+			msgUserRegistered:             {Other: "Registrierung erfolgreich."},
+			msgUserNotFound:               {Other: "Benutzer nicht gefunden"},
+			msgUserNotFoundLegacy:         {Other: "Benutzer nicht gefunden."},
+			msgItemNotFound:               {Other: "Eintrag nicht gefunden"},
+			msgItemAlreadyExists:          {Other: "Eintrag existiert bereits"},
+			msgItemAdded:                  {Other: "Eintrag hinzugefügt"},
+			msgItemModified:               {Other: "Eintrag geändert"},
+			msgItemRemoved:                {Other: "Eintrag entfernt"},
+			msgTagNotFound:                {Other: "Schlagwort nicht gefunden"},
+			msgTagAlreadyExists:           {Other: "Schlagwort existiert bereits"},
+			msgTagsFetchComplete:          {Other: "Schlagwörter abgerufen"},
+			msgSearchComplete:             {Other: "Suche abgeschlossen"},
+			msgBatchComplete:              {Other: "Stapel abgeschlossen"},
+			msgIdempotencyKeyTooLong:      {Other: "Idempotency-Key ist zu lang"},
+			msgIdempotencyKeyReused:       {Other: "Idempotency-Key wurde bereits für eine andere Anfrage verwendet"},
+			msgIdempotencyKeyInProgress:   {Other: "Eine Anfrage mit diesem Idempotency-Key wird noch bearbeitet"},
+			msgPatchTestFailed:            {Other: "Der Test des Patches ist fehlgeschlagen"},
+			msgPatchCantBeApplied:         {Other: "Der Patch kann nicht angewendet werden"},
+			msgPatchedItemNotObject:       {Other: "Das gepatchte Element ist kein Objekt"},
+			msgProtectedFields:            {Other: "Geschützte Felder können nicht geändert werden"},
+			msgInvalidRecurrenceRule:      {Other: "ungültige Wiederholungsregel"},
+			msgUnknownFeedToken:           {Other: "Unbekanntes Kalender-Token"},
+			msgCalendarFeedCreated:        {Other: "Kalender erstellt"},
+			msgCalendarFeedRevoked:        {Other: "Kalender widerrufen"},
+			msgCalendarCantBeParsed:       {Other: "Kalender kann nicht gelesen werden"},
+			msgComponentNotImportable:     {Other: "Kalenderkomponente kann nicht importiert werden"},
+			msgNotICalendar:               {Other: "Kein iCalendar"},
+			msgImportComplete:             {Other: "Import abgeschlossen"},
+			msgImportChecked:              {Other: "Import geprüft"},
+			msgImportCantBeRead:           {Other: "Import kann nicht gelesen werden"},
+			msgRecordCantBeImported:       {Other: "Datensatz kann nicht importiert werden"},
+			msgDataRequestAccepted:        {Other: "Datenanfrage angenommen"},
+			msgDataRequestFetchComplete:   {Other: "Datenanfrage abgerufen"},
+			msgDataRequestsFetchComplete:  {Other: "Datenanfragen abgerufen"},
+			msgDataRequestNotFound:        {Other: "Datenanfrage nicht gefunden"},
+			msgArchiveNotReady:            {Other: "Archiv ist noch nicht fertig"},
+			msgErasureNotConfirmed:        {Other: "Löschung ist nicht bestätigt"},
+			msgUnknownDataRequestType:     {Other: "Unbekannte Art von Datenanfrage"},
+			msgDataRequestInProgress:      {Other: "Eine Datenanfrage dieser Art läuft bereits"},
+			msgStartingUp:                 {Other: "Wird gestartet, bitte gleich erneut versuchen"},
+			msgReady:                      {Other: "Bereit"},
+			msgNotReady:                   {Other: "Nicht bereit"},
+			msgResourceNotFound:           {Other: "Ressource nicht gefunden"},
+			msgOtherUsersCalendars:        {Other: "Auf Kalender anderer Benutzer kann nicht zugegriffen werden"},
+			msgAuthenticationRequired:     {Other: "Authentifizierung erforderlich"},
+			msgItemHasChanged:             {Other: "Eintrag wurde geändert"},
+			msgUIDMismatch:                {Other: "UID passt nicht zum Ressourcennamen"},
+			msgRequestBodyNotXML:          {Other: "Anfragetext ist kein XML"},
+			msgUnableToProcess:            {Other: "Anfrage konnte nicht verarbeitet werden."},
+			msgInternalServerError:        {Other: "Ein interner Serverfehler ist aufgetreten"},
+			msgMethodNotSupported:         {Other: "HTTP-Methode wird nicht unterstützt"},
+			msgItemsFound:                 {One: "%d Eintrag gefunden", Other: "%d Einträge gefunden"},
+			msgItemsFetchComplete:         {Other: "Einträge abgerufen"},
+			msgTokenVerified:              {Other: "%s-Token überprüft"},
+			msgInvalidTransition:          {Other: "Status kann nicht von %s zu %s geändert werden"},
+			msgUnknownBatchOperation:      {Other: "unbekannte Operation %s"},
+			msgBatchTooLarge:              {Other: "ein Stapel darf höchstens %d Operationen enthalten"},
+			msgBatchRolledBack:            {Other: "Stapel zurückgesetzt, Operation %d fehlgeschlagen"},
+			msgRolledBack:                 {Other: "zurückgesetzt"},
+			msgBodyMissesMembers:          {Other: "dem json-Body fehlen erforderliche Mitglieder."},
+			msgBodyLargerThan:             {Other: "der json-Body ist größer als %d Bytes."},
+			msgBodyEmpty:                  {Other: "der json-Body ist leer."},
+			msgBodyTrailingData:           {Other: "der json-Body hat Daten nach Position %d."},
+			msgBodyDuplicateMember:        {Other: "der json-Body enthält ein Mitglied mehrfach."},
+			msgBodyUnknownMembers:         {Other: "der json-Body enthält unbekannte Mitglieder."},
+			msgBodyMalformed:              {Other: "fehlerhafter json-Body an Position %d: %v"},
+			msgBodyWrongType:              {Other: "der json-Body enthält Mitglieder vom falschen Typ."},
+			msgBodyIncomplete:             {Other: "der json-Body ist unvollständig."},
+			msgBodyTooLarge:               {Other: "der json-Body ist zu groß."},
+			msgBodyUnreadable:             {Other: "Der Anfrage-Body konnte nicht gelesen werden."},
+			msgRequirementsNotMet:         {Other: "Die Anfrage erfüllt die Anforderungen nicht."},
+			msgUnsupportedContentType:     {Other: "Nicht unterstützter Inhaltstyp der Anfrage."},
+			msgRequestBodyTooLarge:        {Other: "Der Anfrage-Body ist zu groß."},
+			msgInvalidTagName:             {Other: "Ungültiger Tag-Name"},
+			msgInvalidTagColor:            {Other: "die Farbe muss die Form #rrggbb haben"},
+			msgTagAdded:                   {Other: "Tag hinzugefügt"},
+			msgTagModified:                {Other: "Tag geändert"},
+			msgTagRemoved:                 {Other: "Tag entfernt"},
+			msgTagRenamed:                 {Other: "Tag umbenannt"},
+			msgTagsMerged:                 {Other: "Tags zusammengeführt"},
+			msgTagRemoveFailed:            {Other: "Der Tag konnte nicht entfernt werden"},
+			msgTagRenameFailed:            {Other: "Der Tag konnte nicht umbenannt werden"},
+			msgTagMergeFailed:             {Other: "Die Tags konnten nicht zusammengeführt werden"},
+			msgNotFound:                   {Other: "nicht gefunden"},
+			msgNotImplemented:             {Other: "nicht implementiert"},
+			msgUserIDMissing:              {Other: "Die Benutzer-ID existiert nicht"},
+			msgItemFetchComplete:          {Other: "Eintrag abgerufen"},
+			msgItemSaveFailed:             {Other: "Serverfehler beim Hinzufügen oder Ändern des Eintrags."},
+			msgOK:                         {Other: "OK"},
+			msgServerError:                {Other: "Interner Serverfehler."},
+			msgAuthorizationHeaderMissing: {Other: "Der erforderliche Authorization-Header fehlt."},
+			msgUnknownAuthProvider:        {Other: "Unbekannter Authentifizierungsanbieter"},
+			msgTokenExpired:               {Other: "abgelaufenes Token"},
+			msgWorkflowFetchComplete:      {Other: "Workflow abgerufen"},
+			msgItemRequired:               {Other: "item ist erforderlich"},
+			msgIDRequired:                 {Other: "id ist erforderlich"},
+			msgUnknownStatus:              {Other: "unbekannter Status"},
+			msgUnknownPriority:            {Other: "unbekannte Priorität"},
+			msgInvalidTags:                {Other: "Ungültige Tags"},
+			msgInvalidParam:               {Other: "ungültiger Parameter %s: %s"},
+			msgWebSocketUpgradeFailed:     {Other: "Die Verbindung kann nicht zu einem Websocket werden"},
+			msgAPIVersionInvalid:          {Other: "ungültige API-Version %s"},
+			msgAPIVersionUnsupported:      {Other: "API-Version %d wird nicht mehr unterstützt"},
+			msgAPIVersionUnknown:          {Other: "API-Version %d ist unbekannt"},
+			msgAPIVersionNotServed:        {Other: "API-Version %d wird hier nicht bedient"},
+			msgPostNotShared:              {Other: "Beitrag nicht mit dem Benutzer geteilt"},
+		},
+	})
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"todolist/i18n"
+)
+
+var locales = []string{i18n.DefaultLocale, "es", "fr", "de"}
+
+//messageFields are the fields of the struct literals holding
+//the message of a response.
+var messageFields = map[string]string{
+	"responses.Response": "Message",
+	"bodyError":          "message",
+}
+
+//messagePackage is the parsed source of the package, with what
+//the message checks need of it.
+type messagePackage struct {
+	fset   *token.FileSet
+	files  []*ast.File
+	consts map[string]string
+	//sinks are the functions taking a message, with the index
+	//of the parameter it is.
+	sinks map[string]int
+}
+
+func parseMessagePackage(t *testing.T) *messagePackage {
+	names, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &messagePackage{fset: token.NewFileSet(), consts: map[string]string{}, sinks: map[string]int{}}
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(pkg.fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg.files = append(pkg.files, file)
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				pkg.addConsts(d)
+			case *ast.FuncDecl:
+				pkg.addSink(d)
+			}
+		}
+	}
+	return pkg
+}
+
+func (pkg *messagePackage) addConsts(decl *ast.GenDecl) {
+	if decl.Tok != token.CONST {
+		return
+	}
+	for _, spec := range decl.Specs {
+		value := spec.(*ast.ValueSpec)
+		for idx, name := range value.Names {
+			if idx >= len(value.Values) {
+				continue
+			}
+			if lit, ok := value.Values[idx].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				pkg.consts[name.Name], _ = strconv.Unquote(lit.Value)
+			}
+		}
+	}
+}
+
+func (pkg *messagePackage) addSink(decl *ast.FuncDecl) {
+	if decl.Recv != nil {
+		return
+	}
+	idx := 0
+	for _, field := range decl.Type.Params.List {
+		for _, name := range field.Names {
+			if name.Name == "message" {
+				pkg.sinks[decl.Name.Name] = idx
+			}
+			idx++
+		}
+	}
+}
+
+func typeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return typeName(e.X) + "." + e.Sel.Name
+	}
+	return ""
+}
+
+//check reports message unless it is an id in every catalog,
+//something holding one or the translation of one.
+func (pkg *messagePackage) check(t *testing.T, fn *ast.FuncDecl, message ast.Expr, seen map[string]bool) {
+	where := pkg.fset.Position(message.Pos())
+	switch e := message.(type) {
+	case *ast.BasicLit:
+		value, _ := strconv.Unquote(e.Value)
+		if value != "" {
+			checkID(t, where, value)
+		}
+	case *ast.Ident:
+		if value, ok := pkg.consts[e.Name]; ok {
+			checkID(t, where, value)
+			return
+		}
+		if seen[e.Name] {
+			return
+		}
+		seen[e.Name] = true
+		//A variable, what's assigned to it is checked instead.
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			assign, ok := node.(*ast.AssignStmt)
+			if !ok || len(assign.Lhs) != len(assign.Rhs) {
+				return true
+			}
+			for idx, lhs := range assign.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok && ident.Name == e.Name {
+					pkg.check(t, fn, assign.Rhs[idx], seen)
+				}
+			}
+			return true
+		})
+	case *ast.SelectorExpr:
+		if e.Sel.Name != "Message" && e.Sel.Name != "message" {
+			t.Errorf("%s: message %s isn't a message", where, typeName(e))
+		}
+	case *ast.CallExpr:
+		switch typeName(e.Fun) {
+		case "translate", "pluralMessage", "i18n.Translate", "i18n.Plural":
+			pkg.check(t, fn, e.Args[1], seen)
+		default:
+			t.Errorf("%s: message is what %s returns, not an id", where, typeName(e.Fun))
+		}
+	default:
+		t.Errorf("%s: message isn't an id", where)
+	}
+}
+
+func checkID(t *testing.T, where token.Position, id string) {
+	var missing []string
+	for _, locale := range locales {
+		if i18n.Translate(locale, id) == id {
+			missing = append(missing, locale)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("%s: %q has no catalog entry in %v", where, id, missing)
+	}
+}
+
+//TestMessagesAreTranslated checks every message a response is
+//given, through the functions taking one or in the response
+//itself, is in the catalogs.
+func TestMessagesAreTranslated(t *testing.T) {
+	pkg := parseMessagePackage(t)
+	for _, file := range pkg.files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(node ast.Node) bool {
+				switch n := node.(type) {
+				case *ast.CallExpr:
+					if idx, ok := pkg.sinks[typeName(n.Fun)]; ok && idx < len(n.Args) {
+						pkg.check(t, fn, n.Args[idx], map[string]bool{})
+					}
+				case *ast.CompositeLit:
+					field, ok := messageFields[typeName(n.Type)]
+					if !ok {
+						return true
+					}
+					for _, elt := range n.Elts {
+						kv, ok := elt.(*ast.KeyValueExpr)
+						if ok && typeName(kv.Key) == field {
+							pkg.check(t, fn, kv.Value, map[string]bool{})
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+//TestCatalogsAreComplete checks every message id is in every
+//catalog.
+func TestCatalogsAreComplete(t *testing.T) {
+	pkg := parseMessagePackage(t)
+	for name, id := range pkg.consts {
+		if strings.HasPrefix(name, "msg") {
+			checkID(t, token.Position{Filename: name}, id)
+		}
+	}
+}
@@ -1,16 +1,15 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/http"
+	"strings"
 	"time"
-	"todolist/database"
-	"todolist/environment"
+	"todolist/events"
 	"todolist/handlers/token"
+	"todolist/logging"
 	"todolist/model"
 	"todolist/responses"
 	"todolist/tptverify"
@@ -19,52 +18,23 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+//Login checks the userID and password provided against
+//the database.
 func Login(w http.ResponseWriter, r *http.Request) {
-	//Get a Database connection and check for the
-	//userID and password provided.
-
-	if redirectToHTTPS(&w, r) ||
-		!checkRequestMethod(&w, r, http.MethodPost) ||
-		!checkRequestHeaders(&w, r) {
-		return
-	}
-
-	bytes, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		GenericInternalServerHeader(&w, r)
-		return
-	}
-
 	user := &model.User{}
 	//Try to fill in the empty user struct from the
 	//json recieved.
-	err = json.Unmarshal(bytes, user)
-	if err != nil {
-		GenericBadRequest(&w, "json body contains unidentified members.")
-		return
-	}
-	connection, err := database.GetMongoConnection(environment.GetMongoConnectionString())
-	if err != nil {
-		log.Printf("Error getting mongo connection")
-		GenericInternalServerError(&w, "Internal server error")
+	if !decodeJSONBody(&w, r, user) {
 		return
 	}
-	//defer statements execute when the function returns.
-	//defer calls are stacked but the arguments are evaluated
-	//when a defer statement is encountered and NOT when it's
-	//executed. Thus connection here is already evaluated but
-	//function call is made when function returns. Changing
-	//connection variable post the defer statement WILL NOT
-	//cause the function to use the new value.
-	defer database.ReleaseMongoConnection(connection)
-	realUser := model.GetUser(connection, user.ID, user.Password)
+	realUser := model.GetUser(r.Context(), RequestDatabase(r), user.ID, user.Password)
 	if realUser == nil {
-		GenericBadRequest(&w, "User not found.")
+		GenericBadRequest(&w, msgUserNotFoundLegacy)
 		return
 	}
 	response := responses.Response{
 		Status:  http.StatusOK,
-		Message: "Login Successful",
+		Message: msgLoginSuccessful,
 	}
 	bearerToken, err := token.GenerateToken(user, user.SignInType)
 	if err != nil {
@@ -78,36 +48,26 @@ out:
 }
 
 func Register(w http.ResponseWriter, r *http.Request) {
-
-	if redirectToHTTPS(&w, r) ||
-		!checkRequestMethod(&w, r, http.MethodPost) ||
-		!checkRequestHeaders(&w, r) {
-		return
-	}
-
-	bytes, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		GenericInternalServerHeader(&w, r)
-		return
-	}
 	user := &model.User{}
-	err = json.Unmarshal(bytes, user)
-	if err != nil {
-		GenericBadRequest(&w, "json body contains unidentified members.")
+	if !decodeJSONBody(&w, r, user) {
 		return
 	}
 	user.SignInType = model.WebLogin
-	connection, err := database.GetMongoConnection(environment.GetMongoConnectionString())
-	if err != nil {
-		GenericInternalServerHeader(&w, r)
-		return
-	}
-	defer database.ReleaseMongoConnection(connection)
-	if model.AddUser(connection, user) {
-		GenericResponse(&w, "User Registration Successful.", http.StatusOK)
+	if model.AddUser(r.Context(), RequestDatabase(r), user) {
+		GenericResponse(&w, msgUserRegistered, http.StatusOK)
 		return
 	}
-	GenericInternalServerError(&w, "Internal server error.")
+	GenericInternalServerError(&w, msgServerError)
+}
+
+//deviceInfo is what apps tell about the device when they
+//verify a third party token.
+type deviceInfo struct {
+	Client       string `json:"client"`
+	Os           string `json:"os"`
+	Board        string `json:"board"`
+	Manufacturer string `json:"manufacturer"`
+	Model        string `json:"model"`
 }
 
 //TPTVerify endpoint verifies a third party generated token,
@@ -115,80 +75,48 @@ func Register(w http.ResponseWriter, r *http.Request) {
 //same and short the actual work is done in the type that implements
 //the Verifier interface.
 func TPTVerify(w http.ResponseWriter, r *http.Request) {
-	expected := struct {
-		Client       string `json:"client"`
-		Os           string `json:"os"`
-		Board        string `json:"board"`
-		Manufacturer string `json:"manufacturer"`
-		Model        string `json:"model"`
-	}{}
-	if redirectToHTTPS(&w, r) ||
-		!checkRequestMethod(&w, r, http.MethodPost) ||
-		!checkRequestHeaders(&w, r) {
-		log.Print("Missing headers\n")
-		return
-	}
+	expected := deviceInfo{}
 	bearerToken := token.GetBearerToken(r)
-
-	bytes, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		GenericInternalServerHeader(&w, r)
-		return
-	}
-	err = json.Unmarshal(bytes, &expected)
-	if err != nil {
-		GenericBadRequest(&w, "json body contains unidentified members.")
+	if !decodeJSONBody(&w, r, &expected) {
 		return
 	}
 	authProvider, err := utils.GetRequestHeader(r, "X-Resource-Auth")
 	if err != nil {
-		GenericBadRequest(&w, "Required Authorization header missing.")
+		GenericBadRequest(&w, msgAuthorizationHeaderMissing)
 		return
 	}
 	provider, err := tptverify.GetVerifier(authProvider)
 	if err != nil {
-		GenericResponseWithEC(&w, "Unknown auth provider",
+		GenericResponseWithEC(&w, msgUnknownAuthProvider,
 			http.StatusBadRequest, API_ERROR_CODE_INVALID_INPUT)
-		log.Printf("Error = %s", err)
+		logging.FromContext(r.Context()).Warnf("Error = %s", err)
 		return
 	}
-	claims, err := provider.Verify(bearerToken)
+	claims, err := verifyToken(r.Context(), provider, bearerToken)
 	if err != nil {
-		GenericResponseWithEC(&w, "expired token",
+		GenericResponseWithEC(&w, msgTokenExpired,
 			http.StatusBadRequest, API_ERROR_CODE_TOKEN_EXPIRED)
-		log.Printf("Error = %s", err)
+		logging.FromContext(r.Context()).Warnf("Error = %s", err)
 		return
 	}
 	responseMap := provider.ResponseMap(claims)
 	response := responses.Response{
-		Status:  http.StatusOK,
-		Message: fmt.Sprintf("Verified %s Token", provider.Name()),
-		Meta:    responseMap,
-	}
-	connection, err := database.GetMongoConnection(environment.GetMongoConnectionString())
-	if err != nil {
-		log.Printf("Error getting mongo connection")
-		GenericInternalServerError(&w, "Internal server error")
-		return
+		Status:      http.StatusOK,
+		Message:     msgTokenVerified,
+		MessageArgs: []interface{}{provider.Name()},
+		Meta:        responseMap,
 	}
-	//defer statements execute when the function returns.
-	//defer calls are stacked but the arguments are evaluated
-	//when a defer statement is encountered and NOT when it's
-	//executed. Thus connection here is already evaluated but
-	//function call is made when function returns. Changing
-	//connection variable post the defer statement WILL NOT
-	//cause the function to use the new value.
-	defer database.ReleaseMongoConnection(connection)
+	connection := RequestDatabase(r)
 	rand := rand.New(rand.NewSource(time.Now().Unix()))
 	userid, err := provider.UserId(claims)
 	if err != nil {
-		log.Printf("Error getting userid, err = %v\n", err)
-		GenericInternalServerError(&w, "Internal server error")
+		logging.FromContext(r.Context()).Errorf("Error getting userid, err = %v", err)
+		GenericInternalServerError(&w, msgServerError)
 		return
 	}
-	user := model.GetUserForId(connection, userid)
+	user := model.GetUserForId(r.Context(), connection, userid)
 	if user != nil {
-		log.Printf("User with id %s already registered. From %s\n",
+		logging.FromContext(r.Context()).Infof("User with id %s already registered. From %s",
 			userid, provider.Name())
 		goto done
 	}
@@ -198,7 +126,7 @@ func TPTVerify(w http.ResponseWriter, r *http.Request) {
 	user.Meta = responseMap
 	user.Password = fmt.Sprintf("%x", rand.Int63())
 	user.SignInType, _ = model.GetLoginType(authProvider)
-	model.AddUser(connection, user)
+	model.AddUser(r.Context(), connection, user)
 done:
 	w.Header().Add("Authorization", "Bearer "+bearerToken)
 	GenericWriteResponse(&w, &response)
@@ -208,78 +136,152 @@ func User(w http.ResponseWriter, r *http.Request) {
 	GenericWriteHeader(&w, r, http.StatusNotImplemented)
 }
 
-func getUserID(w *http.ResponseWriter, r *http.Request, method string) (bool, string) {
-	var ok bool
-	var userID string
-	var err error
-	if redirectToHTTPS(w, r) ||
-		!checkRequestMethod(w, r, method) ||
-		!checkRequestHeaders(w, r) {
-		log.Print("Missing headers\n")
-		return ok, userID
-	}
-	claims, response := VerifyBearerToken(r)
-	if claims.Claims == nil || claims.Verifier == nil {
-		log.Print("Couldn't verify bearer token\n")
-		GenericWriteResponse(w, &response)
-		return ok, userID
-	}
-	userID, err = claims.UserId(claims.Claims)
-	if err != nil {
-		log.Printf("UserID doesn't exist\n")
-		GenericBadRequest(w, "User ID doesn't exists")
-		return ok, userID
-	}
-	ok = true
-	return ok, userID
-}
-
 func postAddOrModify(w *http.ResponseWriter, r *http.Request, modify bool) {
-	ok, userID := getUserID(w, r, http.MethodPost)
-	if !ok {
-		log.Printf("Error extracting userID from request\n")
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	expected := model.TodoItem{Priority: model.PriorityUnset}
+	if !decodeJSONBody(w, r, &expected) {
 		return
 	}
-	connection, err := database.GetMongoConnection(environment.GetMongoConnectionString())
-	if err != nil {
-		log.Printf("Couldn't get MongoDB Connection\n")
-		GenericInternalServerError(w, "An Internal Server Error occured")
+	//There's no path to take the id from, the body has it.
+	if err := checkItemID(&expected); err != nil {
+		writeBodyError(w, err)
 		return
 	}
-	defer database.ReleaseMongoConnection(connection)
-
-	expected := model.TodoItem{}
-	bytes, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		GenericInternalServerHeader(w, r)
+	user := model.GetUserForId(r.Context(), connection, userID)
+	if user == nil {
+		logging.FromContext(r.Context()).Debugf("User %s not found", userID)
+		GenericResponseWithEC(w, msgUserNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
 		return
 	}
-	err = json.Unmarshal(bytes, &expected)
-	if err != nil {
-		GenericBadRequest(w, "json body contains unidentified members.")
-		return
+	expected.Owner = userID
+	//Status, priority and completion are checked against the
+	//stored item so every collaborator sees the same state.
+	var stored *model.TodoItem
+	var err error
+	if modify {
+		stored, err = model.GetOneTodoItemForOwner(r.Context(), connection, userID, expected.ID)
+		if err != nil {
+			GenericResponseWithEC(w, msgItemNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+			return
+		}
 	}
-	user := model.GetUserForId(connection, userID)
-	if user == nil {
-		log.Printf("User %s not found\n", userID)
-		GenericResponseWithEC(w, "User not found", http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+	if !saveItem(w, r.Context(), connection, &expected, stored) {
 		return
 	}
-	expected.Owner = userID
-	debugText := "add"
-	var op func(*model.TodoItem, *mongo.Client) bool
-	op = (*model.TodoItem).Add
+	message := msgItemAdded
 	if modify {
+		message = msgItemModified
+	}
+	GenericResponse(w, message, http.StatusOK)
+}
+
+//prepareItem normalizes the tags of item, checks its
+//recurrence and applies the workflow, writing the response if
+//any of it fails.
+func prepareItem(w *http.ResponseWriter, item, stored *model.TodoItem) bool {
+	var err error
+	item.Tags, err = model.NormalizeTagNames(item.Tags)
+	if err != nil {
+		writeFieldErrors(w, msgInvalidTags, responses.FieldError{Field: "tags", Reason: err.Error()})
+		return false
+	}
+	if !model.ValidRecurrence(item.Recurrence) {
+		writeFieldErrors(w, msgInvalidRecurrenceRule, responses.FieldError{Field: "recurrence",
+			Reason: "must be an iCalendar RRULE such as FREQ=WEEKLY;BYDAY=MO"})
+		return false
+	}
+	return applyWorkflow(w, item, stored, item.Owner)
+}
+
+//saveItem adds item, or replaces stored with it if stored
+//isn't nil. The item's tags and workflow state are checked
+//first. The response is written only if saving failed.
+func saveItem(w *http.ResponseWriter, ctx context.Context, connection *mongo.Client, item, stored *model.TodoItem) bool {
+	userID := item.Owner
+	if !prepareItem(w, item, stored) {
+		return false
+	}
+	debugText := "add"
+	saved := false
+	if stored == nil {
+		saved = item.Add(ctx, connection)
+	} else {
 		debugText = "modify"
-		op = (*model.TodoItem).Modify
+		//The status may have moved on since stored was read,
+		//the transition would then be unchecked.
+		err := item.Replace(ctx, connection, stored)
+		if err == model.ErrItemChanged {
+			GenericResponseWithEC(w, msgItemHasChanged, http.StatusConflict, API_ERROR_CODE_INVALID_TRANSITION)
+			return false
+		}
+		saved = err == nil
+	}
+	if !saved {
+		logging.FromContext(ctx).Errorf("Couldn't %s ToDo Item for user %s", debugText, userID)
+		GenericInternalServerError(w, msgItemSaveFailed)
+		return false
+	}
+	if !model.RegisterTags(ctx, connection, userID, item.Tags) {
+		logging.FromContext(ctx).Errorf("Couldn't register tags %v for user %s", item.Tags, userID)
+	}
+	logging.FromContext(ctx).Debugf("%s a ToDo Item for user %s", debugText, userID)
+	eventType := events.ItemCreated
+	if stored != nil {
+		eventType = events.ItemUpdated
+		if sharingChanged(item, stored) {
+			eventType = events.ItemShared
+		}
 	}
-	if !op(&expected, connection) {
-		log.Printf("Couldn't %s ToDo Item for user %s", debugText, userID)
-		GenericInternalServerError(w, "A Server Error occured trying to modify / add TodoItem.")
-		return
+	publishItemEvent(ctx, connection, eventType, item, stored)
+	return true
+}
+
+//applyWorkflow writes the response explaining why the status
+//or priority of item was refused, if it was.
+func applyWorkflow(w *http.ResponseWriter, item, stored *model.TodoItem, userID string) bool {
+	err := item.ApplyWorkflow(stored, userID)
+	switch err {
+	case nil:
+		return true
+	case model.ErrInvalidTransition:
+		from := stored.Status
+		if from == "" {
+			from = model.GetWorkflow().Initial
+		}
+		resp := responses.Response{
+			Status:      http.StatusConflict,
+			APICode:     API_ERROR_CODE_INVALID_TRANSITION,
+			Message:     msgInvalidTransition,
+			MessageArgs: []interface{}{from, item.Status},
+			Meta: map[string]interface{}{
+				"allowed": model.GetWorkflow().Transitions[from],
+			},
+		}
+		GenericWriteResponse(w, &resp)
+	case model.ErrInvalidStatus:
+		writeFieldErrors(w, msgUnknownStatus, responses.FieldError{Field: "status", Reason: err.Error()})
+	case model.ErrInvalidPriority:
+		writeFieldErrors(w, msgUnknownPriority, responses.FieldError{Field: "priority", Reason: err.Error()})
+	default:
+		GenericResponseWithEC(w, msgUnableToProcess, http.StatusBadRequest, API_ERROR_CODE_INVALID_INPUT)
+	}
+	return false
+}
+
+//Workflow describes the item states and priorities so that
+//clients don't need to hard code them.
+func Workflow(w http.ResponseWriter, r *http.Request) {
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		APICode: API_ERROR_CODE_OK,
+		Message: msgWorkflowFetchComplete,
+		Meta: map[string]interface{}{
+			"workflow":   model.GetWorkflow(),
+			"priorities": model.PriorityNames,
+		},
 	}
-	log.Printf("%s a ToDo Item for user %s\n", debugText, userID)
-	GenericResponse(w, fmt.Sprintf("%s Todo Item succeeded", debugText), http.StatusOK)
+	GenericWriteResponse(&w, &resp)
 }
 
 //JSON body contains the Post data.
@@ -292,106 +294,155 @@ func PostEdit(w http.ResponseWriter, r *http.Request) {
 	postAddOrModify(&w, r, true)
 }
 
+type itemIDRequest struct {
+	PostID string `json:"id" validate:"required"`
+}
+
 //JSON Body contains the POST ID
 //If the owner ID doesn't match this user's ID
 //then the post is attempted to be removed from the
 //shared with.
 func PostRemove(w http.ResponseWriter, r *http.Request) {
-	ok, userID := getUserID(&w, r, http.MethodPost)
-	if !ok {
-		log.Printf("Error extracting userID from request\n")
+	expected := itemIDRequest{}
+	if !decodeJSONBody(&w, r, &expected) {
 		return
 	}
-	connection, err := database.GetMongoConnection(environment.GetMongoConnectionString())
-	if err != nil {
-		log.Printf("Couldn't get MongoDB Connection\n")
-		GenericInternalServerError(&w, "An Internal Server Error occured")
+	if !removeItem(&w, r.Context(), RequestDatabase(r), RequestUserID(r), expected.PostID) {
 		return
 	}
-	defer database.ReleaseMongoConnection(connection)
-	expected := struct {
-		PostID string `json:"id"`
-	}{}
-	bytes, err := ioutil.ReadAll(r.Body)
+	GenericResponse(&w, msgItemRemoved, http.StatusOK)
+}
+
+//removeItem removes an item the user owns, or the user from
+//the sharing list of an item shared with it. The response is
+//written only if neither worked, a 400 as it always was to the
+//legacy API and a 404 to later versions.
+func removeItem(w *http.ResponseWriter, ctx context.Context, connection *mongo.Client, userID, itemID string) bool {
+	dummyPostObj := model.TodoItem{
+		Owner: userID,
+		ID:    itemID,
+	}
+	//Whoever the item is shared with hears it's gone.
+	stored, err := model.GetOneTodoItemForOwner(ctx, connection, userID, itemID)
 	if err != nil {
-		GenericInternalServerHeader(&w, r)
+		stored = &dummyPostObj
+	}
+	if dummyPostObj.Remove(ctx, connection) {
+		logging.FromContext(ctx).Debugf("removed ToDo Item %s for user %s", itemID, userID)
+		publishItemEvent(ctx, connection, events.ItemDeleted, stored, nil)
+		return true
+	}
+	logging.FromContext(ctx).Debugf("Item %s not owned by user %s", itemID, userID)
+	shared := model.TodoItem{ID: itemID}
+	if !shared.RemoveFromShared(ctx, connection, userID) {
+		if contextAPIVersion(ctx) == LegacyAPIVersion {
+			GenericBadRequest(w, msgPostNotShared)
+			return false
+		}
+		GenericResponseWithEC(w, msgItemNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+		return false
+	}
+	logging.FromContext(ctx).Debugf("ToDo Item %s no longer shared with user %s", itemID, userID)
+	//It's only gone for the user who no longer shares it, the
+	//owner and the others see who it's shared with change.
+	publishItemEvent(ctx, connection, events.ItemDeleted, &dummyPostObj, nil)
+	publishItemEvent(ctx, connection, events.ItemShared, &shared, nil)
+	return true
+}
+
+//Query parameters restrict, sort and page the items,
+//see parseItemQuery for what's understood.
+func PostGet(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	itemQuery, paramErr := parseItemQuery(r.URL.Query())
+	if paramErr != nil {
+		writeInvalidParam(&w, paramErr)
 		return
 	}
-	err = json.Unmarshal(bytes, &expected)
+	postID, err := utils.GetRequestParam(r, "postid")
 	if err != nil {
-		GenericBadRequest(&w, "json body contains unidentified members.")
-		return
+		logging.FromContext(r.Context()).Debugf("Query parameter postid not found in request.")
 	}
-	dummyPostObj := model.TodoItem{
-		Owner: userID,
-		ID:    expected.PostID,
+	if postID == "" {
+		writeItemPage(&w, r.Context(), connection, userID, itemQuery, true)
+		return
 	}
-	removedShared := false
-	if !dummyPostObj.Remove(connection) {
-		log.Printf("Item %s not owned by user %s", expected.PostID, userID)
-		if !dummyPostObj.RemoveFromShared(connection, userID) {
-			GenericBadRequest(&w, "Post not shared with user")
-			return
-		}
-		removedShared = true
+	page := &model.ItemPage{Total: -1}
+	todoItem, err := model.GetOneTodoItemForOwner(r.Context(), connection, userID, postID)
+	if err == nil {
+		page.Items = append(page.Items, *todoItem)
 	}
-	log.Printf("removed ToDo Item %s for user %s, is shared = %t\n",
-		expected.PostID, userID, removedShared)
-	GenericResponse(&w, "Removed ToDo Item", http.StatusOK)
+	writePage(&w, page, true)
 }
 
-func PostGet(w http.ResponseWriter, r *http.Request) {
-	ok, userID := getUserID(&w, r, http.MethodGet)
-	if !ok {
-		log.Printf("Error extracting userID from request\n")
+//writeItemPage writes the page of items matching itemQuery
+//along with what's needed to fetch the next one. legacy pages
+//keep the message v1 clients have always had.
+func writeItemPage(w *http.ResponseWriter, ctx context.Context, connection *mongo.Client, userID string, itemQuery *model.ItemQuery, legacy bool) {
+	page, err := model.GetOwnerItemsPage(ctx, connection, userID, itemQuery)
+	if err == model.ErrInvalidCursor {
+		writeInvalidParam(w, &invalidParamError{"cursor", err.Error()})
 		return
 	}
-	connection, err := database.GetMongoConnection(environment.GetMongoConnectionString())
 	if err != nil {
-		log.Printf("Couldn't get MongoDB Connection\n")
-		GenericInternalServerError(&w, "An Internal Server Error occured")
+		GenericInternalServerError(w, msgUnableToProcess)
 		return
 	}
-	defer database.ReleaseMongoConnection(connection)
-	getShared := false
-	var off uint
-	var count uint
-	var postID string
-	var items []model.TodoItem
+	writePage(w, page, legacy)
+}
 
-	if shared, err := utils.GetRequestParam(r, "shared"); err == nil {
-		if shared == "1" {
-			getShared = true
-		}
+func writePage(w *http.ResponseWriter, page *model.ItemPage, legacy bool) {
+	meta := map[string]interface{}{
+		"count":    len(page.Items),
+		"items":    page.Items,
+		"has_more": page.HasMore,
 	}
-	if offset, err := utils.GetRequestParam(r, "offset"); err == nil {
-		off = utils.ToUint(offset)
+	if page.NextCursor != "" {
+		meta["next_cursor"] = page.NextCursor
 	}
-	if cnt, err := utils.GetRequestParam(r, "count"); err == nil {
-		count = utils.ToUint(cnt)
+	if page.Total >= 0 {
+		meta["total"] = page.Total
+	}
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		APICode: API_ERROR_CODE_OK,
+		Message: msgItemsFetchComplete,
+		Meta:    meta,
 	}
-	if postID, err = utils.GetRequestParam(r, "postid"); err != nil {
-		log.Printf("Query parameter postid not found in request.")
+	if !legacy {
+		resp.Message = pluralMessage(w, msgItemsFound, len(page.Items))
 	}
-	//get offset and count in request parameter
-	//return count, more and list of items
-	if postID == "" {
-		items, err = model.GetOwnerItems(connection, userID, getShared, off, count)
-	} else {
-		todoItem, err := model.GetOneTodoItemForOwner(connection, userID, postID)
-		if err == nil {
-			items = append(items, *todoItem)
-		}
+	GenericWriteResponse(w, &resp)
+}
+
+//PostSearch does a full text search over the items the user
+//owns or has been shared. q holds the search text and the
+//optional count limits the number of results.
+func PostSearch(w http.ResponseWriter, r *http.Request) {
+	text, err := utils.GetRequestParam(r, "q")
+	if err != nil || strings.TrimSpace(text) == "" {
+		writeInvalidParam(&w, &invalidParamError{"q", "search text is required"})
+		return
+	}
+	if len(text) > maxQueryTextLen {
+		writeInvalidParam(&w, &invalidParamError{"q", fmt.Sprintf("must be at most %d characters", maxQueryTextLen)})
+		return
+	}
+	var count uint
+	if cnt, err := utils.GetRequestParam(r, "count"); err == nil {
+		count = utils.ToUint(cnt)
 	}
+	results, err := model.SearchItems(r.Context(), RequestDatabase(r), RequestUserID(r), text, count)
 	if err != nil {
-		GenericInternalServerError(&w, "Unable to process request.")
+		GenericInternalServerError(&w, msgUnableToProcess)
 		return
 	}
 	resp := responses.Response{
 		Status:  http.StatusOK,
 		APICode: API_ERROR_CODE_OK,
-		Message: "Items fetch complete",
-		Meta:    map[string]interface{}{"count": len(items), "items": items},
+		Message: msgSearchComplete,
+		Meta:    map[string]interface{}{"count": len(results), "results": results},
 	}
 	GenericWriteResponse(&w, &resp)
 }
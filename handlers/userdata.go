@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"todolist/database"
+	"todolist/environment"
+	"todolist/events"
+	"todolist/logging"
+	"todolist/model"
+	"todolist/responses"
+	"todolist/tracing"
+	"todolist/utils"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	dataRequestPath    = "/user/data/requests/"
+	dataRequestIDBytes = 16
+	//dataRequestStale is when a request which never finished,
+	//because the instance working on it went away, no longer
+	//stops a new one.
+	dataRequestStale = 24 * time.Hour
+)
+
+//personalDataReadme is the README.txt of archives.
+const personalDataReadme = `Everything stored about you, as of when the archive was made.
+
+user.json             your account, with what your sign in provider told us;
+                      your password isn't included
+items.json            your items
+shared_with_me.json   the items of others shared with you
+tags.json             your tags
+sessions.json         the calendar apps syncing your lists, and whether you
+                      have a calendar feed; sign in tokens aren't stored
+events.json           recent changes to items which reached you
+data_requests.json    your requests for your data
+`
+
+//dataRequestBody asks for an export or an erasure, which has
+//to be confirmed with the user's id.
+type dataRequestBody struct {
+	Type    string `json:"type" validate:"required"`
+	Confirm string `json:"confirm,omitempty"`
+}
+
+type personalUser struct {
+	ID           string                 `json:"id"`
+	SignIn       string                 `json:"sign_in"`
+	Meta         map[string]interface{} `json:"extra,omitempty"`
+	CalendarFeed bool                   `json:"calendar_feed"`
+}
+
+type sharedMembership struct {
+	Owner string `json:"owner"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+}
+
+type syncSession struct {
+	List  string    `json:"list"`
+	Token string    `json:"token"`
+	Items int       `json:"items"`
+	Time  time.Time `json:"time"`
+}
+
+//DataRequestEndpoints are those of data subject requests,
+//	GET, POST	/user/data/requests
+//	GET		/user/data/requests/{id}
+//	GET		/user/data/requests/{id}/archive
+//Requests are worked on in the background, clients poll them
+//until they're done.
+func DataRequestEndpoints() []Endpoint {
+	return []Endpoint{
+		{Method: http.MethodGet, Pattern: "/user/data/requests", Handler: DataRequestList,
+			Doc: Doc{Summary: "List the user's personal data requests"}},
+		{Method: http.MethodPost, Pattern: "/user/data/requests", Handler: DataRequestCreate,
+			Doc: Doc{Summary: "Ask for a copy of the user's personal data, or for its erasure",
+				Description: "An export makes a zip archive of everything stored about the user. " +
+					"An erasure removes it, confirm has to be the user's id.",
+				Body: dataRequestBody{}, Status: http.StatusAccepted}},
+		{Method: http.MethodGet, Pattern: "/user/data/requests/{id}", Handler: DataRequestGet,
+			Doc: Doc{Summary: "Get a personal data request"}},
+		{Method: http.MethodGet, Pattern: "/user/data/requests/{id}/archive", Handler: DataRequestArchive,
+			Doc: Doc{Summary: "Download the archive of a finished export", Produces: "application/zip"}},
+	}
+}
+
+func writeDataRequest(w *http.ResponseWriter, request *model.DataRequest, httpStatusCode int, message string) {
+	meta := map[string]interface{}{"request": request}
+	if request.Kind == model.DataExport && request.Status == model.DataRequestDone {
+		meta["archive"] = dataRequestPath + request.ID + "/archive"
+	}
+	resp := responses.Response{
+		Status:  httpStatusCode,
+		APICode: API_ERROR_CODE_OK,
+		Message: message,
+		Meta:    meta,
+	}
+	GenericWriteResponse(w, &resp)
+}
+
+func getStoredDataRequest(w *http.ResponseWriter, r *http.Request, connection *mongo.Client, userID string) *model.DataRequest {
+	request, err := model.GetDataRequest(r.Context(), connection, userID, PathParam(r, "id"))
+	if err != nil {
+		GenericResponseWithEC(w, msgDataRequestNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+		return nil
+	}
+	return request
+}
+
+func DataRequestList(w http.ResponseWriter, r *http.Request) {
+	requests, err := model.GetDataRequests(r.Context(), RequestDatabase(r), RequestUserID(r))
+	if err != nil {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	resp := responses.Response{
+		Status:  http.StatusOK,
+		APICode: API_ERROR_CODE_OK,
+		Message: msgDataRequestsFetchComplete,
+		Meta:    map[string]interface{}{"requests": requests},
+	}
+	GenericWriteResponse(&w, &resp)
+}
+
+//DataRequestCreate starts an export or an erasure, unless one
+//of the same type is still being worked on.
+func DataRequestCreate(w http.ResponseWriter, r *http.Request) {
+	userID := RequestUserID(r)
+	connection := RequestDatabase(r)
+	body := dataRequestBody{}
+	if !decodeJSONBody(&w, r, &body) {
+		return
+	}
+	switch body.Type {
+	case model.DataExport:
+	case model.DataErasure:
+		if body.Confirm != userID {
+			writeFieldErrors(&w, msgErasureNotConfirmed,
+				responses.FieldError{Field: "confirm", Reason: "must be your user id"})
+			return
+		}
+	default:
+		writeFieldErrors(&w, msgUnknownDataRequestType,
+			responses.FieldError{Field: "type", Reason: "must be export or erasure"})
+		return
+	}
+	requests, err := model.GetDataRequests(r.Context(), connection, userID)
+	if err != nil {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	for _, request := range requests {
+		if request.Kind == body.Type && request.Active() && time.Since(request.Created) < dataRequestStale {
+			GenericResponseWithEC(&w, msgDataRequestInProgress, http.StatusConflict,
+				API_ERROR_CODE_INVALID_INPUT)
+			return
+		}
+	}
+	random := make([]byte, dataRequestIDBytes)
+	if _, err = rand.Read(random); err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't make a data request id: %v", err)
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	request := &model.DataRequest{
+		ID:      hex.EncodeToString(random),
+		Owner:   userID,
+		Kind:    body.Type,
+		Status:  model.DataRequestPending,
+		Created: time.Now().UTC(),
+	}
+	if !model.AddDataRequest(r.Context(), connection, request) {
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	//The request is worked on after it's answered, only the
+	//logging and tracing of the request go with it.
+	go runDataRequest(utils.Detach(r.Context()), *request)
+	w.Header().Set("Location", dataRequestPath+request.ID)
+	writeDataRequest(&w, request, http.StatusAccepted, msgDataRequestAccepted)
+}
+
+func DataRequestGet(w http.ResponseWriter, r *http.Request) {
+	request := getStoredDataRequest(&w, r, RequestDatabase(r), RequestUserID(r))
+	if request == nil {
+		return
+	}
+	writeDataRequest(&w, request, http.StatusOK, msgDataRequestFetchComplete)
+}
+
+func DataRequestArchive(w http.ResponseWriter, r *http.Request) {
+	connection := RequestDatabase(r)
+	request := getStoredDataRequest(&w, r, connection, RequestUserID(r))
+	if request == nil {
+		return
+	}
+	if request.Kind != model.DataExport {
+		GenericResponseWithEC(&w, msgDataRequestNotFound, http.StatusNotFound, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	if request.Status != model.DataRequestDone {
+		GenericResponseWithEC(&w, msgArchiveNotReady, http.StatusConflict, API_ERROR_CODE_INVALID_INPUT)
+		return
+	}
+	archive, err := model.OpenDataArchive(r.Context(), connection, request)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Couldn't open archive of data request %s: %v", request.ID, err)
+		GenericInternalServerError(&w, msgUnableToProcess)
+		return
+	}
+	defer archive.Close()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="personal-data-%s.zip"`, request.ID))
+	w.Header().Set("Content-Length", strconv.FormatInt(archive.GetFile().Length, 10))
+	if _, err = io.Copy(w, archive); err != nil {
+		logging.FromContext(r.Context()).Warnf("Archive of data request %s cut short: %v", request.ID, err)
+	}
+}
+
+//runDataRequest works on request with a connection of its own,
+//the one of the request which made it is released once that's
+//answered. ctx mustn't be done before the work is, it's only
+//for logging and tracing the work as part of its request.
+func runDataRequest(ctx context.Context, request model.DataRequest) {
+	ctx, span := tracing.Start(ctx, "data request "+request.Kind, tracing.Internal)
+	defer span.End()
+	span.SetAttribute("data_request.id", request.ID)
+	logger := logging.FromContext(ctx)
+	connection, err := database.GetMongoConnectionContext(ctx, environment.GetMongoConnectionString())
+	if err != nil {
+		logger.Errorf("Couldn't get MongoDB Connection for data request %s: %v", request.ID, err)
+		span.SetError(err)
+		return
+	}
+	defer database.ReleaseMongoConnection(connection)
+	request.SetStatus(ctx, connection, model.DataRequestRunning, "")
+	switch request.Kind {
+	case model.DataExport:
+		err = exportPersonalData(ctx, connection, &request)
+	case model.DataErasure:
+		if err = events.EraseUser(ctx, connection, request.Owner); err == nil {
+			err = model.EraseUser(ctx, connection, request.Owner)
+		}
+	}
+	if err != nil {
+		logger.Errorf("The %s request %s of user %s failed: %v", request.Kind, request.ID, request.Owner, err)
+		span.SetError(err)
+		request.SetStatus(ctx, connection, model.DataRequestFailed, "couldn't be completed, make a new request")
+		return
+	}
+	request.SetStatus(ctx, connection, model.DataRequestDone, "")
+	logger.Infof("The %s request %s of user %s is done", request.Kind, request.ID, request.Owner)
+}
+
+//exportPersonalData stores the archive as it's written.
+func exportPersonalData(ctx context.Context, connection *mongo.Client, request *model.DataRequest) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writePersonalData(ctx, writer, connection, request.Owner))
+	}()
+	err := model.SaveDataArchive(ctx, connection, request, reader)
+	//Stops the writer if the archive couldn't be stored.
+	reader.Close()
+	return err
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+//writePersonalData writes the zip archive of everything stored
+//about userID, its README.txt says what's in it.
+func writePersonalData(ctx context.Context, w io.Writer, connection *mongo.Client, userID string) error {
+	user := model.GetUserForId(ctx, connection, userID)
+	if user == nil {
+		return fmt.Errorf("user %s not found", userID)
+	}
+	files := []struct {
+		name  string
+		write func(w io.Writer) error
+	}{
+		{"README.txt", func(w io.Writer) error {
+			_, err := io.WriteString(w, personalDataReadme)
+			return err
+		}},
+		{"user.json", func(w io.Writer) error {
+			return writeIndentedJSON(w, personalUser{ID: user.ID, SignIn: user.SignInType.String(),
+				Meta: user.Meta, CalendarFeed: user.FeedToken != ""})
+		}},
+		{"items.json", func(w io.Writer) error {
+			encoder := &jsonItemEncoder{w: w}
+			if err := encoder.begin(); err != nil {
+				return err
+			}
+			if err := model.EachOwnerItem(ctx, connection, userID, &model.ItemQuery{}, encoder.encode); err != nil {
+				return err
+			}
+			return encoder.end()
+		}},
+		{"shared_with_me.json", func(w io.Writer) error {
+			memberships := []sharedMembership{}
+			err := model.EachSharedItem(ctx, connection, userID, func(item *model.TodoItem) error {
+				memberships = append(memberships, sharedMembership{Owner: item.Owner, ID: item.ID, Name: item.Name})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, memberships)
+		}},
+		{"tags.json", func(w io.Writer) error {
+			tags, err := model.GetTagsForOwner(ctx, connection, userID)
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, tags)
+		}},
+		{"sessions.json", func(w io.Writer) error {
+			states, err := model.GetSyncStates(ctx, connection, userID)
+			if err != nil {
+				return err
+			}
+			sessions := []syncSession{}
+			for _, state := range states {
+				sessions = append(sessions, syncSession{List: state.List, Token: state.Token,
+					Items: len(state.Entries), Time: state.Time})
+			}
+			return writeIndentedJSON(w, map[string]interface{}{
+				"calendar_sync": sessions,
+				"calendar_feed": user.FeedToken != "",
+			})
+		}},
+		{"events.json", func(w io.Writer) error {
+			stored := []events.Event{}
+			err := events.EachStoredEvent(ctx, connection, userID, func(event *events.Event) error {
+				stored = append(stored, *event)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, stored)
+		}},
+		{"data_requests.json", func(w io.Writer) error {
+			requests, err := model.GetDataRequests(ctx, connection, userID)
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, requests)
+		}},
+	}
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if err = file.write(entry); err != nil {
+			return fmt.Errorf("writing %s: %v", file.name, err)
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todolist/environment"
+	"todolist/logging"
+	"todolist/responses"
+)
+
+const (
+	//APIVersionHeader is how clients declare the API version
+	//they were built against, a /vN/ path prefix overrides it.
+	//Clients which don't declare any are on the legacy version.
+	APIVersionHeader = "X-API-Version"
+	LegacyAPIVersion = 1
+	LatestAPIVersion = 2
+)
+
+type apiVersionKey struct{}
+
+var versionPathRegex = regexp.MustCompile("^/v([0-9]+)/")
+
+//versionPolicy is read from the environment once, see
+//environment.APIMinVersion and environment.APIDeprecatedVersions.
+type versionPolicy struct {
+	minVersion int
+	sunsets    map[int]time.Time
+	upgradeURL string
+}
+
+var policyOnce sync.Once
+var policy versionPolicy
+
+func getVersionPolicy() *versionPolicy {
+	policyOnce.Do(func() {
+		policy = versionPolicy{
+			minVersion: LegacyAPIVersion,
+			sunsets:    map[int]time.Time{},
+			upgradeURL: environment.GetAPIUpgradeURL(),
+		}
+		if minVersion, err := parseAPIVersion(environment.GetAPIMinVersion()); err == nil {
+			policy.minVersion = minVersion
+		}
+		for _, entry := range strings.Split(environment.GetAPIDeprecatedVersions(), ",") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			parts := strings.Split(entry, "=")
+			version, err := parseAPIVersion(parts[0])
+			if err != nil || len(parts) != 2 {
+				logging.Warnf("Ignoring deprecated version entry %s", entry)
+				continue
+			}
+			sunset, err := time.Parse("2006-01-02", strings.TrimSpace(parts[1]))
+			if err != nil {
+				logging.Warnf("Ignoring deprecated version entry %s: %v", entry, err)
+				continue
+			}
+			policy.sunsets[version] = sunset
+		}
+	})
+	return &policy
+}
+
+//parseAPIVersion accepts 2 as well as v2.
+func parseAPIVersion(value string) (int, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid api version %s", value)
+	}
+	return version, nil
+}
+
+//requestedAPIVersion is the version the request asks for, with
+//the text it was given as.
+func requestedAPIVersion(r *http.Request) (int, string, error) {
+	value := r.Header.Get(APIVersionHeader)
+	if match := versionPathRegex.FindStringSubmatch(r.URL.Path); match != nil {
+		value = match[1]
+	}
+	if value == "" {
+		return LegacyAPIVersion, "", nil
+	}
+	version, err := parseAPIVersion(value)
+	return version, value, err
+}
+
+//APIVersion is the version the request is being served as.
+func APIVersion(r *http.Request) int {
+	return contextAPIVersion(r.Context())
+}
+
+//contextAPIVersion is APIVersion for what has the request
+//context rather than the request.
+func contextAPIVersion(ctx context.Context) int {
+	version, ok := ctx.Value(apiVersionKey{}).(int)
+	if !ok {
+		return LegacyAPIVersion
+	}
+	return version
+}
+
+func writeInvalidVersion(w *http.ResponseWriter, message string, args ...interface{}) {
+	policy := getVersionPolicy()
+	meta := map[string]interface{}{
+		"min_version":    policy.minVersion,
+		"latest_version": LatestAPIVersion,
+	}
+	if policy.upgradeURL != "" {
+		meta["upgrade_url"] = policy.upgradeURL
+	}
+	resp := responses.Response{
+		Status:      http.StatusBadRequest,
+		APICode:     API_ERROR_CODE_INVALID_VERSION,
+		Message:     message,
+		MessageArgs: args,
+		Meta:        meta,
+	}
+	GenericWriteResponse(w, &resp)
+}
+
+//CheckAPIVersion refuses versions older than the configured
+//minimum, answers deprecated ones with Deprecation and Sunset
+//headers and puts the version into the request context.
+func CheckAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, value, err := requestedAPIVersion(r)
+		if err != nil {
+			writeInvalidVersion(&w, msgAPIVersionInvalid, value)
+			return
+		}
+		policy := getVersionPolicy()
+		if version < policy.minVersion {
+			writeInvalidVersion(&w, msgAPIVersionUnsupported, version)
+			return
+		}
+		if version > LatestAPIVersion {
+			writeInvalidVersion(&w, msgAPIVersionUnknown, version)
+			return
+		}
+		w.Header().Set(APIVersionHeader, strconv.Itoa(version))
+		if sunset, ok := policy.sunsets[version]; ok {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", sunset.Format(http.TimeFormat))
+			if policy.upgradeURL != "" {
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", policy.upgradeURL))
+			}
+		}
+		ctx := context.WithValue(r.Context(), apiVersionKey{}, version)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+//Versioned serves a request with the handler of the highest
+//version not newer than the one CheckAPIVersion settled on.
+func Versioned(byVersion map[int]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version := APIVersion(r)
+		served := 0
+		for candidate := range byVersion {
+			if candidate <= version && candidate > served {
+				served = candidate
+			}
+		}
+		if served == 0 {
+			writeInvalidVersion(&w, msgAPIVersionNotServed, version)
+			return
+		}
+		byVersion[served](w, r)
+	}
+}
@@ -0,0 +1,125 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//DefaultLocale is what everything falls back to, its catalog
+//has the english text of every message.
+const DefaultLocale = "en"
+
+//Message is the translation of one message. One is used when
+//the count of a plural message calls for the singular form,
+//Other in every other case and for messages without a count.
+type Message struct {
+	One   string
+	Other string
+}
+
+//Catalog holds the translations of one locale, descriptions
+//of API codes and messages keyed by their id. Messages may be
+//fmt formats, their arguments are given when translating.
+type Catalog struct {
+	Codes    map[int64]string
+	Messages map[string]Message
+}
+
+var lock sync.RWMutex
+var catalogs = map[string]*Catalog{}
+
+//Register adds the translations in catalog to those of locale,
+//replacing any registered before.
+func Register(locale string, catalog Catalog) {
+	lock.Lock()
+	defer lock.Unlock()
+	locale = strings.ToLower(locale)
+	existing, ok := catalogs[locale]
+	if !ok {
+		existing = &Catalog{Codes: map[int64]string{}, Messages: map[string]Message{}}
+		catalogs[locale] = existing
+	}
+	for code, description := range catalog.Codes {
+		existing.Codes[code] = description
+	}
+	for id, message := range catalog.Messages {
+		existing.Messages[id] = message
+	}
+}
+
+func lookup(locale string) *Catalog {
+	lock.RLock()
+	defer lock.RUnlock()
+	return catalogs[locale]
+}
+
+//Supported tells if there are translations for locale, english
+//always is.
+func Supported(locale string) bool {
+	return locale == DefaultLocale || lookup(locale) != nil
+}
+
+//CodeDescription is the description of an API code in locale,
+//english is the description to use if there's no translation.
+func CodeDescription(locale string, code int64, english string) string {
+	if catalog := lookup(locale); catalog != nil {
+		if description, ok := catalog.Codes[code]; ok {
+			return description
+		}
+	}
+	return english
+}
+
+//message is the message id in locale, or in english if locale
+//has no translation of it. The locale it was found in is
+//returned along with it.
+func message(locale, id string) (Message, string, bool) {
+	for _, candidate := range []string{locale, DefaultLocale} {
+		if catalog := lookup(candidate); catalog != nil {
+			if translated, ok := catalog.Messages[id]; ok && translated.Other != "" {
+				return translated, candidate, true
+			}
+		}
+	}
+	return Message{}, "", false
+}
+
+//Translate returns the message id in locale formatted with
+//args, in english if there's no translation. An id no catalog
+//has is returned as it is, it's taken to be the text itself.
+func Translate(locale, id string, args ...interface{}) string {
+	translated, _, ok := message(locale, id)
+	if !ok {
+		return id
+	}
+	if len(args) == 0 {
+		return translated.Other
+	}
+	return fmt.Sprintf(translated.Other, args...)
+}
+
+//isOne tells if count takes the singular form in the language
+//of locale.
+func isOne(locale string, count int) bool {
+	switch strings.Split(locale, "-")[0] {
+	case "fr":
+		return count == 0 || count == 1
+	default:
+		return count == 1
+	}
+}
+
+//Plural formats the message id with count, choosing the form
+//the language needs. Both forms hold a %d for count.
+func Plural(locale, id string, count int) string {
+	translated, found, ok := message(locale, id)
+	if !ok {
+		return fmt.Sprintf(id, count)
+	}
+	format := translated.Other
+	if isOne(found, count) && translated.One != "" {
+		format = translated.One
+	}
+	return fmt.Sprintf(format, count)
+}
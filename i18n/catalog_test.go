@@ -0,0 +1,87 @@
+package i18n
+
+import "testing"
+
+func init() {
+	Register(DefaultLocale, Catalog{
+		Codes: map[int64]string{1: "First code"},
+		Messages: map[string]Message{
+			"hello":   {Other: "Hello %s"},
+			"english": {Other: "Only in english"},
+			"items":   {One: "%d item", Other: "%d items"},
+		},
+	})
+	Register("FR", Catalog{
+		Codes: map[int64]string{1: "Premier code"},
+		Messages: map[string]Message{
+			"hello": {Other: "Bonjour %s"},
+			"items": {One: "%d élément", Other: "%d éléments"},
+			"empty": {},
+		},
+	})
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		id     string
+		args   []interface{}
+		want   string
+	}{
+		{"translated", "fr", "hello", []interface{}{"Ana"}, "Bonjour Ana"},
+		{"english", DefaultLocale, "hello", []interface{}{"Ana"}, "Hello Ana"},
+		{"no translation", "fr", "english", nil, "Only in english"},
+		{"empty translation", "fr", "empty", nil, "empty"},
+		{"unknown locale", "de", "hello", []interface{}{"Ana"}, "Hello Ana"},
+		{"unknown id", "fr", "Some text", nil, "Some text"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Translate(test.locale, test.id, test.args...); got != test.want {
+				t.Errorf("translated %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		locale string
+		count  int
+		want   string
+	}{
+		{DefaultLocale, 0, "0 items"},
+		{DefaultLocale, 1, "1 item"},
+		{DefaultLocale, 2, "2 items"},
+		{"fr", 0, "0 élément"},
+		{"fr", 1, "1 élément"},
+		{"fr", 2, "2 éléments"},
+		{"de", 0, "0 items"},
+	}
+	for _, test := range tests {
+		if got := Plural(test.locale, "items", test.count); got != test.want {
+			t.Errorf("%s, %d: %q, want %q", test.locale, test.count, got, test.want)
+		}
+	}
+	if got := Plural("fr", "%d things", 3); got != "3 things" {
+		t.Errorf("unknown id gave %q", got)
+	}
+}
+
+func TestCodeDescription(t *testing.T) {
+	tests := []struct {
+		locale string
+		code   int64
+		want   string
+	}{
+		{"fr", 1, "Premier code"},
+		{"fr", 2, "english"},
+		{"de", 1, "english"},
+	}
+	for _, test := range tests {
+		if got := CodeDescription(test.locale, test.code, "english"); got != test.want {
+			t.Errorf("%s, %d: %q, want %q", test.locale, test.code, got, test.want)
+		}
+	}
+}
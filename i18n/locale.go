@@ -0,0 +1,67 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type weightedTag struct {
+	tag    string
+	weight float64
+}
+
+//ParseAcceptLanguage returns the language tags of an
+//Accept-Language header, most preferred first. Tags with q=0
+//and the * wildcard are left out.
+func ParseAcceptLanguage(header string) []string {
+	var weighted []weightedTag
+	for _, entry := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ";")
+		tag := strings.ToLower(strings.TrimSpace(parts[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+				weight = q
+			}
+		}
+		if weight > 0 {
+			weighted = append(weighted, weightedTag{tag, weight})
+		}
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].weight > weighted[j].weight
+	})
+	tags := make([]string, 0, len(weighted))
+	for _, entry := range weighted {
+		tags = append(tags, entry.tag)
+	}
+	return tags
+}
+
+//Match returns the first of tags there are translations for,
+//es-MX matches es when there's nothing for es-MX itself. It's
+//empty when none matches.
+func Match(tags ...string) string {
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+		if tag == "" {
+			continue
+		}
+		if Supported(tag) {
+			return tag
+		}
+		if base := strings.Split(tag, "-")[0]; Supported(base) {
+			return base
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", []string{"fr-ch", "fr", "en", "de"}},
+		{"en;q=0.5, es", []string{"es", "en"}},
+		{"de;q=0, fr", []string{"fr"}},
+		{"de;q=x, fr", []string{"fr"}},
+		{"es;q=0.5, fr;q=0.5", []string{"es", "fr"}},
+	}
+	for _, test := range tests {
+		if got := ParseAcceptLanguage(test.header); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want string
+	}{
+		{"supported", []string{"fr"}, "fr"},
+		{"region falls back", []string{"fr-CA"}, "fr"},
+		{"underscore", []string{"fr_CA"}, "fr"},
+		{"first supported", []string{"de", "", "en"}, "en"},
+		{"none", []string{"de", "pt-BR"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Match(test.tags...); got != test.want {
+				t.Errorf("matched %q, want %q", got, test.want)
+			}
+		})
+	}
+}
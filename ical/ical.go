@@ -0,0 +1,331 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//MediaType of iCalendar data, RFC 5545.
+const MediaType = "text/calendar"
+
+const (
+	//maxLineOctets is where content lines are folded.
+	maxLineOctets = 75
+
+	dateTimeFormat = "20060102T150405Z"
+	localFormat    = "20060102T150405"
+	dateFormat     = "20060102"
+)
+
+//Property is a content line, NAME;PARAM=value:VALUE. Value is
+//as it's on the line, TEXT values are escaped.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+//Component is a BEGIN:Name ... END:Name block, a VCALENDAR
+//holding VTODOs for instance.
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+//Add appends a property, params are given as name, value
+//pairs.
+func (c *Component) Add(name, value string, params ...string) {
+	property := Property{Name: name, Value: value}
+	if len(params) > 0 {
+		property.Params = map[string]string{}
+		for idx := 0; idx+1 < len(params); idx += 2 {
+			property.Params[params[idx]] = params[idx+1]
+		}
+	}
+	c.Properties = append(c.Properties, property)
+}
+
+//AddText appends a property of type TEXT, escaping value.
+func (c *Component) AddText(name, value string) {
+	c.Add(name, EscapeText(value))
+}
+
+//Get returns the first property called name, nil if there's
+//none.
+func (c *Component) Get(name string) *Property {
+	for idx := range c.Properties {
+		if strings.EqualFold(c.Properties[idx].Name, name) {
+			return &c.Properties[idx]
+		}
+	}
+	return nil
+}
+
+//Text is the unescaped value of the property called name.
+func (c *Component) Text(name string) string {
+	if property := c.Get(name); property != nil {
+		return UnescapeText(property.Value)
+	}
+	return ""
+}
+
+var textEscaper = strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n")
+var textUnescaper = strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", "\n", "\\N", "\n")
+
+func EscapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+func UnescapeText(value string) string {
+	return textUnescaper.Replace(value)
+}
+
+//SplitText splits a TEXT list such as CATEGORIES on the commas
+//which aren't escaped.
+func SplitText(value string) []string {
+	var items []string
+	start := 0
+	for idx := 0; idx < len(value); idx++ {
+		switch value[idx] {
+		case '\\':
+			idx++
+		case ',':
+			items = append(items, UnescapeText(value[start:idx]))
+			start = idx + 1
+		}
+	}
+	return append(items, UnescapeText(value[start:]))
+}
+
+func (property *Property) line() string {
+	var line strings.Builder
+	line.WriteString(property.Name)
+	names := make([]string, 0, len(property.Params))
+	for name := range property.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := property.Params[name]
+		if strings.ContainsAny(value, ";:,") {
+			value = `"` + value + `"`
+		}
+		fmt.Fprintf(&line, ";%s=%s", name, value)
+	}
+	line.WriteString(":")
+	line.WriteString(property.Value)
+	return line.String()
+}
+
+//fold breaks line into lines of at most maxLineOctets octets,
+//continuation lines start with a space. UTF-8 sequences aren't
+//split.
+func fold(line string) string {
+	var folded strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+		//The space counts.
+		limit = maxLineOctets - 1
+	}
+	folded.WriteString(line)
+	folded.WriteString("\r\n")
+	return folded.String()
+}
+
+//Encode writes the component, with the components it holds.
+func (c *Component) Encode(w io.Writer) error {
+	if _, err := io.WriteString(w, fold("BEGIN:"+c.Name)); err != nil {
+		return err
+	}
+	for idx := range c.Properties {
+		if _, err := io.WriteString(w, fold(c.Properties[idx].line())); err != nil {
+			return err
+		}
+	}
+	for _, component := range c.Components {
+		if err := component.Encode(w); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, fold("END:"+c.Name))
+	return err
+}
+
+//ParseError is iCalendar data which can't be read, Line is
+//the line it's on.
+type ParseError struct {
+	Line   int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+//parseLine splits an unfolded content line into its name,
+//parameters and value.
+func parseLine(line string) (*Property, error) {
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return nil, fmt.Errorf("isn't a content line")
+	}
+	property := &Property{Name: strings.ToUpper(line[:end])}
+	for line[end] == ';' {
+		line = line[end+1:]
+		equals := strings.IndexByte(line, '=')
+		if equals <= 0 {
+			return nil, fmt.Errorf("parameter without a value")
+		}
+		name := strings.ToUpper(line[:equals])
+		line = line[equals+1:]
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			quote := strings.IndexByte(line[1:], '"')
+			if quote < 0 {
+				return nil, fmt.Errorf("parameter %s isn't closed by a quote", name)
+			}
+			value = line[1 : quote+1]
+			line = line[quote+2:]
+			end = 0
+		} else {
+			end = strings.IndexAny(line, ";:")
+			if end < 0 {
+				return nil, fmt.Errorf("has no value")
+			}
+			value = line[:end]
+			line = line[end:]
+			end = 0
+		}
+		if property.Params == nil {
+			property.Params = map[string]string{}
+		}
+		property.Params[name] = value
+		if line == "" {
+			break
+		}
+	}
+	if line == "" || line[end] != ':' {
+		return nil, fmt.Errorf("has no value")
+	}
+	property.Value = line[end+1:]
+	return property, nil
+}
+
+//Parse reads one component, usually a VCALENDAR, with all it
+//holds. Folded lines are joined first.
+func Parse(r io.Reader) (*Component, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var lines []string
+	var lineNumbers []int
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			if len(lines) == 0 {
+				return nil, &ParseError{Line: number, Reason: "continues no line"}
+			}
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, text)
+		lineNumbers = append(lineNumbers, number)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var root *Component
+	var open []*Component
+	for idx, line := range lines {
+		property, err := parseLine(line)
+		if err != nil {
+			return nil, &ParseError{Line: lineNumbers[idx], Reason: err.Error()}
+		}
+		switch property.Name {
+		case "BEGIN":
+			component := NewComponent(strings.ToUpper(property.Value))
+			if len(open) > 0 {
+				parent := open[len(open)-1]
+				parent.Components = append(parent.Components, component)
+			} else if root != nil {
+				return nil, &ParseError{Line: lineNumbers[idx], Reason: "more than one top level component"}
+			} else {
+				root = component
+			}
+			open = append(open, component)
+		case "END":
+			if len(open) == 0 || open[len(open)-1].Name != strings.ToUpper(property.Value) {
+				return nil, &ParseError{Line: lineNumbers[idx], Reason: "END:" + property.Value + " doesn't match a BEGIN"}
+			}
+			open = open[:len(open)-1]
+		default:
+			if len(open) == 0 {
+				return nil, &ParseError{Line: lineNumbers[idx], Reason: "property outside of a component"}
+			}
+			component := open[len(open)-1]
+			component.Properties = append(component.Properties, *property)
+		}
+	}
+	if root == nil {
+		return nil, &ParseError{Line: len(lines), Reason: "no component"}
+	}
+	if len(open) > 0 {
+		return nil, &ParseError{Line: lineNumbers[len(lineNumbers)-1], Reason: open[len(open)-1].Name + " isn't ended"}
+	}
+	return root, nil
+}
+
+//FormatDateTime is t as a UTC DATE-TIME.
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+//FormatDate is t as a DATE, it needs VALUE=DATE.
+func FormatDate(t time.Time) string {
+	return t.Format(dateFormat)
+}
+
+//ParseTime reads a DATE or DATE-TIME property. Times without
+//Z are taken to be in the TZID zone, UTC if it's unknown.
+//dateOnly tells a DATE, which has no time of day.
+func (property *Property) ParseTime() (t time.Time, dateOnly bool, err error) {
+	value := property.Value
+	if property.Params["VALUE"] == "DATE" || len(value) == len(dateFormat) {
+		t, err = time.Parse(dateFormat, value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(dateTimeFormat, value)
+		return t, false, err
+	}
+	location := time.UTC
+	if zone := property.Params["TZID"]; zone != "" {
+		if loaded, loadErr := time.LoadLocation(zone); loadErr == nil {
+			location = loaded
+		}
+	}
+	t, err = time.ParseInLocation(localFormat, value, location)
+	return t, false, err
+}
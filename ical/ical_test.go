@@ -0,0 +1,167 @@
+package ical
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want *Component
+		line int
+	}{
+		{"calendar", "\ufeffBEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:1\r\n" +
+			"SUMMARY:Buy milk\\, eggs\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+			&Component{Name: "VCALENDAR",
+				Properties: []Property{{Name: "VERSION", Value: "2.0"}},
+				Components: []*Component{{Name: "VTODO", Properties: []Property{
+					{Name: "UID", Value: "1"},
+					{Name: "SUMMARY", Value: "Buy milk\\, eggs"},
+				}}}}, 0},
+		{"folded lines and bare newlines", "begin:vtodo\nSUMMARY:long\n  line\n\tend\nend:VTODO\n",
+			&Component{Name: "VTODO", Properties: []Property{{Name: "SUMMARY", Value: "long lineend"}}}, 0},
+		{"parameters", "BEGIN:VTODO\r\nDUE;tzid=Europe/Paris;X-A=\"a;b:c\":20200101T100000\r\nEND:VTODO\r\n",
+			&Component{Name: "VTODO", Properties: []Property{{Name: "DUE",
+				Params: map[string]string{"TZID": "Europe/Paris", "X-A": "a;b:c"}, Value: "20200101T100000"}}}, 0},
+		{"value with colons", "BEGIN:VTODO\r\nURL:http://a:80/b\r\nEND:VTODO\r\n",
+			&Component{Name: "VTODO", Properties: []Property{{Name: "URL", Value: "http://a:80/b"}}}, 0},
+		{"continues no line", " BEGIN:VTODO\r\n", nil, 1},
+		{"not a content line", "BEGIN:VTODO\r\nSUMMARY\r\nEND:VTODO\r\n", nil, 2},
+		{"parameter without value", "BEGIN:VTODO\r\nDUE;TZID:1\r\nEND:VTODO\r\n", nil, 2},
+		{"unclosed quote", "BEGIN:VTODO\r\nDUE;X=\"a:1\r\nEND:VTODO\r\n", nil, 2},
+		{"mismatched end", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR\r\n", nil, 3},
+		{"property outside", "UID:1\r\n", nil, 1},
+		{"two top level", "BEGIN:A\r\nEND:A\r\nBEGIN:B\r\nEND:B\r\n", nil, 3},
+		{"not ended", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\n", nil, 2},
+		{"empty", "", nil, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(test.data))
+			if test.want == nil {
+				parseErr, ok := err.(*ParseError)
+				if !ok || parseErr.Line != test.line {
+					t.Errorf("error %v, want one on line %d", err, test.line)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parsed %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+//TestEncodeParse checks what's encoded, long and escaped lines
+//included, is parsed back as it was.
+func TestEncodeParse(t *testing.T) {
+	calendar := NewComponent("VCALENDAR")
+	calendar.Add("VERSION", "2.0")
+	todo := NewComponent("VTODO")
+	todo.AddText("SUMMARY", "Milk, eggs; and \\ butter\nfrom the "+strings.Repeat("é", 60))
+	todo.Add("DUE", "20200101", "VALUE", "DATE")
+	todo.Add("X-NOTE", "x", "X-A", "a:b")
+	calendar.Components = append(calendar.Components, todo)
+	var data bytes.Buffer
+	if err := calendar.Encode(&data); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(data.String(), "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets || !utf8.ValidString(line) {
+			t.Errorf("line %q isn't folded right", line)
+		}
+	}
+	parsed, err := Parse(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, calendar) {
+		t.Errorf("parsed %+v, want %+v", parsed, calendar)
+	}
+	if got := parsed.Components[0].Text("summary"); got != UnescapeText(todo.Properties[0].Value) {
+		t.Errorf("summary is %q", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		text    string
+		escaped string
+	}{
+		{"plain", "plain"},
+		{"a, b; c", "a\\, b\\; c"},
+		{"back\\slash", "back\\\\slash"},
+		{"two\nlines\r\nthree", "two\\nlines\\nthree"},
+	}
+	for _, test := range tests {
+		if got := EscapeText(test.text); got != test.escaped {
+			t.Errorf("%q escaped as %q, want %q", test.text, got, test.escaped)
+		}
+	}
+	if got := UnescapeText("a\\Nb\\,c"); got != "a\nb,c" {
+		t.Errorf("unescaped %q", got)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"a", []string{"a"}},
+		{"a,b", []string{"a", "b"}},
+		{"a\\,b,c", []string{"a,b", "c"}},
+		{"a\\\\,b", []string{"a\\", "b"}},
+		{"", []string{""}},
+	}
+	for _, test := range tests {
+		if got := SplitText(test.value); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q split into %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		property Property
+		want     time.Time
+		dateOnly bool
+		fails    bool
+	}{
+		{"utc", Property{Value: "20200102T030405Z"}, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), false, false},
+		{"date", Property{Value: "20200102"}, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), true, false},
+		{"date by parameter", Property{Value: "20200102", Params: map[string]string{"VALUE": "DATE"}},
+			time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), true, false},
+		{"floating", Property{Value: "20200102T030405"}, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), false, false},
+		{"unknown zone", Property{Value: "20200102T030405", Params: map[string]string{"TZID": "Nowhere/Else"}},
+			time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), false, false},
+		{"not a time", Property{Value: "tomorrow"}, time.Time{}, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, dateOnly, err := test.property.ParseTime()
+			if (err != nil) != test.fails {
+				t.Fatalf("error %v, want failure %v", err, test.fails)
+			}
+			if err != nil {
+				return
+			}
+			if !got.Equal(test.want) || dateOnly != test.dateOnly {
+				t.Errorf("%v, date only %v, want %v, %v", got, dateOnly, test.want, test.dateOnly)
+			}
+		})
+	}
+	if got := FormatDateTime(time.Date(2020, 1, 2, 4, 4, 5, 0, time.FixedZone("", 3600))); got != "20200102T030405Z" {
+		t.Errorf("formatted %s", got)
+	}
+}
@@ -0,0 +1,282 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"todolist/environment"
+)
+
+type Level int
+
+const (
+	Debug = Level(iota)
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (level Level) String() string {
+	if level < Debug || level > Error {
+		return "unknown"
+	}
+	return levelNames[level]
+}
+
+//ParseLevel takes a level by name, Info for names it doesn't
+//know.
+func ParseLevel(name string) (Level, bool) {
+	for idx, levelName := range levelNames {
+		if strings.EqualFold(levelName, strings.TrimSpace(name)) {
+			return Level(idx), true
+		}
+	}
+	return Info, false
+}
+
+//Field is a key and value entries carry next to the message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+//scope holds the fields of a request, they can be added to
+//while it's served and every logger made from its context
+//sees them.
+type scope struct {
+	sync.Mutex
+	fields []Field
+}
+
+//Logger writes JSON entries, one per line, with its fields.
+//Values and messages are redacted before they're written.
+type Logger struct {
+	fields []Field
+	scope  *scope
+}
+
+type loggerKey struct{}
+
+var output struct {
+	sync.Mutex
+	w io.Writer
+}
+
+var minLevel = Info
+
+var root = &Logger{}
+
+func init() {
+	output.w = os.Stderr
+	if name := environment.GetLogLevel(); name != "" {
+		level, ok := ParseLevel(name)
+		minLevel = level
+		if !ok {
+			root.Warnf("Ignoring %s=%s, logging from level %s", environment.LogLevel, name, level)
+		}
+	}
+}
+
+//SetOutput sends entries to w, stderr is where they go unless
+//it's changed.
+func SetOutput(w io.Writer) {
+	output.Lock()
+	defer output.Unlock()
+	output.w = w
+}
+
+func SetLevel(level Level) {
+	minLevel = level
+}
+
+//Enabled tells whether entries of level are written.
+func Enabled(level Level) bool {
+	return level >= minLevel
+}
+
+//With is a logger with the fields of l and key.
+func (l *Logger) With(key string, value interface{}) *Logger {
+	fields := make([]Field, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return &Logger{fields: append(fields, Field{key, value}), scope: l.scope}
+}
+
+//With is a logger with key and none of a request's fields.
+func With(key string, value interface{}) *Logger {
+	return root.With(key, value)
+}
+
+//NewContext is ctx with a new scope for the fields of one
+//request, loggers taken from it carry them.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loggerKey{}, &Logger{scope: &scope{}})
+}
+
+//WithContext is ctx whose logger is l.
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+//FromContext is the logger of ctx, the one without fields if
+//it has none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return root
+}
+
+//SetField adds key to the fields of the request ctx belongs
+//to, or replaces its value.
+func SetField(ctx context.Context, key string, value interface{}) {
+	l := FromContext(ctx)
+	if l.scope == nil {
+		return
+	}
+	l.scope.Lock()
+	defer l.scope.Unlock()
+	for idx := range l.scope.fields {
+		if l.scope.fields[idx].Key == key {
+			l.scope.fields[idx].Value = value
+			return
+		}
+	}
+	l.scope.fields = append(l.scope.fields, Field{key, value})
+}
+
+//Log writes an entry of level with msg, and fields on top of
+//those of l.
+func (l *Logger) Log(level Level, msg string, fields ...Field) {
+	if !Enabled(level) {
+		return
+	}
+	var all []Field
+	if l.scope != nil {
+		l.scope.Lock()
+		all = append(all, l.scope.fields...)
+		l.scope.Unlock()
+	}
+	all = append(append(all, l.fields...), fields...)
+	entry := newEntry()
+	entry.add("time", time.Now().UTC().Format(time.RFC3339Nano))
+	entry.add("level", level.String())
+	entry.add("msg", RedactString(msg))
+	for _, field := range all {
+		entry.add(field.Key, Redact(field.Key, field.Value))
+	}
+	line := entry.bytes()
+	output.Lock()
+	defer output.Unlock()
+	output.w.Write(line)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(Debug, format, args)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(Info, format, args)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.logf(Warn, format, args)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(Error, format, args)
+}
+
+func (l *Logger) logf(level Level, format string, args []interface{}) {
+	if Enabled(level) {
+		l.Log(level, fmt.Sprintf(format, args...))
+	}
+}
+
+func Debugf(format string, args ...interface{}) {
+	root.logf(Debug, format, args)
+}
+
+func Infof(format string, args ...interface{}) {
+	root.logf(Info, format, args)
+}
+
+func Warnf(format string, args ...interface{}) {
+	root.logf(Warn, format, args)
+}
+
+func Errorf(format string, args ...interface{}) {
+	root.logf(Error, format, args)
+}
+
+//Fatalf logs an error and exits.
+func Fatalf(format string, args ...interface{}) {
+	root.logf(Error, format, args)
+	os.Exit(1)
+}
+
+//entry keeps the order fields were added in, later ones with
+//the same key replace earlier ones.
+type entry struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newEntry() *entry {
+	return &entry{values: map[string]interface{}{}}
+}
+
+func (e *entry) add(key string, value interface{}) {
+	if _, ok := e.values[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.values[key] = value
+}
+
+func (e *entry) bytes() []byte {
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	//Messages aren't read by browsers, & and < are kept as
+	//they are.
+	encoder.SetEscapeHTML(false)
+	line.WriteByte('{')
+	for idx, key := range e.keys {
+		if idx > 0 {
+			line.WriteByte(',')
+		}
+		encoder.Encode(key)
+		line.Truncate(line.Len() - 1)
+		line.WriteByte(':')
+		if err := encoder.Encode(e.values[key]); err != nil {
+			encoder.Encode(fmt.Sprint(e.values[key]))
+		}
+		line.Truncate(line.Len() - 1)
+	}
+	line.WriteString("}\n")
+	return line.Bytes()
+}
+
+//Writer takes what's written to it, a line at a time, as the
+//messages of entries of level. The log package is pointed at
+//it so that what others log comes out the same way.
+func Writer(level Level) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+			root.Log(level, line)
+		}
+		return len(p), nil
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (fn writerFunc) Write(p []byte) (int, error) {
+	return fn(p)
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRedactString(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"nothing to hide", "GET /v2/items 200", "GET /v2/items 200"},
+		{"bearer", "sent Bearer abc.def-ghi== again", "sent Bearer " + Redacted + " again"},
+		{"basic", "got basic dXNlcjpwYXNz in header", "got basic " + Redacted + " in header"},
+		{"jwt", "token eyJhbGciOi.eyJzdWIiOi.c2ln left", "token " + Redacted + " left"},
+		{"query pair", "/login?user=a&password=hunter2&x=1", "/login?user=a&password=" + Redacted + "&x=1"},
+		{"key containing a sensitive one", "db_secret_key=abc", "db_secret_key=" + Redacted},
+		{"json member", `{"refresh_token": "abc", "name": "a"}`, `{"refresh_token": "` + Redacted + `", "name": "a"}`},
+		{"url credentials", "dial mongodb://user:pw@db:27017/x", "dial mongodb://" + Redacted + "@db:27017/x"},
+		{"url without credentials", "http://db:27017/x", "http://db:27017/x"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := RedactString(test.text); got != test.want {
+				t.Errorf("redacted %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+type loggedUser struct {
+	Name     string            `json:"name"`
+	Password string            `json:"password"`
+	Devices  []loggedDevice    `json:"devices"`
+	Extra    map[string]string `json:"extra"`
+}
+
+type loggedDevice struct {
+	ID    string `json:"id"`
+	Token string `json:"push_token"`
+}
+
+func TestRedact(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	tests := []struct {
+		name  string
+		key   string
+		value interface{}
+		want  interface{}
+	}{
+		{"sensitive key", "Api_Key", 12345, Redacted},
+		{"number", "count", 3, 3},
+		{"duration", "took", time.Second, time.Second},
+		{"time", "at", at, "2020-01-02T02:04:05Z"},
+		{"string", "url", "mongodb://u:p@db", "mongodb://" + Redacted + "@db"},
+		{"error", "err", errors.New("password=abc"), "password=" + Redacted},
+		{"struct", "user", loggedUser{Name: "a", Password: "pw",
+			Devices: []loggedDevice{{ID: "d", Token: "t"}},
+			Extra:   map[string]string{"Cookie": "c", "note": "Bearer abc"}},
+			map[string]interface{}{
+				"name":     "a",
+				"password": Redacted,
+				"devices":  []interface{}{map[string]interface{}{"id": "d", "push_token": Redacted}},
+				"extra":    map[string]interface{}{"Cookie": Redacted, "note": "Bearer " + Redacted},
+			}},
+		{"not json", "request", struct {
+			Password string
+			Done     chan bool
+		}{"pw", make(chan bool)}, Redacted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Redact(test.key, test.value); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("redacted %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,37 @@
 package main
 
 import (
+	"log"
 	"net/http"
 	"todolist/environment"
+	"todolist/events"
 	"todolist/handlers"
+	"todolist/logging"
 )
 
 func main() {
+	//What the log package is given, by net/http for one, comes
+	//out as entries like ours.
+	log.SetFlags(0)
+	log.SetOutput(logging.Writer(logging.Warn))
 	port := environment.GetPort()
-	http.HandleFunc("/login", handlers.Login)
-	http.HandleFunc("/tptverify", handlers.TPTVerify)
-	http.HandleFunc("/register", handlers.Register)
-	http.HandleFunc("/user", handlers.User)
-	http.HandleFunc("/post/add", handlers.PostAdd)
-	http.HandleFunc("/post/remove", handlers.PostRemove)
-	http.HandleFunc("/post/edit", handlers.PostEdit)
-	http.HandleFunc("/post/get", handlers.PostGet)
-	http.HandleFunc("/", handlers.GenericNotImplemented)
+	if environment.GetEventsChangeStream() {
+		if err := events.StartChangeStream(environment.GetMongoConnectionString()); err != nil {
+			logging.Errorf("Couldn't watch events, they stay with this instance: %v", err)
+		}
+	}
+	//Routes other than the probes answer 503 until MongoDB,
+	//Google's certificates and the secrets are all there.
+	go handlers.WaitUntilReady()
+	handlers.RegisterRoutes(http.DefaultServeMux)
+	if metricsPort := environment.GetMetricsPort(); metricsPort != "" && metricsPort != port {
+		admin := http.NewServeMux()
+		handlers.RegisterAdminRoutes(admin)
+		go func() {
+			logging.Fatalf("Couldn't serve metrics on port %s: %v", metricsPort, http.ListenAndServe(":"+metricsPort, admin))
+		}()
+	} else {
+		handlers.RegisterAdminRoutes(http.DefaultServeMux)
+	}
 	http.ListenAndServe(":"+port, nil)
 }
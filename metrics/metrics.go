@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//ContentType is that of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+//DefaultBuckets suit latencies in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type kind string
+
+const (
+	counterKind   = kind("counter")
+	gaugeKind     = kind("gauge")
+	histogramKind = kind("histogram")
+)
+
+//series is the value of a family for one set of label values.
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64
+	sum         float64
+	count       uint64
+}
+
+//family holds every series of a metric.
+type family struct {
+	sync.Mutex
+	name       string
+	help       string
+	kind       kind
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+	fn         func() float64
+}
+
+var registry struct {
+	sync.Mutex
+	families map[string]*family
+}
+
+func init() {
+	NewGaugeFunc("go_goroutines", "Goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+}
+
+func register(f *family) *family {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.families == nil {
+		registry.families = map[string]*family{}
+	}
+	if _, ok := registry.families[f.name]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", f.name))
+	}
+	f.series = map[string]*series{}
+	//Metrics without labels are there from the start, at zero.
+	if len(f.labelNames) == 0 && f.fn == nil {
+		f.get(nil)
+	}
+	registry.families[f.name] = f
+	return f
+}
+
+//get is the series of labelValues, made on first use.
+//Missing values are empty, extra ones are left out.
+func (f *family) get(labelValues []string) *series {
+	values := make([]string, len(f.labelNames))
+	copy(values, labelValues)
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: values}
+		if f.kind == histogramKind {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+//Counter counts what only ever goes up, per label values.
+type Counter struct {
+	family *family
+}
+
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{register(&family{name: name, help: help, kind: counterKind, labelNames: labelNames})}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+//Add adds value, which mustn't be negative.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.family.Lock()
+	defer c.family.Unlock()
+	c.family.get(labelValues).value += value
+}
+
+//Gauge is a value which goes up and down, per label values.
+type Gauge struct {
+	family *family
+}
+
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{register(&family{name: name, help: help, kind: gaugeKind, labelNames: labelNames})}
+}
+
+//NewGaugeFunc is a gauge without labels whose value fn gives
+//whenever it's scraped.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(&family{name: name, help: help, kind: gaugeKind, fn: fn})
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.family.Lock()
+	defer g.family.Unlock()
+	g.family.get(labelValues).value = value
+}
+
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.family.Lock()
+	defer g.family.Unlock()
+	g.family.get(labelValues).value += value
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+//Histogram counts observations into buckets by their upper
+//bound, per label values.
+type Histogram struct {
+	family *family
+}
+
+//NewHistogram has buckets, DefaultBuckets if they're nil, in
+//increasing order. The +Inf bucket is always there.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &Histogram{register(&family{name: name, help: help, kind: histogramKind,
+		labelNames: labelNames, buckets: buckets})}
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.family.Lock()
+	defer h.family.Unlock()
+	s := h.family.get(labelValues)
+	for idx, bound := range h.family.buckets {
+		if value <= bound {
+			s.buckets[idx]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+//Handler serves every metric in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		if r.Method == http.MethodHead {
+			return
+		}
+		Write(w)
+	})
+}
+
+//Write writes every metric in the Prometheus text format,
+//sorted by name.
+func Write(w io.Writer) error {
+	registry.Lock()
+	families := make([]*family, 0, len(registry.families))
+	for _, f := range registry.families {
+		families = append(families, f)
+	}
+	registry.Unlock()
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	out := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(out)
+	}
+	return out.Flush()
+}
+
+func (f *family) write(out *bufio.Writer) {
+	fmt.Fprintf(out, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(out, "# TYPE %s %s\n", f.name, f.kind)
+	if f.fn != nil {
+		fmt.Fprintf(out, "%s %s\n", f.name, formatValue(f.fn()))
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != histogramKind {
+			fmt.Fprintf(out, "%s%s %s\n", f.name, f.labels(s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		for idx, bound := range f.buckets {
+			fmt.Fprintf(out, "%s_bucket%s %d\n", f.name,
+				f.labels(s.labelValues, "le", formatValue(bound)), s.buckets[idx])
+		}
+		fmt.Fprintf(out, "%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(out, "%s_sum%s %s\n", f.name, f.labels(s.labelValues, "", ""), formatValue(s.sum))
+		fmt.Fprintf(out, "%s_count%s %d\n", f.name, f.labels(s.labelValues, "", ""), s.count)
+	}
+}
+
+//labels is {name="value",...} of the series, with extra on top
+//if it's named.
+func (f *family) labels(values []string, extra, extraValue string) string {
+	var pairs []string
+	for idx, name := range f.labelNames {
+		pairs = append(pairs, name+`="`+escapeLabel(values[idx])+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra+`="`+escapeLabel(extraValue)+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,266 @@
+package model
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"todolist/database"
+	"todolist/environment"
+	"todolist/logging"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//ErrInvalidCursor is returned when a continuation cursor has
+//been tampered with or was issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+//ErrNoCursorSecret is returned instead of signing or checking
+//cursors with a key anyone could work out.
+var ErrNoCursorSecret = errors.New(environment.AppTokenSecret + " isn't set")
+
+//itemCursor is what a continuation cursor carries, the sort
+//key values and the _id of the last item sent, null values are
+//fields the item doesn't have. Sort is the sort spec the cursor
+//was issued for.
+type itemCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	Last   string        `json:"last"`
+	lastID primitive.ObjectID
+}
+
+//ItemPage is one page of a listing. Total is only filled
+//when it was asked for, otherwise it's -1.
+type ItemPage struct {
+	Items      []TodoItem
+	HasMore    bool
+	NextCursor string
+	Total      int64
+}
+
+func cursorKey() ([]byte, error) {
+	secret := environment.GetAppTokenSecret()
+	if secret == "" {
+		return nil, ErrNoCursorSecret
+	}
+	return []byte("cursor:" + secret), nil
+}
+
+func signCursor(payload []byte) (string, error) {
+	key, err := cursorKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func sortSpec(keys []SortKey) string {
+	var spec []string
+	for _, key := range keys {
+		if key.Descending {
+			spec = append(spec, "-"+key.Field)
+		} else {
+			spec = append(spec, key.Field)
+		}
+	}
+	return strings.Join(spec, ",")
+}
+
+//rawSortValue is the value field has in item as it's stored,
+//nil if the item doesn't have it.
+func rawSortValue(item bson.Raw, field string) interface{} {
+	value, err := item.LookupErr(field)
+	if err != nil {
+		return nil
+	}
+	switch value.Type {
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.Int32:
+		return int(value.Int32())
+	case bsontype.Int64:
+		return int(value.Int64())
+	}
+	return nil
+}
+
+func encodeCursor(keys []SortKey, item bson.Raw) (string, error) {
+	last, ok := item.Lookup("_id").ObjectIDOK()
+	if !ok {
+		return "", errors.New("item has no ObjectID")
+	}
+	cursor := itemCursor{Sort: sortSpec(keys), Last: last.Hex()}
+	for _, key := range keys {
+		cursor.Values = append(cursor.Values, rawSortValue(item, ItemFields[key.Field]))
+	}
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	signature, err := signCursor(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + signature, nil
+}
+
+func decodeCursor(keys []SortKey, token string) (*itemCursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := signCursor(payload)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(signature), []byte(parts[1])) {
+		return nil, ErrInvalidCursor
+	}
+	cursor := &itemCursor{}
+	if err = json.Unmarshal(payload, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sortSpec(keys) || len(cursor.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.lastID, err = primitive.ObjectIDFromHex(cursor.Last); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	//json gives back numbers as float64, mongo must compare
+	//them against the int the field is stored as.
+	for idx, key := range keys {
+		if key.Field == "priority" && cursor.Values[idx] != nil {
+			value, ok := cursor.Values[idx].(float64)
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			cursor.Values[idx] = int(value)
+		}
+	}
+	return cursor, nil
+}
+
+//keysetFilter matches the items that sort after the cursor,
+//for keys k1, k2 ... followed by the _id which breaks ties that
+//is
+//	k1 > v1 OR (k1 == v1 AND k2 > v2) OR ... OR
+//	(k1 == v1 AND ... AND _id > last)
+//with < in place of > for descending keys. Mongo sorts a field
+//an item doesn't have as null, before any value, items stored
+//before the field existed are matched the same way.
+func keysetFilter(keys []SortKey, cursor *itemCursor) bson.M {
+	var or bson.A
+	var equal bson.A
+	for idx, key := range keys {
+		field := ItemFields[key.Field]
+		value := cursor.Values[idx]
+		if after := afterValue(field, value, key.Descending); after != nil {
+			or = append(or, allOf(append(append(bson.A{}, equal...), after)))
+		}
+		//null matches a missing field as well.
+		equal = append(equal, bson.M{field: value})
+	}
+	or = append(or, allOf(append(equal, bson.M{"_id": bson.M{"$gt": cursor.lastID}})))
+	return bson.M{"$or": or}
+}
+
+//afterValue matches the values of field which sort after value,
+//it's nil when none do.
+func afterValue(field string, value interface{}, descending bool) bson.M {
+	switch {
+	case value == nil && descending:
+		return nil
+	case value == nil:
+		return bson.M{field: bson.M{"$ne": nil}}
+	case descending:
+		return bson.M{"$or": bson.A{
+			bson.M{field: bson.M{"$lt": value}},
+			bson.M{field: nil},
+		}}
+	}
+	return bson.M{field: bson.M{"$gt": value}}
+}
+
+func allOf(clauses bson.A) bson.M {
+	if len(clauses) == 1 {
+		return clauses[0].(bson.M)
+	}
+	return bson.M{"$and": clauses}
+}
+
+//GetOwnerItemsPage lists the items matching itemQuery. If
+//itemQuery.Cursor is set the listing continues after it, offset
+//paging is still honoured for older clients. One more item than
+//asked for is fetched to know if there are more.
+func GetOwnerItemsPage(context context.Context, dbClient *mongo.Client, owner string, itemQuery *ItemQuery) (*ItemPage, error) {
+	page := &ItemPage{Total: -1}
+	keys := itemQuery.Sort
+	query := itemQuery.Filter(owner)
+	if itemQuery.Cursor != "" {
+		cursor, err := decodeCursor(keys, itemQuery.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = bson.M{"$and": bson.A{query, keysetFilter(keys, cursor)}}
+	}
+	pageQuery := *itemQuery
+	if pageQuery.Count > 0 {
+		pageQuery.Count++
+	}
+	findOpts := pageQuery.FindOptions()
+
+	collection := database.GetTodoListCollection(dbClient)
+	if itemQuery.WithTotal {
+		total, err := collection.CountDocuments(context, itemQuery.Filter(owner))
+		if err != nil {
+			logging.FromContext(context).Errorf("Error counting TodoItems for owner %s: %v", owner, err)
+			return nil, errors.Errorf("Couldn't count TODO items for owner %s", owner)
+		}
+		page.Total = total
+	}
+	cursor, err := collection.Find(context, query, findOpts)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error finding TodoItems for owner %s: %v", owner, err)
+		return nil, errors.Errorf("No TODO items found for owner %s", owner)
+	}
+	defer cursor.Close(context)
+	//The items are kept as stored until the cursor is made,
+	//it needs to know which fields they don't have.
+	var stored []bson.Raw
+	err = cursor.All(context, &stored)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error iterating cursor: %v", err)
+		return nil, errors.Errorf("Couldn't decode TodoItems for owner %s", owner)
+	}
+	if itemQuery.Count > 0 && uint(len(stored)) > itemQuery.Count {
+		stored = stored[:itemQuery.Count]
+		page.HasMore = true
+		page.NextCursor, err = encodeCursor(keys, stored[len(stored)-1])
+		if err != nil {
+			logging.FromContext(context).Errorf("Error encoding cursor for owner %s: %v", owner, err)
+			return nil, err
+		}
+	}
+	page.Items = make([]TodoItem, len(stored))
+	for idx, raw := range stored {
+		if err = bson.Unmarshal(raw, &page.Items[idx]); err != nil {
+			logging.FromContext(context).Errorf("Error decoding TodoItem: %v", err)
+			return nil, errors.Errorf("Couldn't decode TodoItems for owner %s", owner)
+		}
+	}
+	return page, nil
+}
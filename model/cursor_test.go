@@ -0,0 +1,138 @@
+package model
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"todolist/environment"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//withSecret runs the test with secret as the app token secret.
+func withSecret(t *testing.T, secret string) {
+	previous, had := os.LookupEnv(environment.AppTokenSecret)
+	os.Setenv(environment.AppTokenSecret, secret)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(environment.AppTokenSecret, previous)
+		} else {
+			os.Unsetenv(environment.AppTokenSecret)
+		}
+	})
+}
+
+func storedItem(t *testing.T, id primitive.ObjectID, fields bson.M) bson.Raw {
+	fields["_id"] = id
+	raw, err := bson.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestCursorSigning(t *testing.T) {
+	withSecret(t, "secret")
+	id := primitive.NewObjectID()
+	byPriority := []SortKey{{Field: "priority", Descending: true}}
+	token, err := encodeCursor(byPriority, storedItem(t, id, bson.M{"priority": int32(2)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.Split(token, ".")[0]
+	tests := []struct {
+		name   string
+		keys   []SortKey
+		token  string
+		secret string
+		err    error
+	}{
+		{"as issued", byPriority, token, "secret", nil},
+		{"other sort order", []SortKey{{Field: "priority"}}, token, "secret", ErrInvalidCursor},
+		{"other secret", byPriority, token, "other", ErrInvalidCursor},
+		{"no secret", byPriority, token, "", ErrNoCursorSecret},
+		{"payload changed", byPriority, payload + "x." + strings.Split(token, ".")[1], "secret", ErrInvalidCursor},
+		{"not signed", byPriority, payload, "secret", ErrInvalidCursor},
+		{"not base64", byPriority, "!!.x", "secret", ErrInvalidCursor},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withSecret(t, test.secret)
+			cursor, err := decodeCursor(test.keys, test.token)
+			if err != test.err {
+				t.Fatalf("error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if cursor.lastID != id || !reflect.DeepEqual(cursor.Values, []interface{}{2}) {
+				t.Errorf("decoded %+v, want %s after priority 2", cursor, id.Hex())
+			}
+		})
+	}
+}
+
+func TestEncodeCursorMissingField(t *testing.T) {
+	withSecret(t, "secret")
+	keys := []SortKey{{Field: "list"}, {Field: "name"}}
+	token, err := encodeCursor(keys, storedItem(t, primitive.NewObjectID(), bson.M{"name": "a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := decodeCursor(keys, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{nil, "a"}; !reflect.DeepEqual(cursor.Values, want) {
+		t.Errorf("values %v, want %v", cursor.Values, want)
+	}
+}
+
+func TestKeysetFilter(t *testing.T) {
+	last := primitive.NewObjectID()
+	afterLast := bson.M{"_id": bson.M{"$gt": last}}
+	tests := []struct {
+		name   string
+		keys   []SortKey
+		values []interface{}
+		want   bson.M
+	}{
+		{"no keys", nil, nil, bson.M{"$or": bson.A{afterLast}}},
+		{"ascending", []SortKey{{Field: "name"}}, []interface{}{"b"},
+			bson.M{"$or": bson.A{
+				bson.M{"name": bson.M{"$gt": "b"}},
+				bson.M{"$and": bson.A{bson.M{"name": "b"}, afterLast}},
+			}}},
+		{"descending", []SortKey{{Field: "priority", Descending: true}}, []interface{}{2},
+			bson.M{"$or": bson.A{
+				bson.M{"$or": bson.A{bson.M{"priority": bson.M{"$lt": 2}}, bson.M{"priority": nil}}},
+				bson.M{"$and": bson.A{bson.M{"priority": 2}, afterLast}},
+			}}},
+		{"ascending from missing", []SortKey{{Field: "list"}}, []interface{}{nil},
+			bson.M{"$or": bson.A{
+				bson.M{"list": bson.M{"$ne": nil}},
+				bson.M{"$and": bson.A{bson.M{"list": nil}, afterLast}},
+			}}},
+		{"descending from missing", []SortKey{{Field: "list", Descending: true}}, []interface{}{nil},
+			bson.M{"$or": bson.A{
+				bson.M{"$and": bson.A{bson.M{"list": nil}, afterLast}},
+			}}},
+		{"two keys", []SortKey{{Field: "status"}, {Field: "end_time", Descending: true}}, []interface{}{"todo", "2020"},
+			bson.M{"$or": bson.A{
+				bson.M{"status": bson.M{"$gt": "todo"}},
+				bson.M{"$and": bson.A{bson.M{"status": "todo"},
+					bson.M{"$or": bson.A{bson.M{"endtime": bson.M{"$lt": "2020"}}, bson.M{"endtime": nil}}}}},
+				bson.M{"$and": bson.A{bson.M{"status": "todo"}, bson.M{"endtime": "2020"}, afterLast}},
+			}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor := &itemCursor{Values: test.values, lastID: last}
+			if got := keysetFilter(test.keys, cursor); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("filter is %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package model
+
+import (
+	"context"
+	"io"
+	"time"
+	"todolist/database"
+	"todolist/logging"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//Kinds of data requests, users asking for a copy of their
+//personal data or for it to be erased.
+const (
+	DataExport  = "export"
+	DataErasure = "erasure"
+)
+
+//States of data requests.
+const (
+	DataRequestPending = "pending"
+	DataRequestRunning = "running"
+	DataRequestDone    = "done"
+	DataRequestFailed  = "failed"
+)
+
+//DataRequest is a data subject request, which is worked on in
+//the background. The archive of a finished export is stored
+//under the request's ID.
+type DataRequest struct {
+	ID       string     `json:"id"`
+	Owner    string     `json:"-"`
+	Kind     string     `json:"type"`
+	Status   string     `json:"status"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty" bson:"finished,omitempty"`
+	Error    string     `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+//Active tells whether the request is still being worked on.
+func (request *DataRequest) Active() bool {
+	return request.Status == DataRequestPending || request.Status == DataRequestRunning
+}
+
+func AddDataRequest(context context.Context, dbClient *mongo.Client, request *DataRequest) bool {
+	collection := database.GetDataRequestCollection(dbClient)
+	if _, err := collection.InsertOne(context, request); err != nil {
+		logging.FromContext(context).Errorf("Error adding %s request %s of user %s: %v", request.Kind, request.ID, request.Owner, err)
+		return false
+	}
+	return true
+}
+
+func GetDataRequest(context context.Context, dbClient *mongo.Client, owner, id string) (*DataRequest, error) {
+	query := bson.M{
+		"owner": owner,
+		"id":    id,
+	}
+	request := &DataRequest{}
+	collection := database.GetDataRequestCollection(dbClient)
+	if err := collection.FindOne(context, query).Decode(request); err != nil {
+		return nil, errors.Errorf("No data request %s found for user %s", id, owner)
+	}
+	return request, nil
+}
+
+//GetDataRequests are the requests of owner, the latest first.
+func GetDataRequests(context context.Context, dbClient *mongo.Client, owner string) ([]DataRequest, error) {
+	query := bson.M{
+		"owner": owner,
+	}
+	findOpts := options.Find().SetSort(bson.M{"created": -1})
+	collection := database.GetDataRequestCollection(dbClient)
+	cursor, err := collection.Find(context, query, findOpts)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error finding data requests of user %s: %v", owner, err)
+		return nil, errors.Errorf("No data requests found for user %s", owner)
+	}
+	defer cursor.Close(context)
+	requests := []DataRequest{}
+	if err = cursor.All(context, &requests); err != nil {
+		logging.FromContext(context).Errorf("Error iterating cursor: %v", err)
+		return nil, errors.Errorf("Couldn't decode data requests of user %s", owner)
+	}
+	return requests, nil
+}
+
+//SetStatus stores the new status of the request, reason says
+//why it failed. Done and failed requests are finished.
+func (request *DataRequest) SetStatus(context context.Context, dbClient *mongo.Client, status, reason string) bool {
+	request.Status = status
+	request.Error = reason
+	set := bson.M{"status": status, "error": reason}
+	if status == DataRequestDone || status == DataRequestFailed {
+		now := time.Now().UTC()
+		request.Finished = &now
+		set["finished"] = now
+	}
+	collection := database.GetDataRequestCollection(dbClient)
+	if _, err := collection.UpdateOne(context, bson.M{"id": request.ID}, bson.M{"$set": set}); err != nil {
+		logging.FromContext(context).Errorf("Error updating data request %s: %v", request.ID, err)
+		return false
+	}
+	return true
+}
+
+//SaveDataArchive stores what source gives as the archive of
+//the export request.
+func SaveDataArchive(context context.Context, dbClient *mongo.Client, request *DataRequest, source io.Reader) error {
+	bucket, err := database.GetArchiveBucket(dbClient)
+	if err != nil {
+		return err
+	}
+	uploadOpts := options.GridFSUpload().SetMetadata(bson.M{"owner": request.Owner})
+	return bucket.UploadFromStreamWithID(request.ID, "personal-data-"+request.ID+".zip", source, uploadOpts)
+}
+
+//OpenDataArchive reads the archive of the export request, the
+//caller closes it.
+func OpenDataArchive(context context.Context, dbClient *mongo.Client, request *DataRequest) (*gridfs.DownloadStream, error) {
+	bucket, err := database.GetArchiveBucket(dbClient)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.OpenDownloadStream(request.ID)
+}
+
+//EraseUser removes the user and everything stored about them.
+//Items shared with the user stop being shared with them, and
+//those they completed no longer say who did. Only the erasure
+//requests are kept, as the record that the user was erased.
+//The archives, which GridFS can't remove in a transaction, go
+//first, the rest is removed in one so that a failed erasure
+//leaves the user as they were but for their archives. Running
+//it again finishes it.
+func EraseUser(context context.Context, dbClient *mongo.Client, id string) error {
+	requests, err := GetDataRequests(context, dbClient, id)
+	if err != nil {
+		return err
+	}
+	bucket, err := database.GetArchiveBucket(dbClient)
+	if err != nil {
+		return err
+	}
+	for _, request := range requests {
+		if request.Kind != DataExport {
+			continue
+		}
+		if err = bucket.Delete(request.ID); err != nil && err != gridfs.ErrFileNotFound {
+			return errors.Wrap(err, "removing archives")
+		}
+	}
+	err = WithTransaction(context, dbClient, func(sc mongo.SessionContext) error {
+		items := database.GetTodoListCollection(dbClient)
+		if _, err := items.DeleteMany(sc, bson.M{"owner": id}); err != nil {
+			return errors.Wrap(err, "removing items")
+		}
+		if _, err := items.UpdateMany(sc, bson.M{"sharedwith": id},
+			bson.M{"$pull": bson.M{"sharedwith": id}}); err != nil {
+			return errors.Wrap(err, "removing shares")
+		}
+		if _, err := items.UpdateMany(sc, bson.M{"completedby": id},
+			bson.M{"$unset": bson.M{"completedby": ""}}); err != nil {
+			return errors.Wrap(err, "anonymizing completions")
+		}
+		if _, err := database.GetTagCollection(dbClient).DeleteMany(sc, bson.M{"owner": id}); err != nil {
+			return errors.Wrap(err, "removing tags")
+		}
+		if _, err := database.GetSyncCollection(dbClient).DeleteMany(sc, bson.M{"owner": id}); err != nil {
+			return errors.Wrap(err, "removing sync states")
+		}
+		if _, err := database.GetDataRequestCollection(dbClient).DeleteMany(sc,
+			bson.M{"owner": id, "kind": DataExport}); err != nil {
+			return errors.Wrap(err, "removing export requests")
+		}
+		if _, err := database.GetUserCollection(dbClient).DeleteOne(sc, bson.M{"id": id}); err != nil {
+			return errors.Wrap(err, "removing user")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(context).Infof("Erased user %s", id)
+	return nil
+}
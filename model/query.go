@@ -0,0 +1,170 @@
+package model
+
+import (
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//ItemFields maps the json name of a TodoItem field, which is
+//what clients use in query parameters, to the name mongo
+//stores it under. Only these can be sorted on or projected.
+var ItemFields = map[string]string{
+	"id":           "id",
+	"name":         "name",
+	"content":      "content",
+	"actions":      "actions",
+	"start_time":   "starttime",
+	"end_time":     "endtime",
+	"sharedWith":   "sharedwith",
+	"tags":         "tags",
+	"status":       "status",
+	"priority":     "priority",
+	"list":         "list",
+	"recurrence":   "recurrence",
+	"completed_at": "completedat",
+	"completed_by": "completedby",
+}
+
+//SortableItemFields are the json names of the fields which
+//can be used as sort keys.
+var SortableItemFields = []string{
+	"id", "name", "start_time", "end_time", "status", "priority", "list", "completed_at",
+}
+
+//SortKey is one key of a multi key sort. Field is the json
+//name of the field.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+//ItemQuery holds everything a listing of items can be
+//restricted by. Empty members don't restrict anything.
+//DueAfter and DueBefore are RFC3339 timestamps compared against
+//end_time as instants, whatever zone it was given in.
+type ItemQuery struct {
+	//Shared keeps the items of the owner which are shared with
+	//the owner as well, items of others are never listed.
+	Shared     bool
+	Tags       TagFilter
+	Statuses   []string
+	Priorities []int
+	Lists      []string
+	DueAfter   string
+	DueBefore  string
+	Text       string
+	Sort       []SortKey
+	Fields     []string
+	Offset     uint
+	Count      uint
+	//Cursor continues a listing where a previous page ended,
+	//it's the NextCursor of that ItemPage.
+	Cursor    string
+	WithTotal bool
+}
+
+//Filter translates the query into a mongo filter document.
+//All user supplied strings end up as values, never as keys or
+//operators, and Text is quoted before being used as a regex.
+func (query *ItemQuery) Filter(owner string) bson.M {
+	filter := bson.M{
+		"owner": owner,
+	}
+	if query.Shared {
+		filter["sharedwith"] = bson.M{"$in": bson.A{owner}}
+	}
+	if len(query.Tags.Tags) > 0 {
+		tagOp := "$in"
+		if query.Tags.MatchAll {
+			tagOp = "$all"
+		}
+		filter["tags"] = bson.M{tagOp: query.Tags.Tags}
+	}
+	if len(query.Statuses) > 0 {
+		filter["status"] = bson.M{"$in": query.Statuses}
+	}
+	if len(query.Priorities) > 0 {
+		filter["priority"] = bson.M{"$in": query.Priorities}
+	}
+	if len(query.Lists) > 0 {
+		filter["list"] = bson.M{"$in": query.Lists}
+	}
+	if query.DueAfter != "" || query.DueBefore != "" {
+		due := bson.M{}
+		legacyDue := bson.M{}
+		if after := ParseItemTime(query.DueAfter); after != nil {
+			due["$gte"] = *after
+			legacyDue["$gte"] = after.UTC().Format(time.RFC3339)
+		}
+		if before := ParseItemTime(query.DueBefore); before != nil {
+			due["$lte"] = *before
+			legacyDue["$lte"] = before.UTC().Format(time.RFC3339)
+		}
+		//Items stored before DueAt was kept only have the
+		//string to go by.
+		filter["$or"] = bson.A{
+			bson.M{"dueat": due},
+			bson.M{"dueat": bson.M{"$exists": false}, "endtime": legacyDue},
+		}
+	}
+	if query.Text != "" {
+		filter["name"] = bson.M{
+			"$regex":   regexp.QuoteMeta(query.Text),
+			"$options": "i",
+		}
+	}
+	return filter
+}
+
+//FindOptions gives the sort, projection and paging options
+//for the query.
+func (query *ItemQuery) FindOptions() *options.FindOptions {
+	findOpts := options.Find()
+	//_id comes last so that no two items ever compare equal,
+	//continuation cursors rely on it.
+	sortDoc := bson.D{}
+	for _, key := range query.Sort {
+		direction := 1
+		if key.Descending {
+			direction = -1
+		}
+		sortDoc = append(sortDoc, bson.E{Key: ItemFields[key.Field], Value: direction})
+	}
+	findOpts.SetSort(append(sortDoc, bson.E{Key: "_id", Value: 1}))
+	if len(query.Fields) > 0 {
+		//id is always sent so that clients can refer
+		//back to the item.
+		//Sort keys are needed as well to build the
+		//continuation cursor.
+		projection := bson.M{"id": 1}
+		for _, field := range query.Fields {
+			projection[ItemFields[field]] = 1
+		}
+		for _, key := range query.Sort {
+			projection[ItemFields[key.Field]] = 1
+		}
+		findOpts.SetProjection(projection)
+	}
+	if query.Offset > 0 {
+		findOpts.SetSkip(int64(query.Offset))
+	}
+	if query.Count > 0 {
+		findOpts.SetLimit(int64(query.Count))
+	}
+	return findOpts
+}
+
+//ParseItemTime reads a start or end time the way clients send
+//them, RFC3339 or a plain date, nil if it's neither.
+func ParseItemTime(value string) *time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
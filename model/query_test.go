@@ -0,0 +1,83 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestItemQueryFilter(t *testing.T) {
+	after := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query ItemQuery
+		want  bson.M
+	}{
+		{"nothing", ItemQuery{}, bson.M{"owner": "u"}},
+		{"shared", ItemQuery{Shared: true},
+			bson.M{"owner": "u", "sharedwith": bson.M{"$in": bson.A{"u"}}}},
+		{"any tag", ItemQuery{Tags: TagFilter{Tags: []string{"a", "b"}}},
+			bson.M{"owner": "u", "tags": bson.M{"$in": []string{"a", "b"}}}},
+		{"all tags", ItemQuery{Tags: TagFilter{Tags: []string{"a", "b"}, MatchAll: true}},
+			bson.M{"owner": "u", "tags": bson.M{"$all": []string{"a", "b"}}}},
+		{"states, priorities and lists", ItemQuery{Statuses: []string{"todo"}, Priorities: []int{1, 2}, Lists: []string{"work"}},
+			bson.M{"owner": "u", "status": bson.M{"$in": []string{"todo"}},
+				"priority": bson.M{"$in": []int{1, 2}}, "list": bson.M{"$in": []string{"work"}}}},
+		{"due after", ItemQuery{DueAfter: "2020-01-02T04:04:05+01:00"},
+			bson.M{"owner": "u", "$or": bson.A{
+				bson.M{"dueat": bson.M{"$gte": after}},
+				bson.M{"dueat": bson.M{"$exists": false}, "endtime": bson.M{"$gte": "2020-01-02T03:04:05Z"}},
+			}}},
+		{"text is quoted", ItemQuery{Text: "a.b*"},
+			bson.M{"owner": "u", "name": bson.M{"$regex": `a\.b\*`, "$options": "i"}}},
+		{"operators are values", ItemQuery{Statuses: []string{"$ne"}, Text: "$where"},
+			bson.M{"owner": "u", "status": bson.M{"$in": []string{"$ne"}},
+				"name": bson.M{"$regex": `\$where`, "$options": "i"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.query.Filter("u"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("filter is %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestItemQueryFindOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      ItemQuery
+		sort       bson.D
+		projection interface{}
+		skip       *int64
+		limit      *int64
+	}{
+		{"default", ItemQuery{}, bson.D{{Key: "_id", Value: 1}}, nil, nil, nil},
+		{"sorted", ItemQuery{Sort: []SortKey{{Field: "end_time", Descending: true}, {Field: "name"}}},
+			bson.D{{Key: "endtime", Value: -1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}, nil, nil, nil},
+		{"fields keep id and sort keys", ItemQuery{Fields: []string{"name"}, Sort: []SortKey{{Field: "status"}}},
+			bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: 1}},
+			bson.M{"id": 1, "name": 1, "status": 1}, nil, nil},
+		{"paged", ItemQuery{Offset: 10, Count: 5}, bson.D{{Key: "_id", Value: 1}}, nil, int64Ptr(10), int64Ptr(5)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.query.FindOptions()
+			if !reflect.DeepEqual(opts.Sort, test.sort) {
+				t.Errorf("sort is %v, want %v", opts.Sort, test.sort)
+			}
+			if !reflect.DeepEqual(opts.Projection, test.projection) {
+				t.Errorf("projection is %v, want %v", opts.Projection, test.projection)
+			}
+			if !reflect.DeepEqual(opts.Skip, test.skip) || !reflect.DeepEqual(opts.Limit, test.limit) {
+				t.Errorf("skip %v and limit %v, want %v and %v", opts.Skip, opts.Limit, test.skip, test.limit)
+			}
+		})
+	}
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
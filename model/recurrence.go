@@ -0,0 +1,40 @@
+package model
+
+import (
+	"strings"
+	"todolist/utils"
+)
+
+//recurrenceFrequencies and recurrenceParts are what an RRULE,
+//RFC 5545 section 3.3.10, may have.
+var recurrenceFrequencies = []string{
+	"SECONDLY", "MINUTELY", "HOURLY", "DAILY", "WEEKLY", "MONTHLY", "YEARLY",
+}
+
+var recurrenceParts = []string{
+	"FREQ", "UNTIL", "COUNT", "INTERVAL", "BYSECOND", "BYMINUTE", "BYHOUR", "BYDAY",
+	"BYMONTHDAY", "BYYEARDAY", "BYWEEKNO", "BYMONTH", "BYSETPOS", "WKST",
+}
+
+//ValidRecurrence checks that rule is made of RRULE parts, with
+//FREQ once and not both of UNTIL and COUNT. The values of the
+//other parts aren't checked. An empty rule is valid.
+func ValidRecurrence(rule string) bool {
+	if rule == "" {
+		return true
+	}
+	seen := map[string]string{}
+	for _, part := range strings.Split(rule, ";") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 || pair[1] == "" || !utils.StringSlice(recurrenceParts).Contains(pair[0]) {
+			return false
+		}
+		if _, ok := seen[pair[0]]; ok {
+			return false
+		}
+		seen[pair[0]] = pair[1]
+	}
+	_, hasUntil := seen["UNTIL"]
+	_, hasCount := seen["COUNT"]
+	return utils.StringSlice(recurrenceFrequencies).Contains(seen["FREQ"]) && !(hasUntil && hasCount)
+}
@@ -0,0 +1,364 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"todolist/database"
+	"todolist/logging"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	nameSearchWeight    = 10
+	contentSearchWeight = 1
+	snippetContext      = 40
+	textIndexName       = "item_text"
+)
+
+//Snippet is a piece of a field around the first match,
+//matched terms are wrapped in <em></em> and the rest of
+//the text is html escaped.
+type Snippet struct {
+	Field string `json:"field"`
+	Text  string `json:"text"`
+}
+
+type SearchResult struct {
+	Item     TodoItem  `json:"item"`
+	Score    float64   `json:"score"`
+	Snippets []Snippet `json:"snippets,omitempty"`
+}
+
+//scoredItem is how mongo hands back an item along with the
+//textScore it was projected with.
+type scoredItem struct {
+	TodoItem `bson:",inline"`
+	Score    float64 `bson:"score"`
+}
+
+var textIndexLock sync.Mutex
+var textIndexReady bool
+
+//searchText collects every string inside content, nested maps
+//and arrays included, so that it can be text indexed. Content
+//decoded by mongo holds primitive types instead of plain maps
+//and slices.
+func searchText(value interface{}) []string {
+	var result []string
+	switch v := value.(type) {
+	case string:
+		result = append(result, v)
+	case map[string]interface{}:
+		for _, nested := range v {
+			result = append(result, searchText(nested)...)
+		}
+	case primitive.M:
+		result = searchText(map[string]interface{}(v))
+	case primitive.D:
+		for _, nested := range v {
+			result = append(result, searchText(nested.Value)...)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			result = append(result, searchText(nested)...)
+		}
+	case primitive.A:
+		result = searchText([]interface{}(v))
+	}
+	return result
+}
+
+func (todoItem *TodoItem) updateSearchText() {
+	todoItem.SearchText = strings.Join(searchText(todoItem.Content), "\n")
+}
+
+func ensureTextIndex(context context.Context, dbClient *mongo.Client) error {
+	textIndexLock.Lock()
+	defer textIndexLock.Unlock()
+	if textIndexReady {
+		return nil
+	}
+	collection := database.GetTodoListCollection(dbClient)
+	index := mongo.IndexModel{
+		Keys: bson.D{{Key: "name", Value: "text"}, {Key: "searchtext", Value: "text"}},
+		Options: options.Index().SetName(textIndexName).SetWeights(bson.M{
+			"name":       nameSearchWeight,
+			"searchtext": contentSearchWeight,
+		}),
+	}
+	_, err := collection.Indexes().CreateOne(context, index)
+	if err != nil {
+		logging.Errorf("Error creating text index: %v", err)
+		return err
+	}
+	textIndexReady = true
+	return nil
+}
+
+func accessibleBy(userID string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"owner": userID},
+		bson.M{"sharedwith": userID},
+	}}
+}
+
+//SearchItems does a ranked full text search over the name and
+//content of the items userID owns or which are shared with it.
+//When the text index can't be used the search is done by an
+//in memory SearchIndex over the accessible items instead.
+func SearchItems(context context.Context, dbClient *mongo.Client, userID, text string, count uint) ([]SearchResult, error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+	results, err := mongoSearch(context, dbClient, userID, text, count)
+	if err != nil {
+		logging.FromContext(context).Warnf("Text search failed for %s, searching in memory: %v", userID, err)
+		results, err = memorySearch(context, dbClient, userID, text, count)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for idx := range results {
+		results[idx].Snippets = makeSnippets(&results[idx].Item, terms)
+	}
+	return results, nil
+}
+
+func mongoSearch(context context.Context, dbClient *mongo.Client, userID, text string, count uint) ([]SearchResult, error) {
+	if err := ensureTextIndex(context, dbClient); err != nil {
+		return nil, err
+	}
+	query := accessibleBy(userID)
+	query["$text"] = bson.M{"$search": text}
+	score := bson.M{"$meta": "textScore"}
+	findOpts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}})
+	if count > 0 {
+		findOpts.SetLimit(int64(count))
+	}
+	collection := database.GetTodoListCollection(dbClient)
+	cursor, err := collection.Find(context, query, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context)
+	var scored []scoredItem
+	if err = cursor.All(context, &scored); err != nil {
+		return nil, err
+	}
+	results := []SearchResult{}
+	for _, item := range scored {
+		results = append(results, SearchResult{Item: item.TodoItem, Score: item.Score})
+	}
+	return results, nil
+}
+
+func memorySearch(context context.Context, dbClient *mongo.Client, userID, text string, count uint) ([]SearchResult, error) {
+	collection := database.GetTodoListCollection(dbClient)
+	cursor, err := collection.Find(context, accessibleBy(userID))
+	if err != nil {
+		return nil, errors.Errorf("No TODO items found for %s", userID)
+	}
+	defer cursor.Close(context)
+	var items []TodoItem
+	if err = cursor.All(context, &items); err != nil {
+		logging.FromContext(context).Errorf("Error iterating cursor: %v", err)
+		return nil, errors.Errorf("Couldn't decode TodoItems for %s", userID)
+	}
+	index := NewSearchIndex()
+	for idx := range items {
+		index.Add(&items[idx])
+	}
+	results := index.Search(text)
+	if count > 0 && uint(len(results)) > count {
+		results = results[:count]
+	}
+	return results, nil
+}
+
+//searchTerms lower cases text and splits it into words, the
+//same way for queries and for indexed text.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func makeSnippets(item *TodoItem, terms []string) []Snippet {
+	var snippets []Snippet
+	if snippet, ok := highlight(item.Name, terms); ok {
+		snippets = append(snippets, Snippet{Field: "name", Text: snippet})
+	}
+	for _, text := range searchText(item.Content) {
+		if snippet, ok := highlight(text, terms); ok {
+			snippets = append(snippets, Snippet{Field: "content", Text: snippet})
+			break
+		}
+	}
+	return snippets
+}
+
+//highlight cuts a window of text around the first matching
+//term and marks every match inside the window.
+func highlight(text string, terms []string) (string, bool) {
+	lower := strings.ToLower(text)
+	first := -1
+	for _, term := range terms {
+		if idx := strings.Index(lower, term); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+	//Lower casing can change byte lengths, in which case
+	//offsets into lower don't apply to text.
+	if first < 0 || len(lower) != len(text) {
+		return "", false
+	}
+	start := first - snippetContext
+	if start < 0 {
+		start = 0
+	}
+	end := first + snippetContext
+	if end > len(text) {
+		end = len(text)
+	}
+	for start > 0 && !utf8Start(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8Start(text[end]) {
+		end++
+	}
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("…")
+	}
+	window, lowerWindow := text[start:end], lower[start:end]
+	for pos := 0; pos < len(window); {
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(lowerWindow[pos:], term) && len(term) > len(matched) {
+				matched = term
+			}
+		}
+		if matched == "" {
+			next := pos + 1
+			for next < len(window) && !utf8Start(window[next]) {
+				next++
+			}
+			builder.WriteString(html.EscapeString(window[pos:next]))
+			pos = next
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("<em>%s</em>", html.EscapeString(window[pos:pos+len(matched)])))
+		pos += len(matched)
+	}
+	if end < len(text) {
+		builder.WriteString("…")
+	}
+	return builder.String(), true
+}
+
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+//SearchIndex is a simple inverted index from terms to the
+//items containing them. Items are keyed by owner and ID since
+//IDs are only unique per owner. Terms in the name count nameSearchWeight
+//times, the score is weighted term frequency times inverse
+//document frequency.
+type SearchIndex struct {
+	sync.Mutex
+	postings map[string]map[string]int
+	items    map[string]*TodoItem
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: map[string]map[string]int{},
+		items:    map[string]*TodoItem{},
+	}
+}
+
+func searchIndexKey(owner, itemID string) string {
+	return owner + "/" + itemID
+}
+
+func (index *SearchIndex) Add(item *TodoItem) {
+	index.Lock()
+	defer index.Unlock()
+	key := searchIndexKey(item.Owner, item.ID)
+	index.remove(key)
+	index.items[key] = item
+	add := func(text string, weight int) {
+		for _, term := range searchTerms(text) {
+			if index.postings[term] == nil {
+				index.postings[term] = map[string]int{}
+			}
+			index.postings[term][key] += weight
+		}
+	}
+	add(item.Name, nameSearchWeight)
+	for _, text := range searchText(item.Content) {
+		add(text, contentSearchWeight)
+	}
+}
+
+func (index *SearchIndex) Remove(owner, itemID string) {
+	index.Lock()
+	defer index.Unlock()
+	index.remove(searchIndexKey(owner, itemID))
+}
+
+func (index *SearchIndex) remove(key string) {
+	if _, ok := index.items[key]; !ok {
+		return
+	}
+	delete(index.items, key)
+	for term, posting := range index.postings {
+		delete(posting, key)
+		if len(posting) == 0 {
+			delete(index.postings, term)
+		}
+	}
+}
+
+//Search returns the items containing any of the terms of
+//text, best match first.
+func (index *SearchIndex) Search(text string) []SearchResult {
+	index.Lock()
+	defer index.Unlock()
+	scores := map[string]float64{}
+	for _, term := range searchTerms(text) {
+		posting := index.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		idf := 1 + float64(len(index.items))/float64(len(posting))
+		for key, frequency := range posting {
+			scores[key] += float64(frequency) * idf
+		}
+	}
+	results := []SearchResult{}
+	for key, score := range scores {
+		results = append(results, SearchResult{Item: *index.items[key], Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Item.ID < results[j].Item.ID
+	})
+	return results
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+	"todolist/database"
+	"todolist/logging"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//SyncStateRetention is how long a sync token stays usable,
+//clients with an older one start over.
+const SyncStateRetention = 30 * 24 * time.Hour
+
+var syncIndexLock sync.Mutex
+var syncIndexReady bool
+
+//SyncEntry is an item as a client last saw it.
+type SyncEntry struct {
+	ID   string
+	ETag string
+}
+
+//SyncState is what a list held when Token was handed out, so
+//that clients coming back with it hear only what changed.
+type SyncState struct {
+	Owner   string
+	List    string
+	Token   string
+	Entries []SyncEntry
+	Time    time.Time
+}
+
+func ensureSyncIndex(context context.Context, dbClient *mongo.Client) {
+	syncIndexLock.Lock()
+	defer syncIndexLock.Unlock()
+	if syncIndexReady {
+		return
+	}
+	collection := database.GetSyncCollection(dbClient)
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "time", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(SyncStateRetention.Seconds())),
+	}
+	if _, err := collection.Indexes().CreateOne(context, index); err != nil {
+		logging.Errorf("Error creating sync state index: %v", err)
+		return
+	}
+	syncIndexReady = true
+}
+
+//SaveSyncState stores state, or keeps the one stored with the
+//same token a while longer.
+func SaveSyncState(context context.Context, dbClient *mongo.Client, state *SyncState) bool {
+	ensureSyncIndex(context, dbClient)
+	query := bson.M{
+		"owner": state.Owner,
+		"list":  state.List,
+		"token": state.Token,
+	}
+	update := bson.M{
+		"$set":         bson.M{"time": state.Time},
+		"$setOnInsert": bson.M{"entries": state.Entries},
+	}
+	collection := database.GetSyncCollection(dbClient)
+	_, err := collection.UpdateOne(context, query, update, options.Update().SetUpsert(true))
+	if err != nil {
+		logging.FromContext(context).Errorf("Error saving sync state of list %s for owner %s: %v", state.List, state.Owner, err)
+		return false
+	}
+	return true
+}
+
+//GetSyncState is the state token was handed out for, nil if
+//it's unknown or has expired.
+func GetSyncState(context context.Context, dbClient *mongo.Client, owner, list, token string) *SyncState {
+	query := bson.M{
+		"owner": owner,
+		"list":  list,
+		"token": token,
+	}
+	state := &SyncState{}
+	collection := database.GetSyncCollection(dbClient)
+	if err := collection.FindOne(context, query).Decode(state); err != nil {
+		logging.FromContext(context).Debugf("No sync state of list %s for owner %s with token %s", list, owner, token)
+		return nil
+	}
+	if time.Since(state.Time) > SyncStateRetention {
+		return nil
+	}
+	return state
+}
+
+//GetSyncStates are the states kept for the lists of owner.
+func GetSyncStates(context context.Context, dbClient *mongo.Client, owner string) ([]SyncState, error) {
+	query := bson.M{
+		"owner": owner,
+	}
+	collection := database.GetSyncCollection(dbClient)
+	cursor, err := collection.Find(context, query)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error finding sync states of owner %s: %v", owner, err)
+		return nil, err
+	}
+	defer cursor.Close(context)
+	states := []SyncState{}
+	if err = cursor.All(context, &states); err != nil {
+		logging.FromContext(context).Errorf("Error iterating cursor: %v", err)
+		return nil, err
+	}
+	return states, nil
+}
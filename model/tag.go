@@ -0,0 +1,340 @@
+package model
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"todolist/database"
+	"todolist/logging"
+	"todolist/utils"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	//DefaultTagColor is used for tags which are created
+	//implicitly by tagging an item.
+	DefaultTagColor = "#9e9e9e"
+	maxTagLength    = 64
+)
+
+var tagColorRegex = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+
+var tagIndexLock sync.Mutex
+var tagIndexReady bool
+
+//Tag is an entry in a user's tag catalog. Items refer
+//to tags by name only, the catalog keeps the color.
+type Tag struct {
+	Owner string `json:"-"`
+	Name  string `json:"name" validate:"required"`
+	Color string `json:"color,omitempty"`
+}
+
+func ValidTagColor(color string) bool {
+	return tagColorRegex.MatchString(color)
+}
+
+//NormalizeTagName trims the tag name and checks that it can
+//be used in a query parameter, commas separate tags there.
+func NormalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("empty tag name")
+	}
+	if len(name) > maxTagLength {
+		return "", errors.Errorf("tag %s is longer than %d characters", name, maxTagLength)
+	}
+	if strings.ContainsAny(name, ",") {
+		return "", errors.Errorf("tag %s contains a comma", name)
+	}
+	return name, nil
+}
+
+//NormalizeTagNames normalizes every name and drops duplicates
+//keeping the order in which they were given.
+func NormalizeTagNames(names []string) ([]string, error) {
+	var result utils.StringSlice
+	for _, name := range names {
+		normalized, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if !result.Contains(normalized) {
+			result = append(result, normalized)
+		}
+	}
+	return result, nil
+}
+
+//ensureTagIndex makes names unique per owner, so that tags
+//added at the same time can't end up in the catalog twice.
+func ensureTagIndex(context context.Context, dbClient *mongo.Client) {
+	tagIndexLock.Lock()
+	defer tagIndexLock.Unlock()
+	if tagIndexReady {
+		return
+	}
+	collection := database.GetTagCollection(dbClient)
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := collection.Indexes().CreateOne(context, index); err != nil {
+		logging.Errorf("Error creating tag index: %v", err)
+		return
+	}
+	tagIndexReady = true
+}
+
+//isDuplicateKey tells whether err is a unique index refusing
+//a write.
+func isDuplicateKey(err error) bool {
+	const duplicateKeyCode = 11000
+	switch e := errors.Cause(err).(type) {
+	case mongo.WriteException:
+		for _, writeErr := range e.WriteErrors {
+			if writeErr.Code == duplicateKeyCode {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == duplicateKeyCode
+	}
+	return false
+}
+
+func GetTagsForOwner(context context.Context, dbClient *mongo.Client, owner string) ([]Tag, error) {
+	query := bson.M{
+		"owner": owner,
+	}
+	findOpts := options.Find().SetSort(bson.M{"name": 1})
+	collection := database.GetTagCollection(dbClient)
+	cursor, err := collection.Find(context, query, findOpts)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error finding tags for owner %s: %v", owner, err)
+		return nil, errors.Errorf("No tags found for owner %s", owner)
+	}
+	defer cursor.Close(context)
+	tags := []Tag{}
+	err = cursor.All(context, &tags)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error iterating cursor: %v", err)
+		return nil, errors.Errorf("Couldn't decode tags for owner %s", owner)
+	}
+	return tags, nil
+}
+
+func GetTag(context context.Context, dbClient *mongo.Client, owner, name string) (*Tag, error) {
+	query := bson.M{
+		"owner": owner,
+		"name":  name,
+	}
+	collection := database.GetTagCollection(dbClient)
+	tag := &Tag{}
+	err := collection.FindOne(context, query).Decode(tag)
+	if err != nil {
+		return nil, errors.Errorf("No tag %s found for owner %s", name, owner)
+	}
+	return tag, nil
+}
+
+func (tag *Tag) Add(context context.Context, dbClient *mongo.Client) bool {
+	if tag.Color == "" {
+		tag.Color = DefaultTagColor
+	}
+	ensureTagIndex(context, dbClient)
+	collection := database.GetTagCollection(dbClient)
+	_, err := collection.InsertOne(context, tag)
+	if isDuplicateKey(err) {
+		logging.FromContext(context).Debugf("Tag %s already exists for owner %s", tag.Name, tag.Owner)
+		return false
+	}
+	if err != nil {
+		logging.FromContext(context).Errorf("Error adding tag %s for owner %s: %v", tag.Name, tag.Owner, err)
+		return false
+	}
+	logging.FromContext(context).Debugf("Added tag %s for owner %s", tag.Name, tag.Owner)
+	return true
+}
+
+//Modify only changes the color, use RenameTag to change
+//the name since that needs to touch the items as well.
+func (tag *Tag) Modify(context context.Context, dbClient *mongo.Client) bool {
+	query := bson.M{
+		"owner": tag.Owner,
+		"name":  tag.Name,
+	}
+	update := bson.M{
+		"$set": bson.M{"color": tag.Color},
+	}
+	collection := database.GetTagCollection(dbClient)
+	res, err := collection.UpdateOne(context, query, update)
+	if err != nil || res.MatchedCount == 0 {
+		logging.FromContext(context).Errorf("Error updating tag %s for owner %s", tag.Name, tag.Owner)
+		return false
+	}
+	logging.FromContext(context).Debugf("Updated tag %s for owner %s", tag.Name, tag.Owner)
+	return true
+}
+
+//Remove deletes the tag from the catalog and from every
+//item of the owner in a single transaction.
+func (tag *Tag) Remove(context context.Context, dbClient *mongo.Client) error {
+	return WithTransaction(context, dbClient, func(sc mongo.SessionContext) error {
+		res, err := database.GetTagCollection(dbClient).DeleteOne(sc, bson.M{
+			"owner": tag.Owner,
+			"name":  tag.Name,
+		})
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount == 0 {
+			return errors.Errorf("No tag %s found for owner %s", tag.Name, tag.Owner)
+		}
+		_, err = database.GetTodoListCollection(dbClient).UpdateMany(sc,
+			bson.M{"owner": tag.Owner, "tags": tag.Name},
+			bson.M{"$pull": bson.M{"tags": tag.Name}})
+		return err
+	})
+}
+
+//RegisterTags makes sure every name has an entry in the owner's
+//catalog, creating missing ones with the default color.
+func RegisterTags(context context.Context, dbClient *mongo.Client, owner string, names []string) bool {
+	ensureTagIndex(context, dbClient)
+	collection := database.GetTagCollection(dbClient)
+	for _, name := range names {
+		query := bson.M{
+			"owner": owner,
+			"name":  name,
+		}
+		update := bson.M{
+			"$setOnInsert": bson.M{"color": DefaultTagColor},
+		}
+		_, err := collection.UpdateOne(context, query, update, options.Update().SetUpsert(true))
+		//Another request registering the same name first is fine.
+		if err != nil && !isDuplicateKey(err) {
+			logging.FromContext(context).Errorf("Error registering tag %s for owner %s: %v", name, owner, err)
+			return false
+		}
+	}
+	return true
+}
+
+//RenameTag renames a catalog entry and every item carrying it.
+//Renaming onto an existing tag is refused, use MergeTags for it.
+func RenameTag(context context.Context, dbClient *mongo.Client, owner, from, to string) error {
+	from, err := NormalizeTagName(from)
+	if err != nil {
+		return err
+	}
+	if to, err = NormalizeTagName(to); err != nil {
+		return err
+	}
+	ensureTagIndex(context, dbClient)
+	return WithTransaction(context, dbClient, func(sc mongo.SessionContext) error {
+		collection := database.GetTagCollection(dbClient)
+		count, err := collection.CountDocuments(sc, bson.M{"owner": owner, "name": to})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.Errorf("tag %s already exists", to)
+		}
+		res, err := collection.UpdateOne(sc,
+			bson.M{"owner": owner, "name": from},
+			bson.M{"$set": bson.M{"name": to}})
+		if isDuplicateKey(err) {
+			return errors.Errorf("tag %s already exists", to)
+		}
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return errors.Errorf("No tag %s found for owner %s", from, owner)
+		}
+		return retagItems(sc, dbClient, owner, []string{from}, to)
+	})
+}
+
+//MergeTags folds all the from tags into the existing tag into,
+//the from tags are removed from the catalog.
+func MergeTags(context context.Context, dbClient *mongo.Client, owner string, from []string, into string) error {
+	into, err := NormalizeTagName(into)
+	if err != nil {
+		return err
+	}
+	names, err := NormalizeTagNames(from)
+	if err != nil {
+		return err
+	}
+	var sources []string
+	for _, name := range names {
+		if name != into {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) == 0 {
+		return errors.New("nothing to merge")
+	}
+	return WithTransaction(context, dbClient, func(sc mongo.SessionContext) error {
+		collection := database.GetTagCollection(dbClient)
+		count, err := collection.CountDocuments(sc, bson.M{"owner": owner, "name": into})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.Errorf("No tag %s found for owner %s", into, owner)
+		}
+		res, err := collection.DeleteMany(sc,
+			bson.M{"owner": owner, "name": bson.M{"$in": sources}})
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount != int64(len(sources)) {
+			return errors.Errorf("Not all of %v found for owner %s", sources, owner)
+		}
+		return retagItems(sc, dbClient, owner, sources, into)
+	})
+}
+
+//A single update can't both add to and pull from the tags
+//array, so it's done in two steps within the caller's transaction.
+func retagItems(sc mongo.SessionContext, dbClient *mongo.Client, owner string, from []string, to string) error {
+	collection := database.GetTodoListCollection(dbClient)
+	query := bson.M{
+		"owner": owner,
+		"tags":  bson.M{"$in": from},
+	}
+	res, err := collection.UpdateMany(sc, query, bson.M{"$addToSet": bson.M{"tags": to}})
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateMany(sc, query, bson.M{"$pull": bson.M{"tags": bson.M{"$in": from}}})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(sc).Debugf("Retagged %d item(s) of owner %s from %v to %s", res.MatchedCount, owner, from, to)
+	return nil
+}
+
+//WithTransaction runs fn in a transaction which is committed
+//if fn returns nil and aborted otherwise. fn may be run again
+//when the transaction hits a transient error.
+func WithTransaction(context context.Context, dbClient *mongo.Client, fn func(sc mongo.SessionContext) error) error {
+	session, err := dbClient.StartSession()
+	if err != nil {
+		logging.FromContext(context).Errorf("Error starting session: %v", err)
+		return err
+	}
+	defer session.EndSession(context)
+	_, err = session.WithTransaction(context, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
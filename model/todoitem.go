@@ -1,9 +1,12 @@
 package model
 
 import (
-	"log"
+	"context"
+	"reflect"
+	"strings"
+	"time"
 	"todolist/database"
-	"todolist/utils"
+	"todolist/logging"
 
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
@@ -11,99 +14,173 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+//TodoItem is an entry of a user's todo list. Actions, StartTime,
+//EndTime, ID and SharedWith have always been sent even when
+//they're empty, clients rely on the keys being there.
 type TodoItem struct {
 	Owner     string                 `json:"-"`
 	Name      string                 `json:"name"`
 	Content   map[string]interface{} `json:"content,omitempty"`
-	Actions   map[string]interface{} `json:"actions",omitempty`
-	StartTime string                 `json:"start_time",omitempty`
-	EndTime   string                 `json:"end_time", omitempty`
-	ID        string                 `json:"id", omitempty`
+	Actions   map[string]interface{} `json:"actions"`
+	StartTime string                 `json:"start_time"`
+	EndTime   string                 `json:"end_time"`
+	ID        string                 `json:"id"`
 	//SharedWith contains the userIDs of the users
 	//This TodoItem is shared with.
-	SharedWith []string `json:"sharedWith", omitempty`
+	SharedWith []string `json:"sharedWith"`
+	//Tags are names from the owner's tag catalog.
+	Tags     []string `json:"tags,omitempty"`
+	Status   string   `json:"status,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+	//List is the name of the list this item is filed under.
+	List string `json:"list,omitempty"`
+	//Recurrence is an iCalendar RRULE value, such as
+	//FREQ=WEEKLY;BYDAY=MO, for items which come back.
+	Recurrence string `json:"recurrence,omitempty"`
+	//CompletedAt and CompletedBy are set by ApplyWorkflow when
+	//the item reaches the done state, clients can't set them.
+	CompletedAt string `json:"completed_at,omitempty"`
+	CompletedBy string `json:"completed_by,omitempty"`
+	//SearchText holds the strings found in Content, it's
+	//kept up to date by Add and Modify for the text index.
+	SearchText string `json:"-"`
+	//DueAt is EndTime parsed, so that due dates are compared as
+	//instants. It's kept up to date along with SearchText.
+	DueAt *time.Time `json:"-"`
 }
 
-var globalLock utils.Resource
+//Copy is a copy of the item whose slices and maps, at their
+//top level, are its own.
+func (todoItem *TodoItem) Copy() *TodoItem {
+	copied := *todoItem
+	copied.SharedWith = append([]string(nil), todoItem.SharedWith...)
+	copied.Tags = append([]string(nil), todoItem.Tags...)
+	copied.Content = copyMap(todoItem.Content)
+	copied.Actions = copyMap(todoItem.Actions)
+	if todoItem.DueAt != nil {
+		dueAt := *todoItem.DueAt
+		copied.DueAt = &dueAt
+	}
+	return &copied
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
 
-func (todoItem *TodoItem) RemoveFromShared(dbClient *mongo.Client, sharedUserID string) bool {
-	storedItem := &TodoItem{}
-	context := utils.GetContext()
-	query := bson.D{
-		{"sharedwith", bson.D{{"$in", bson.A{sharedUserID}}}},
-		{"ID", todoItem.ID},
+//RemoveFromShared takes sharedUserID off the users the item
+//with todoItem's ID is shared with, it's false if the item
+//isn't shared with it. todoItem is then the item as it's left.
+func (todoItem *TodoItem) RemoveFromShared(context context.Context, dbClient *mongo.Client, sharedUserID string) bool {
+	query := bson.M{
+		"sharedwith": sharedUserID,
+		"id":         todoItem.ID,
+	}
+	update := bson.M{
+		"$pull": bson.M{"sharedwith": sharedUserID},
 	}
 	collection := database.GetTodoListCollection(dbClient)
-	globalLock.Lock()
-	defer globalLock.Unlock()
-	err := collection.FindOne(context, query).Decode(storedItem)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(context, query, update, opts).Decode(todoItem)
 	if err != nil {
-		log.Printf("TodoItem with ID = %s, not shared with %s\n", todoItem.ID, sharedUserID)
+		logging.FromContext(context).Debugf("TodoItem with ID = %s, not shared with %s", todoItem.ID, sharedUserID)
 		return false
 	}
-
-	log.Printf("Found TodoItem with ID %s, shared with %v\n", storedItem.ID, storedItem.SharedWith)
-	var idx int
-	//Find where this ID is present in the Index
-	for __idx, value := range storedItem.SharedWith {
-		if value == sharedUserID {
-			idx = __idx
-			break
-		}
-	}
-	newSharedUsers := make([]string, len(storedItem.SharedWith)-1)
-	newSharedUsers = append(newSharedUsers, storedItem.SharedWith[:idx]...)
-	newSharedUsers = append(newSharedUsers, storedItem.SharedWith[idx+1:]...)
-	todoItem.SharedWith = newSharedUsers
-
-	return todoItem.Modify(dbClient)
+	logging.FromContext(context).Debugf("TodoItem with ID %s no longer shared with %s", todoItem.ID, sharedUserID)
+	return true
 }
 
-func (todoItem *TodoItem) Remove(dbClient *mongo.Client) bool {
-	context := utils.GetContext()
+func (todoItem *TodoItem) Remove(context context.Context, dbClient *mongo.Client) bool {
 	query := bson.M{
 		"owner": todoItem.Owner,
-		"ID":    todoItem.ID,
+		"id":    todoItem.ID,
 	}
 	collection := database.GetTodoListCollection(dbClient)
 	res, err := collection.DeleteOne(context, query)
-	if err != nil {
-		log.Printf("No document found for owner %s, with ID = %s\n", todoItem.Owner, todoItem.ID)
+	if err != nil || res.DeletedCount == 0 {
+		logging.FromContext(context).Debugf("No document found for owner %s, with ID = %s", todoItem.Owner, todoItem.ID)
 		return false
 	}
-	log.Printf("Removed %d item(s) for owner %s", res.DeletedCount, todoItem.Owner)
+	logging.FromContext(context).Debugf("Removed %d item(s) for owner %s", res.DeletedCount, todoItem.Owner)
 	return true
 }
 
-func RemoveAllItemsForOwner(dbClient *mongo.Client, owner string) bool {
-	context := utils.GetContext()
+func RemoveAllItemsForOwner(context context.Context, dbClient *mongo.Client, owner string) bool {
 	query := bson.M{
 		"owner": owner,
 	}
 	collection := database.GetTodoListCollection(dbClient)
 	res, err := collection.DeleteMany(context, query)
 	if err != nil {
-		log.Printf("No document found for owner %s\n", owner)
+		logging.FromContext(context).Debugf("No document found for owner %s", owner)
 		return false
 	}
-	log.Printf("Removed %d item(s) for owner %s", res.DeletedCount, owner)
+	logging.FromContext(context).Debugf("Removed %d item(s) for owner %s", res.DeletedCount, owner)
 	return true
 }
 
-func (todoItem *TodoItem) Add(dbClient *mongo.Client) bool {
-	context := utils.GetContext()
+func (todoItem *TodoItem) Add(context context.Context, dbClient *mongo.Client) bool {
+	todoItem.updateSearchText()
+	todoItem.DueAt = ParseItemTime(todoItem.EndTime)
 	collection := database.GetTodoListCollection(dbClient)
 	res, err := collection.InsertOne(context, todoItem)
 	if err != nil {
-		log.Printf("Error adding todoItem %v", *todoItem)
+		logging.FromContext(context).Errorf("Error adding todoItem %s for owner %s: %v", todoItem.ID, todoItem.Owner, err)
 		return false
 	}
-	log.Printf("Added a todoItem, %v with objectId %v\n", *todoItem, res.InsertedID)
+	logging.FromContext(context).Debugf("Added todoItem %s for owner %s with objectId %v", todoItem.ID, todoItem.Owner, res.InsertedID)
 	return true
 }
 
-func (todoItem *TodoItem) Modify(dbClient *mongo.Client) bool {
-	context := utils.GetContext()
+//ErrItemChanged is returned when the stored item was changed
+//by someone else between being read and being written.
+var ErrItemChanged = errors.New("item has changed")
+
+//unchangedQuery matches todoItem as long as it still has the
+//status stored was read with, transitions are checked against
+//that one.
+func (todoItem *TodoItem) unchangedQuery(stored *TodoItem) bson.M {
+	query := bson.M{
+		"owner": todoItem.Owner,
+		"id":    todoItem.ID,
+	}
+	if stored.Status == "" {
+		query["status"] = bson.M{"$in": bson.A{"", nil}}
+	} else {
+		query["status"] = stored.Status
+	}
+	return query
+}
+
+//Replace stores todoItem in place of stored, it returns
+//ErrItemChanged if the status of stored has changed meanwhile.
+func (todoItem *TodoItem) Replace(context context.Context, dbClient *mongo.Client, stored *TodoItem) error {
+	todoItem.updateSearchText()
+	todoItem.DueAt = ParseItemTime(todoItem.EndTime)
+	collection := database.GetTodoListCollection(dbClient)
+	res, err := collection.ReplaceOne(context, todoItem.unchangedQuery(stored), *todoItem)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error replacing todoItem for owner %s with ID = %s: %v", todoItem.Owner, todoItem.ID, err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		logging.FromContext(context).Debugf("TodoItem for Owner %s with ID = %s changed meanwhile", todoItem.Owner, todoItem.ID)
+		return ErrItemChanged
+	}
+	logging.FromContext(context).Debugf("Replaced TodoItem for Owner %s with ID = %s", todoItem.Owner, todoItem.ID)
+	return nil
+}
+
+func (todoItem *TodoItem) Modify(context context.Context, dbClient *mongo.Client) bool {
+	todoItem.updateSearchText()
+	todoItem.DueAt = ParseItemTime(todoItem.EndTime)
 	query := bson.M{
 		"owner": todoItem.Owner,
 		"id":    todoItem.ID,
@@ -111,53 +188,135 @@ func (todoItem *TodoItem) Modify(dbClient *mongo.Client) bool {
 	collection := database.GetTodoListCollection(dbClient)
 	res, err := collection.ReplaceOne(context, query, *todoItem)
 	if err != nil {
-		log.Printf("Error updating todoItem for owner %s with ID = %s\n", todoItem.Owner, todoItem.ID)
+		logging.FromContext(context).Errorf("Error updating todoItem for owner %s with ID = %s", todoItem.Owner, todoItem.ID)
 		return false
 	}
-	log.Printf("Updated %d TodoItem for Owner %s with ID = %s\n", res.MatchedCount, todoItem.Owner, todoItem.ID)
+	logging.FromContext(context).Debugf("Updated %d TodoItem for Owner %s with ID = %s", res.MatchedCount, todoItem.Owner, todoItem.ID)
 	return true
 }
 
-func GetOneTodoItemForOwner(dbClient *mongo.Client, owner, todoItemID string) (*TodoItem, error) {
+//itemFieldIndex maps the json names of TodoItem fields to
+//their index, mongo knows them by their lower cased Go name.
+func itemFieldIndex(jsonName string) (int, bool) {
+	itemType := reflect.TypeOf(TodoItem{})
+	for idx := 0; idx < itemType.NumField(); idx++ {
+		if strings.Split(itemType.Field(idx).Tag.Get("json"), ",")[0] == jsonName {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+//updatePath turns the json path of a changed value into the
+//mongo path to set, and the value to set it to. Paths into
+//Content and Actions stop above keys mongo can't have in a
+//path. The value is nil when it's to be unset.
+func (todoItem *TodoItem) updatePath(path []string) (string, interface{}, bool) {
+	idx, ok := itemFieldIndex(path[0])
+	if !ok {
+		return "", nil, false
+	}
+	itemValue := reflect.ValueOf(todoItem).Elem()
+	field := strings.ToLower(itemValue.Type().Field(idx).Name)
+	value := itemValue.Field(idx).Interface()
+	keys := []string{field}
+	for _, key := range path[1:] {
+		members, isObject := value.(map[string]interface{})
+		if !isObject || key == "" || strings.Contains(key, ".") || strings.HasPrefix(key, "$") {
+			break
+		}
+		member, ok := members[key]
+		if !ok {
+			return strings.Join(append(keys, key), "."), nil, true
+		}
+		keys = append(keys, key)
+		value = member
+	}
+	return strings.Join(keys, "."), value, true
+}
+
+//UpdateFields stores the values of todoItem at paths, given by
+//json member names, and leaves the rest of the stored item as
+//it is. Values no longer in todoItem are unset. Like Replace it
+//returns ErrItemChanged if the status of stored has changed
+//meanwhile.
+func (todoItem *TodoItem) UpdateFields(context context.Context, dbClient *mongo.Client, stored *TodoItem, paths [][]string) error {
+	todoItem.updateSearchText()
+	todoItem.DueAt = ParseItemTime(todoItem.EndTime)
+	set := bson.M{"searchtext": todoItem.SearchText, "dueat": todoItem.DueAt}
+	unset := bson.M{}
+	var updated []string
+	for _, path := range paths {
+		key, value, ok := todoItem.updatePath(path)
+		if !ok {
+			logging.FromContext(context).Debugf("TodoItem has no field %s", path[0])
+			return errors.Errorf("TodoItem has no field %s", path[0])
+		}
+		updated = append(updated, key)
+		if value == nil {
+			unset[key] = ""
+		} else {
+			set[key] = value
+		}
+	}
+	//A path cut short may now cover others, mongo refuses
+	//updates of a field and a field within it.
+	for _, key := range updated {
+		for _, other := range updated {
+			if strings.HasPrefix(key, other+".") {
+				delete(set, key)
+				delete(unset, key)
+			}
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	collection := database.GetTodoListCollection(dbClient)
+	res, err := collection.UpdateOne(context, todoItem.unchangedQuery(stored), update)
+	if err != nil {
+		logging.FromContext(context).Errorf("Error updating fields %v of todoItem for owner %s with ID = %s: %v", updated, todoItem.Owner, todoItem.ID, err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		logging.FromContext(context).Debugf("TodoItem for Owner %s with ID = %s changed meanwhile", todoItem.Owner, todoItem.ID)
+		return ErrItemChanged
+	}
+	logging.FromContext(context).Debugf("Updated fields %v of TodoItem for Owner %s with ID = %s", updated, todoItem.Owner, todoItem.ID)
+	return nil
+}
+
+func GetOneTodoItemForOwner(context context.Context, dbClient *mongo.Client, owner, todoItemID string) (*TodoItem, error) {
 	query := bson.M{
 		"owner": owner,
 		"id":    todoItemID,
 	}
-	context := utils.GetContext()
 	collection := database.GetTodoListCollection(dbClient)
 	item := &TodoItem{}
 	err := collection.FindOne(context, query).Decode(item)
 	if err != nil {
-		log.Printf("No Item found for user %s with ID %s", owner, todoItemID)
+		logging.FromContext(context).Debugf("No Item found for user %s with ID %s", owner, todoItemID)
 		return nil, errors.Errorf("No Item found for user %s, item ID = %s", owner, todoItemID)
 	}
 	return item, nil
 }
 
-func GetOwnerItems(dbClient *mongo.Client, owner string, getShared bool, off uint, count uint) ([]TodoItem, error) {
-	query := bson.M{
-		"owner": owner,
-	}
-	if getShared {
-		query["sharedwith"] = bson.D{{"$in", bson.A{owner}}}
-	}
-	//Create empty Find option
-	findOpts := options.Find()
+//TagFilter restricts an ItemQuery to items carrying the
+//given tags. With MatchAll every tag must be present, otherwise
+//any one of them is enough.
+type TagFilter struct {
+	Tags     []string
+	MatchAll bool
+}
 
-	//If we need to skip things add the relevant
-	//option.
-	if off > 0 {
-		findOpts.SetSkip(int64(off))
-	}
-	//Limit the total records
-	if count > 0 {
-		findOpts.SetLimit(int64(count))
-	}
-	context := utils.GetContext()
+func GetOwnerItems(context context.Context, dbClient *mongo.Client, owner string, itemQuery *ItemQuery) ([]TodoItem, error) {
+	query := itemQuery.Filter(owner)
+	findOpts := itemQuery.FindOptions()
 	collection := database.GetTodoListCollection(dbClient)
 	cursor, err := collection.Find(context, query, findOpts)
 	if err != nil {
-		log.Printf("No TodoItem found for owner %s", owner)
+		logging.FromContext(context).Debugf("No TodoItem found for owner %s", owner)
 		return nil, errors.Errorf("No TODO items found for owner %s", owner)
 	}
 	defer cursor.Close(context)
@@ -165,9 +324,48 @@ func GetOwnerItems(dbClient *mongo.Client, owner string, getShared bool, off uin
 	var todoItems []TodoItem
 	err = cursor.All(context, &todoItems)
 	if err != nil {
-		log.Printf("Error iterating cursor: %v", err)
+		logging.FromContext(context).Errorf("Error iterating cursor: %v", err)
 		return nil, errors.Errorf("Couldn't decode TodoItems for owner %s", owner)
 	}
-	log.Printf("Sending todoItems as : %v", todoItems)
+	logging.FromContext(context).Debugf("Sending %d todoItems of owner %s", len(todoItems), owner)
 	return todoItems, nil
 }
+
+//EachOwnerItem calls fn with the owner's items matching
+//itemQuery one at a time, as the cursor gives them, so that
+//they don't all have to be in memory. It stops at the first
+//error fn returns, and returns it.
+func EachOwnerItem(context context.Context, dbClient *mongo.Client, owner string, itemQuery *ItemQuery, fn func(item *TodoItem) error) error {
+	return eachItem(context, dbClient, owner, itemQuery.Filter(owner), itemQuery.FindOptions(), fn)
+}
+
+//EachSharedItem is EachOwnerItem for the items other owners
+//share with user.
+func EachSharedItem(context context.Context, dbClient *mongo.Client, user string, fn func(item *TodoItem) error) error {
+	query := bson.M{
+		"sharedwith": user,
+		"owner":      bson.M{"$ne": user},
+	}
+	return eachItem(context, dbClient, user, query, options.Find(), fn)
+}
+
+func eachItem(context context.Context, dbClient *mongo.Client, user string, query bson.M, findOpts *options.FindOptions, fn func(item *TodoItem) error) error {
+	collection := database.GetTodoListCollection(dbClient)
+	cursor, err := collection.Find(context, query, findOpts)
+	if err != nil {
+		logging.FromContext(context).Errorf("Couldn't find TodoItems for user %s: %v", user, err)
+		return errors.Errorf("Couldn't find TODO items for user %s", user)
+	}
+	defer cursor.Close(context)
+	for cursor.Next(context) {
+		item := &TodoItem{}
+		if err = cursor.Decode(item); err != nil {
+			logging.FromContext(context).Errorf("Error decoding TodoItem of user %s: %v", user, err)
+			return errors.Errorf("Couldn't decode TodoItems for user %s", user)
+		}
+		if err = fn(item); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
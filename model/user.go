@@ -1,10 +1,10 @@
 package model
 
 import (
+	"context"
 	"errors"
-	"log"
 	"todolist/database"
-	"todolist/utils"
+	"todolist/logging"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -23,10 +23,31 @@ const (
 
 type User struct {
 	//This is a unique field.
-	ID         string                 `json:"id"`
+	ID         string                 `json:"id" validate:"required"`
 	Meta       map[string]interface{} `json:"extra,omitempty"  bson:"extra,omitempty"`
 	SignInType LoginType              `json:"-" bson:"type"`
-	Password   string                 `json:"pass"`
+	Password   string                 `json:"pass" validate:"required"`
+	//FeedToken is the hash of the token which lets calendar
+	//apps get the user's items without logging in.
+	FeedToken string `json:"-" bson:"feedtoken,omitempty"`
+}
+
+//String is the name of the auth provider, GetLoginType
+//takes it back.
+func (loginType LoginType) String() string {
+	switch loginType {
+	case GoogleLogin:
+		return "google"
+	case FacebookLogin:
+		return "facebook"
+	case TwitterLogin:
+		return "twitter"
+	case GithubLogin:
+		return "github"
+	case GitlabLogin:
+		return "gitlab"
+	}
+	return "web"
 }
 
 func GetLoginType(authProvider string) (LoginType, error) {
@@ -49,23 +70,22 @@ func GetLoginType(authProvider string) (LoginType, error) {
 	return loginType, err
 }
 
-func GetUser(dbClient *mongo.Client, id, password string) *User {
-	return __GetUser(dbClient, id, password, false)
+func GetUser(context context.Context, dbClient *mongo.Client, id, password string) *User {
+	return __GetUser(context, dbClient, id, password, false)
 }
 
-func GetUserForId(dbClient *mongo.Client, id string) *User {
-	return __GetUser(dbClient, id, "", true)
+func GetUserForId(context context.Context, dbClient *mongo.Client, id string) *User {
+	return __GetUser(context, dbClient, id, "", true)
 }
 
 //See if we can get a login Id and password
 //to match anything in the database.
-func __GetUser(dbClient *mongo.Client, id, password string, onlyId bool) *User {
+func __GetUser(context context.Context, dbClient *mongo.Client, id, password string, onlyId bool) *User {
 	u := &User{}
 	//Create a mongo query to find a user with
 	//matching id and password.
 	//For complex queries we'll use bson.D but since
 	//this is simple we use bson.M (map)
-	context := utils.GetContext()
 	query := bson.M{
 		"id": id,
 	}
@@ -80,23 +100,21 @@ func __GetUser(dbClient *mongo.Client, id, password string, onlyId bool) *User {
 	return nil
 }
 
-func AddUser(dbClient *mongo.Client, user *User) bool {
-	context := utils.GetContext()
-	if GetUserForId(dbClient, user.ID) != nil {
+func AddUser(context context.Context, dbClient *mongo.Client, user *User) bool {
+	if GetUserForId(context, dbClient, user.ID) != nil {
 		return false
 	}
 	collection := database.GetUserCollection(dbClient)
 	res, err := collection.InsertOne(context, *user)
 	if err != nil {
-		log.Printf("Error adding user %v: %s", *user, err)
+		logging.FromContext(context).Errorf("Error adding user %s: %v", user.ID, err)
 		return false
 	}
-	log.Printf("Added user %v to users collection with object id = %v\n", *user, res.InsertedID)
+	logging.FromContext(context).Debugf("Added user %s to users collection with object id = %v", user.ID, res.InsertedID)
 	return true
 }
 
-func (u *User) Update(dbClient *mongo.Client) {
-	context := utils.GetContext()
+func (u *User) Update(context context.Context, dbClient *mongo.Client) {
 	query := bson.M{
 		"id":       u.ID,
 		"password": u.Password,
@@ -104,6 +122,42 @@ func (u *User) Update(dbClient *mongo.Client) {
 	collection := database.GetUserCollection(dbClient)
 	result, err := collection.ReplaceOne(context, query, *u)
 	if err == nil {
-		log.Printf("Updated %d user(s)\n", result.MatchedCount)
+		logging.FromContext(context).Debugf("Updated %d user(s)", result.MatchedCount)
+	}
+}
+
+//SetFeedToken replaces the hash of the user's feed token, an
+//empty one revokes it.
+func SetFeedToken(context context.Context, dbClient *mongo.Client, id, tokenHash string) bool {
+	query := bson.M{
+		"id": id,
+	}
+	update := bson.M{"$set": bson.M{"feedtoken": tokenHash}}
+	if tokenHash == "" {
+		update = bson.M{"$unset": bson.M{"feedtoken": ""}}
+	}
+	collection := database.GetUserCollection(dbClient)
+	result, err := collection.UpdateOne(context, query, update)
+	if err != nil || result.MatchedCount == 0 {
+		logging.FromContext(context).Errorf("Couldn't set feed token of user %s: %v", id, err)
+		return false
+	}
+	return true
+}
+
+//GetUserForFeedToken finds the user whose feed token hashes to
+//tokenHash.
+func GetUserForFeedToken(context context.Context, dbClient *mongo.Client, tokenHash string) *User {
+	if tokenHash == "" {
+		return nil
+	}
+	u := &User{}
+	query := bson.M{
+		"feedtoken": tokenHash,
+	}
+	collection := database.GetUserCollection(dbClient)
+	if err := collection.FindOne(context, query).Decode(u); err != nil {
+		return nil
 	}
+	return u
 }
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"todolist/environment"
+	"todolist/logging"
+	"todolist/utils"
+
+	"github.com/pkg/errors"
+)
+
+//Priority levels of a TodoItem, PriorityNone is what items
+//created before priorities existed have.
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+//PriorityUnset is what handlers set Priority to before decoding
+//an edit into an item, it's left so when the edit leaves the
+//priority out and ApplyWorkflow keeps the stored one.
+const PriorityUnset = -1
+
+var PriorityNames = map[int]string{
+	PriorityNone:   "none",
+	PriorityLow:    "low",
+	PriorityMedium: "medium",
+	PriorityHigh:   "high",
+	PriorityUrgent: "urgent",
+}
+
+func ValidPriority(priority int) bool {
+	_, ok := PriorityNames[priority]
+	return ok
+}
+
+//ErrInvalidStatus and ErrInvalidTransition are returned by
+//ApplyWorkflow, the handlers tell the client which it was.
+var (
+	ErrInvalidStatus     = errors.New("unknown status")
+	ErrInvalidTransition = errors.New("status transition not allowed")
+	ErrInvalidPriority   = errors.New("unknown priority")
+)
+
+//Workflow is the set of states an item can be in and which
+//state can follow which. Items start in Initial, reaching Done
+//records when and by whom the item was completed.
+type Workflow struct {
+	States      []string            `json:"states"`
+	Initial     string              `json:"initial"`
+	Done        string              `json:"done"`
+	Transitions map[string][]string `json:"transitions"`
+}
+
+var DefaultWorkflow = Workflow{
+	States:  []string{"todo", "doing", "done"},
+	Initial: "todo",
+	Done:    "done",
+	Transitions: map[string][]string{
+		"todo":  {"doing", "done"},
+		"doing": {"todo", "done"},
+		"done":  {"todo"},
+	},
+}
+
+var workflowOnce sync.Once
+var workflow *Workflow
+
+//GetWorkflow returns the workflow configured in the environment,
+//falling back to DefaultWorkflow if there's none or it's broken.
+func GetWorkflow() *Workflow {
+	workflowOnce.Do(func() {
+		workflow = &DefaultWorkflow
+		spec := environment.GetItemWorkflow()
+		if spec == "" {
+			return
+		}
+		parsed, err := ParseWorkflow(spec, environment.GetItemWorkflowDoneState())
+		if err != nil {
+			logging.Warnf("Ignoring %s=%s: %v", environment.ItemWorkflow, spec, err)
+			return
+		}
+		workflow = parsed
+	})
+	return workflow
+}
+
+//ParseWorkflow reads a workflow of the form
+//	todo>doing,done;doing>todo,done;done>todo
+//where every state lists the states that may follow it. The
+//first state is the initial one, done must be one of them.
+func ParseWorkflow(spec, done string) (*Workflow, error) {
+	parsed := &Workflow{Done: done, Transitions: map[string][]string{}}
+	for _, rule := range strings.Split(spec, ";") {
+		parts := strings.Split(rule, ">")
+		from := strings.TrimSpace(parts[0])
+		if len(parts) > 2 || from == "" {
+			return nil, errors.Errorf("malformed rule %s", rule)
+		}
+		if utils.StringSlice(parsed.States).Contains(from) {
+			return nil, errors.Errorf("state %s given more than once", from)
+		}
+		parsed.States = append(parsed.States, from)
+		parsed.Transitions[from] = []string{}
+		if len(parts) == 2 {
+			for _, to := range strings.Split(parts[1], ",") {
+				if to = strings.TrimSpace(to); to != "" {
+					parsed.Transitions[from] = append(parsed.Transitions[from], to)
+				}
+			}
+		}
+	}
+	parsed.Initial = parsed.States[0]
+	for _, targets := range parsed.Transitions {
+		for _, to := range targets {
+			if !parsed.Valid(to) {
+				return nil, errors.Errorf("transition to unknown state %s", to)
+			}
+		}
+	}
+	if !parsed.Valid(done) {
+		return nil, errors.Errorf("done state %s is not a state", done)
+	}
+	return parsed, nil
+}
+
+func (wf *Workflow) Valid(state string) bool {
+	return utils.StringSlice(wf.States).Contains(state)
+}
+
+func (wf *Workflow) CanTransition(from, to string) bool {
+	return from == to || utils.StringSlice(wf.Transitions[from]).Contains(to)
+}
+
+//ApplyWorkflow checks the status and priority of an item being
+//added, or modified when stored is the item as it's in the
+//database, and fills in the server owned completion fields.
+//An empty status means the initial state for a new item and
+//no change for an existing one, PriorityUnset likewise means
+//none or no change.
+func (todoItem *TodoItem) ApplyWorkflow(stored *TodoItem, userID string) error {
+	wf := GetWorkflow()
+	if todoItem.Priority == PriorityUnset {
+		todoItem.Priority = PriorityNone
+		if stored != nil {
+			todoItem.Priority = stored.Priority
+		}
+	}
+	if !ValidPriority(todoItem.Priority) {
+		return ErrInvalidPriority
+	}
+	previous := ""
+	if stored != nil {
+		//Items stored before there were states are taken to
+		//be in the initial one.
+		previous = stored.Status
+		if previous == "" {
+			previous = wf.Initial
+		}
+	}
+	if todoItem.Status == "" {
+		todoItem.Status = previous
+		if todoItem.Status == "" {
+			todoItem.Status = wf.Initial
+		}
+	}
+	if !wf.Valid(todoItem.Status) {
+		return ErrInvalidStatus
+	}
+	if stored != nil && wf.Valid(previous) && !wf.CanTransition(previous, todoItem.Status) {
+		return ErrInvalidTransition
+	}
+	switch {
+	case todoItem.Status != wf.Done:
+		todoItem.CompletedAt = ""
+		todoItem.CompletedBy = ""
+	case stored != nil && previous == wf.Done:
+		todoItem.CompletedAt = stored.CompletedAt
+		todoItem.CompletedBy = stored.CompletedBy
+	default:
+		todoItem.CompletedAt = time.Now().UTC().Format(time.RFC3339)
+		todoItem.CompletedBy = userID
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWorkflow(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		done string
+		want *Workflow
+	}{
+		{"default", "todo>doing,done;doing>todo,done;done>todo", "done", &DefaultWorkflow},
+		{"spaces and a final state", " open > closed ; closed ", "closed", &Workflow{
+			States:      []string{"open", "closed"},
+			Initial:     "open",
+			Done:        "closed",
+			Transitions: map[string][]string{"open": {"closed"}, "closed": {}},
+		}},
+		{"malformed rule", "todo>doing>done", "done", nil},
+		{"no state", ">done", "done", nil},
+		{"state twice", "todo>done;todo>done;done", "done", nil},
+		{"unknown target", "todo>gone;done", "done", nil},
+		{"unknown done state", "todo>done;done", "finished", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseWorkflow(test.spec, test.done)
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("parsed %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parsed %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"todo", "doing", true},
+		{"todo", "done", true},
+		{"doing", "todo", true},
+		{"done", "todo", true},
+		{"done", "doing", false},
+		{"done", "done", true},
+		{"lost", "todo", false},
+	}
+	for _, test := range tests {
+		if got := DefaultWorkflow.CanTransition(test.from, test.to); got != test.want {
+			t.Errorf("%s to %s allowed %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestApplyWorkflow(t *testing.T) {
+	completed := TodoItem{Status: "done", Priority: PriorityHigh, CompletedAt: "2020-01-01T00:00:00Z", CompletedBy: "a"}
+	tests := []struct {
+		name      string
+		item      TodoItem
+		stored    *TodoItem
+		err       error
+		status    string
+		priority  int
+		completed bool
+	}{
+		{"new item", TodoItem{Priority: PriorityUnset}, nil, nil, "todo", PriorityNone, false},
+		{"new item done", TodoItem{Status: "done", Priority: PriorityLow}, nil, nil, "done", PriorityLow, true},
+		{"unknown status", TodoItem{Status: "lost"}, nil, ErrInvalidStatus, "", 0, false},
+		{"unknown priority", TodoItem{Priority: 9}, nil, ErrInvalidPriority, "", 0, false},
+		{"edit keeps status and priority", TodoItem{Priority: PriorityUnset}, &completed, nil, "done", PriorityHigh, true},
+		{"edit reopens", TodoItem{Status: "todo", Priority: PriorityUnset}, &completed, nil, "todo", PriorityHigh, false},
+		{"transition not allowed", TodoItem{Status: "doing"}, &completed, ErrInvalidTransition, "", 0, false},
+		{"stored before states", TodoItem{Status: "doing"}, &TodoItem{}, nil, "doing", PriorityNone, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := test.item
+			err := item.ApplyWorkflow(test.stored, "b")
+			if err != test.err {
+				t.Fatalf("error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if item.Status != test.status || item.Priority != test.priority {
+				t.Errorf("status %s and priority %d, want %s and %d", item.Status, item.Priority, test.status, test.priority)
+			}
+			if (item.CompletedAt != "") != test.completed || (item.CompletedBy != "") != test.completed {
+				t.Errorf("completed at %q by %q, want completed %v", item.CompletedAt, item.CompletedBy, test.completed)
+			}
+		})
+	}
+}
+
+//TestApplyWorkflowKeepsCompletion checks an item edited while
+//done still says when and by whom it was first completed.
+func TestApplyWorkflowKeepsCompletion(t *testing.T) {
+	stored := &TodoItem{Status: "done", CompletedAt: "2020-01-01T00:00:00Z", CompletedBy: "a"}
+	item := TodoItem{Name: "renamed", Priority: PriorityUnset}
+	if err := item.ApplyWorkflow(stored, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if item.CompletedAt != stored.CompletedAt || item.CompletedBy != "a" {
+		t.Errorf("completed at %s by %s, want %s by a", item.CompletedAt, item.CompletedBy, stored.CompletedAt)
+	}
+}
@@ -0,0 +1,168 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+//Schema is a JSON schema as OpenAPI 3.0 has it.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+}
+
+//Ref points to the component schema called name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+//Schemas builds schemas from Go types the way encoding/json
+//would encode them. Named structs are put into Components and
+//referred to, so each is described once.
+type Schemas struct {
+	Components map[string]*Schema
+	types      map[string]reflect.Type
+}
+
+func NewSchemas() *Schemas {
+	return &Schemas{Components: map[string]*Schema{}, types: map[string]reflect.Type{}}
+}
+
+//For returns the schema of the type of v.
+func (schemas *Schemas) For(v interface{}) *Schema {
+	return schemas.forType(reflect.TypeOf(v))
+}
+
+func (schemas *Schemas) forType(t reflect.Type) *Schema {
+	if t == rawMessageType {
+		//Any json value.
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemas.forType(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemas.forType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemas.forType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return schemas.forStruct(t)
+		}
+		name := schemas.componentName(t)
+		if _, ok := schemas.Components[name]; !ok {
+			//Claim the name first, the struct may refer to itself.
+			schemas.types[name] = t
+			schemas.Components[name] = &Schema{}
+			schemas.Components[name] = schemas.forStruct(t)
+		}
+		return Ref(name)
+	}
+	//interface{} and whatever else json can't say more about.
+	return &Schema{}
+}
+
+//componentName is the type name, with the package in front
+//when another type already has the name.
+func (schemas *Schemas) componentName(t reflect.Type) string {
+	name := strings.Title(t.Name())
+	if other, ok := schemas.types[name]; !ok || other == t {
+		return name
+	}
+	pkg := t.PkgPath()
+	if idx := strings.LastIndex(pkg, "/"); idx >= 0 {
+		pkg = pkg[idx+1:]
+	}
+	return strings.Title(pkg) + name
+}
+
+//forStruct follows the json tags, a validate:"required" tag
+//makes the member required.
+func (schemas *Schemas) forStruct(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := schemas.forStruct(indirect(field.Type))
+			for member, property := range embedded.Properties {
+				schema.Properties[member] = property
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = schemas.forType(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+	return schema
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+//Enum is a schema of type typ allowing only values.
+func Enum(typ, description string, values ...interface{}) *Schema {
+	return &Schema{Type: typ, Description: description, Enum: values}
+}
+
+//PathParams returns the {name} segments of a path pattern.
+func PathParams(pattern string) []string {
+	var params []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, segment[1:len(segment)-1])
+		}
+	}
+	return params
+}
+
+//OperationID makes an id such as getV2ItemsId out of a method
+//and path pattern.
+func OperationID(method, pattern string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(pattern, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '_' || r == '-'
+	}) {
+		id += strings.Title(part)
+	}
+	return id
+}
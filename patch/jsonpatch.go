@@ -0,0 +1,188 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//MediaType is the content type of JSON Patch documents,
+//RFC 6902.
+const MediaType = "application/json-patch+json"
+
+//Operation is one step of a JSON Patch. Value is kept raw so
+//that a null value can be told from a missing one.
+type Operation struct {
+	Op    string          `json:"op" validate:"required"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+//Error is an operation which can't be applied, Member is the
+//member of the operation at fault.
+type Error struct {
+	Index  int
+	Member string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("operation %d: %s: %s", e.Index, e.Member, e.Reason)
+}
+
+//TestFailedError is a test operation whose value differs from
+//the document's.
+type TestFailedError struct {
+	Index int
+	Path  string
+}
+
+func (e *TestFailedError) Error() string {
+	return fmt.Sprintf("operation %d: test of %s failed", e.Index, e.Path)
+}
+
+func add(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return update(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("%q is below a value which isn't an object or array", token)
+	})
+}
+
+func remove(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("the whole document can't be removed")
+	}
+	return update(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("member %q doesn't exist", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:idx], node[idx+1:]...), nil
+		}
+		return nil, fmt.Errorf("%q is below a value which isn't an object or array", token)
+	})
+}
+
+//deepCopy copies a value json.Unmarshal made into an
+//interface{}, so that copy doesn't leave two paths to it.
+func deepCopy(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(node))
+		for key, member := range node {
+			copied[key] = deepCopy(member)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(node))
+		for idx, item := range node {
+			copied[idx] = deepCopy(item)
+		}
+		return copied
+	}
+	return value
+}
+
+//apply runs one operation, the error names the member of the
+//operation at fault.
+func apply(doc interface{}, index int, op *Operation) (interface{}, error) {
+	fail := func(member string, err error) (interface{}, error) {
+		return nil, &Error{Index: index, Member: member, Reason: err.Error()}
+	}
+	path, err := ParsePointer(op.Path)
+	if err != nil {
+		return fail("path", err)
+	}
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return fail("value", fmt.Errorf("required"))
+		}
+		if err = json.Unmarshal(op.Value, &value); err != nil {
+			return fail("value", err)
+		}
+	case "move", "copy":
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return fail("from", err)
+		}
+		if value, err = get(doc, from); err != nil {
+			return fail("from", err)
+		}
+		if op.Op == "copy" {
+			value = deepCopy(value)
+			break
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return fail("from", fmt.Errorf("a value can't be moved into itself"))
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return fail("from", err)
+		}
+	}
+	switch op.Op {
+	case "add", "move", "copy":
+		doc, err = add(doc, path, value)
+	case "remove":
+		doc, err = remove(doc, path)
+	case "replace":
+		if _, err = get(doc, path); err == nil {
+			if len(path) == 0 {
+				doc = value
+			} else if doc, err = remove(doc, path); err == nil {
+				doc, err = add(doc, path, value)
+			}
+		}
+	case "test":
+		var current interface{}
+		if current, err = get(doc, path); err == nil && !reflect.DeepEqual(current, value) {
+			return nil, &TestFailedError{Index: index, Path: op.Path}
+		}
+	default:
+		return fail("op", fmt.Errorf("must be add, remove, replace, move, copy or test"))
+	}
+	if err != nil {
+		return fail("path", err)
+	}
+	return doc, nil
+}
+
+//Apply runs the operations on doc, a value json.Unmarshal made
+//into an interface{}, and returns the patched document. doc is
+//changed in place, it's only usable when Apply succeeds.
+func Apply(doc interface{}, ops []Operation) (interface{}, error) {
+	var err error
+	for idx := range ops {
+		if doc, err = apply(doc, idx, &ops[idx]); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,80 @@
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		ops    string
+		want   string
+		member string
+	}{
+		{"add member", `{"a":1}`, `[{"op":"add","path":"/b","value":2}]`, `{"a":1,"b":2}`, ""},
+		{"add replaces member", `{"a":1}`, `[{"op":"add","path":"/a","value":[1]}]`, `{"a":[1]}`, ""},
+		{"add into array", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`, ""},
+		{"add to end of array", `{"a":[1]}`, `[{"op":"add","path":"/a/-","value":2}]`, `{"a":[1,2]}`, ""},
+		{"add null", `{}`, `[{"op":"add","path":"/a","value":null}]`, `{"a":null}`, ""},
+		{"add without value", `{}`, `[{"op":"add","path":"/a"}]`, "", "value"},
+		{"add below missing", `{}`, `[{"op":"add","path":"/a/b","value":1}]`, "", "path"},
+		{"add past end", `{"a":[]}`, `[{"op":"add","path":"/a/1","value":1}]`, "", "path"},
+		{"whole document", `{"a":1}`, `[{"op":"add","path":"","value":[1]}]`, `[1]`, ""},
+		{"remove member", `{"a":1,"b":2}`, `[{"op":"remove","path":"/a"}]`, `{"b":2}`, ""},
+		{"remove from array", `{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/1"}]`, `{"a":[1,3]}`, ""},
+		{"remove missing", `{}`, `[{"op":"remove","path":"/a"}]`, "", "path"},
+		{"remove document", `{}`, `[{"op":"remove","path":""}]`, "", "path"},
+		{"replace", `{"a":{"b":1}}`, `[{"op":"replace","path":"/a/b","value":2}]`, `{"a":{"b":2}}`, ""},
+		{"replace missing", `{}`, `[{"op":"replace","path":"/a","value":2}]`, "", "path"},
+		{"move", `{"a":{"b":1},"c":{}}`, `[{"op":"move","from":"/a/b","path":"/c/d"}]`, `{"a":{},"c":{"d":1}}`, ""},
+		{"move in array", `{"a":[1,2,3]}`, `[{"op":"move","from":"/a/0","path":"/a/-"}]`, `{"a":[2,3,1]}`, ""},
+		{"move into itself", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, "", "from"},
+		{"move from missing", `{}`, `[{"op":"move","from":"/a","path":"/b"}]`, "", "from"},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`,
+			`{"a":{"b":1},"c":{"b":2}}`, ""},
+		{"test passes", `{"a":[1,{"b":"c"}]}`, `[{"op":"test","path":"/a","value":[1,{"b":"c"}]}]`,
+			`{"a":[1,{"b":"c"}]}`, ""},
+		{"test missing", `{}`, `[{"op":"test","path":"/a","value":1}]`, "", "path"},
+		{"unknown op", `{}`, `[{"op":"swap","path":"/a"}]`, "", "op"},
+		{"bad path", `{}`, `[{"op":"remove","path":"a"}]`, "", "path"},
+		{"escaped path", `{"a/b":1,"c~d":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/c~0d"}]`, `{}`, ""},
+		{"later operation fails", `{"a":1}`, `[{"op":"remove","path":"/a"},{"op":"remove","path":"/a"}]`, "", "path"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ops []Operation
+			if err := json.Unmarshal([]byte(test.ops), &ops); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply(decodeJSON(t, test.doc), ops)
+			if test.member != "" {
+				patchErr, ok := err.(*Error)
+				if !ok || patchErr.Member != test.member {
+					t.Errorf("error %v, want one of member %s", err, test.member)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("patched %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyTestFails(t *testing.T) {
+	ops := []Operation{
+		{Op: "test", Path: "/a", Value: json.RawMessage(`1`)},
+		{Op: "test", Path: "/b", Value: json.RawMessage(`"x"`)},
+	}
+	_, err := Apply(decodeJSON(t, `{"a":1,"b":"y"}`), ops)
+	failed, ok := err.(*TestFailedError)
+	if !ok || failed.Index != 1 || failed.Path != "/b" {
+		t.Errorf("error %v, want the test of /b to fail", err)
+	}
+}
@@ -0,0 +1,58 @@
+package patch
+
+import "reflect"
+
+//MergeMediaType is the content type of JSON Merge Patch
+//documents, RFC 7396.
+const MergeMediaType = "application/merge-patch+json"
+
+//Merge lays patch over target: objects are merged member by
+//member, a null member removes the member, anything else
+//replaces what's there. target is changed in place.
+func Merge(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = map[string]interface{}{}
+	}
+	for key, value := range members {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		doc[key] = Merge(doc[key], value)
+	}
+	return doc
+}
+
+//Diff lists the paths at which after differs from before. It
+//descends into objects both have, anything else which differs
+//is one path, a whole array for instance.
+func Diff(before, after map[string]interface{}) [][]string {
+	var paths [][]string
+	var diff func(prefix []string, before, after map[string]interface{})
+	diff = func(prefix []string, before, after map[string]interface{}) {
+		for key, value := range after {
+			path := append(append([]string{}, prefix...), key)
+			old, ok := before[key]
+			oldObject, oldIsObject := old.(map[string]interface{})
+			object, isObject := value.(map[string]interface{})
+			switch {
+			case ok && oldIsObject && isObject:
+				diff(path, oldObject, object)
+			case !ok || !reflect.DeepEqual(old, value):
+				paths = append(paths, path)
+			}
+		}
+		for key := range before {
+			if _, ok := after[key]; !ok {
+				paths = append(paths, append(append([]string{}, prefix...), key))
+			}
+		}
+	}
+	diff(nil, before, after)
+	return paths
+}
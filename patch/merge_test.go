@@ -0,0 +1,75 @@
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, data string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+//TestMerge holds the examples of RFC 7396.
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, test := range tests {
+		got := Merge(decodeJSON(t, test.target), decodeJSON(t, test.patch))
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s merged with %s is %v, want %v", test.target, test.patch, got, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []string
+	}{
+		{"same", `{"a":1,"b":{"c":[1]}}`, `{"a":1,"b":{"c":[1]}}`, nil},
+		{"changed", `{"a":1,"b":2}`, `{"a":1,"b":3}`, []string{"b"}},
+		{"added and removed", `{"a":1}`, `{"b":1}`, []string{"a", "b"}},
+		{"nested", `{"a":{"b":1,"c":2}}`, `{"a":{"b":1,"c":3,"d":4}}`, []string{"a/c", "a/d"}},
+		{"whole array", `{"a":[1,2]}`, `{"a":[1,3]}`, []string{"a"}},
+		{"object replaced", `{"a":{"b":1}}`, `{"a":1}`, []string{"a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := decodeJSON(t, test.before).(map[string]interface{})
+			after := decodeJSON(t, test.after).(map[string]interface{})
+			var got []string
+			for _, path := range Diff(before, after) {
+				got = append(got, strings.Join(path, "/"))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("paths %v, want %v", got, test.want)
+			}
+		})
+	}
+}
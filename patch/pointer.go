@@ -0,0 +1,102 @@
+package patch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//ParsePointer splits a JSON pointer, RFC 6901, into its
+//reference tokens. The pointer "" is the whole document and
+//has none.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q doesn't start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for idx, token := range tokens {
+		tokens[idx] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+//arrayIndex parses token as an index into an array of length
+//items. With end the index may be length, which "-" stands for.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%q isn't an array index", token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't an array index", token)
+	}
+	if idx > length || (idx == length && !end) {
+		return 0, fmt.Errorf("index %d is out of range", idx)
+	}
+	return idx, nil
+}
+
+//get returns the value tokens point to in doc.
+func get(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q doesn't exist", token)
+			}
+			doc = value
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[idx]
+		default:
+			return nil, fmt.Errorf("%q is below a value which isn't an object or array", token)
+		}
+	}
+	return doc, nil
+}
+
+//update replaces the container holding the last of tokens
+//with what change makes of it, and returns the new doc. Arrays
+//may move when they grow, so every container on the way is
+//written back.
+func update(doc interface{}, tokens []string,
+	change func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return change(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q doesn't exist", tokens[0])
+		}
+		child, err := update(child, tokens[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = child
+		return node, nil
+	case []interface{}:
+		idx, err := arrayIndex(tokens[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(node[idx], tokens[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = child
+		return node, nil
+	}
+	return nil, fmt.Errorf("%q is below a value which isn't an object or array", tokens[0])
+}
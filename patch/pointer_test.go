@@ -0,0 +1,61 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		want    []string
+		fails   bool
+	}{
+		{"", nil, false},
+		{"/", []string{""}, false},
+		{"/a/b", []string{"a", "b"}, false},
+		{"/a~1b/c~0d", []string{"a/b", "c~d"}, false},
+		{"/~01", []string{"~1"}, false},
+		{"a", nil, true},
+	}
+	for _, test := range tests {
+		got, err := ParsePointer(test.pointer)
+		if (err != nil) != test.fails {
+			t.Errorf("%q: error %v, want failure %v", test.pointer, err, test.fails)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: tokens %q, want %q", test.pointer, got, test.want)
+		}
+	}
+}
+
+func TestArrayIndex(t *testing.T) {
+	tests := []struct {
+		token  string
+		length int
+		end    bool
+		want   int
+		fails  bool
+	}{
+		{"0", 2, false, 0, false},
+		{"1", 2, false, 1, false},
+		{"2", 2, false, 0, true},
+		{"2", 2, true, 2, false},
+		{"3", 2, true, 0, true},
+		{"-", 2, true, 2, false},
+		{"-", 2, false, 0, true},
+		{"01", 2, false, 0, true},
+		{"-1", 2, false, 0, true},
+		{"a", 2, false, 0, true},
+		{"", 2, false, 0, true},
+		{"99999999999999999999", 2, false, 0, true},
+	}
+	for _, test := range tests {
+		got, err := arrayIndex(test.token, test.length, test.end)
+		if (err != nil) != test.fails || got != test.want {
+			t.Errorf("%q of %d, end %v: %d, %v, want %d, failure %v",
+				test.token, test.length, test.end, got, err, test.want, test.fails)
+		}
+	}
+}
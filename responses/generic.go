@@ -6,4 +6,18 @@ type Response struct {
 	APICodeDescription string                 `json:"apicode_desc,omitempty"`
 	Message            string                 `json:"msg,omitempty"`
 	Meta               map[string]interface{} `json:"extra,omitempty"`
+	Errors             []FieldError           `json:"errors,omitempty"`
+	//MessageArgs format Message once it's translated.
+	MessageArgs []interface{} `json:"-"`
+	//RequestID is the X-Request-ID of the request answered, to
+	//find it in the logs by.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+//FieldError names one part of the request which was wrong,
+//Field is a json path into the body, a query parameter or a
+//header name.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
 }
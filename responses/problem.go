@@ -0,0 +1,18 @@
+package responses
+
+//ProblemMediaType is the content type of Problem, RFC 7807.
+const ProblemMediaType = "application/problem+json"
+
+//Problem is the RFC 7807 form of an error Response, sent to
+//clients which ask for it in Accept.
+type Problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	APICode   int64                  `json:"apicode"`
+	Errors    []FieldError           `json:"errors,omitempty"`
+	Meta      map[string]interface{} `json:"extra,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
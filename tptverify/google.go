@@ -2,8 +2,8 @@ package tptverify
 
 import (
 	"errors"
-	"log"
 	"todolist/handlers/token"
+	"todolist/logging"
 )
 
 const (
@@ -30,7 +30,7 @@ func (gverifier *GoogleVerifier) Verify(tokenString string) (interface{}, error)
 	googleClaims, err := token.GetGoogleClaims(tokenString)
 
 	if err != nil {
-		log.Printf("Invalid google token %s", tokenString)
+		logging.Infof("Invalid google token: %v", err)
 		return nil, err
 	}
 	return googleClaims, nil
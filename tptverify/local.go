@@ -1,8 +1,8 @@
 package tptverify
 
 import (
-	"log"
 	"todolist/handlers/token"
+	"todolist/logging"
 
 	"github.com/pkg/errors"
 )
@@ -26,7 +26,7 @@ func (localVerifier *LocalVerifier) UserId(data interface{}) (string, error) {
 func (localVerifier *LocalVerifier) Verify(tokenString string) (interface{}, error) {
 	appClaims, err := token.GetUserClaims(tokenString)
 	if err != nil {
-		log.Printf("Invalid App Claim token %s\n", tokenString)
+		logging.Infof("Invalid App Claim token: %v", err)
 		return nil, err
 	}
 	return appClaims, nil
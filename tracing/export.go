@@ -0,0 +1,326 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todolist/environment"
+	"todolist/logging"
+)
+
+const (
+	queueSize      = 2048
+	maxBatch       = 512
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+	scopeName      = "todolist"
+)
+
+//Exporter sends ended spans somewhere they can be looked at.
+type Exporter interface {
+	Export(spans []*Span) error
+}
+
+//processor batches spans for the exporter so that ending one
+//never waits on it. Spans are dropped when it falls behind.
+type processor struct {
+	exporter Exporter
+	queue    chan *Span
+	flush    chan chan struct{}
+}
+
+var current struct {
+	sync.RWMutex
+	processor *processor
+}
+
+func init() {
+	name := strings.ToLower(strings.TrimSpace(environment.GetTracesExporter()))
+	switch name {
+	case "", "none":
+	case "stdout", "console":
+		SetExporter(NewStdoutExporter(os.Stdout))
+	case "otlp":
+		SetExporter(NewOTLPExporter(environment.GetOTLPEndpoint()))
+	default:
+		logging.Warnf("Ignoring %s=%s, spans aren't exported", environment.TracesExporter, name)
+	}
+}
+
+//SetExporter starts exporting spans with exporter, nil stops
+//exporting them.
+func SetExporter(exporter Exporter) {
+	var p *processor
+	if exporter != nil {
+		p = &processor{
+			exporter: exporter,
+			queue:    make(chan *Span, queueSize),
+			flush:    make(chan chan struct{}),
+		}
+		go p.run()
+	}
+	current.Lock()
+	previous := current.processor
+	current.processor = p
+	current.Unlock()
+	if previous != nil {
+		previous.Flush()
+	}
+}
+
+//Enabled tells whether spans are exported.
+func Enabled() bool {
+	current.RLock()
+	defer current.RUnlock()
+	return current.processor != nil
+}
+
+//Flush exports the spans ended so far.
+func Flush() {
+	current.RLock()
+	p := current.processor
+	current.RUnlock()
+	if p != nil {
+		p.Flush()
+	}
+}
+
+func enqueue(span *Span) {
+	current.RLock()
+	p := current.processor
+	current.RUnlock()
+	if p == nil {
+		return
+	}
+	select {
+	case p.queue <- span:
+	default:
+	}
+}
+
+func (p *processor) Flush() {
+	done := make(chan struct{})
+	p.flush <- done
+	<-done
+}
+
+func (p *processor) run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) < maxBatch {
+				continue
+			}
+		case <-ticker.C:
+		case done := <-p.flush:
+			for drained := false; !drained; {
+				select {
+				case span := <-p.queue:
+					batch = append(batch, span)
+				default:
+					drained = true
+				}
+			}
+			p.export(batch)
+			batch = nil
+			close(done)
+			continue
+		}
+		p.export(batch)
+		batch = nil
+	}
+}
+
+func (p *processor) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := p.exporter.Export(batch); err != nil {
+		logging.Warnf("Couldn't export %d spans: %v", len(batch), err)
+	}
+}
+
+//stdoutExporter writes spans as OTLP JSON, one export request
+//a line, which the collector's otlpjsonfile receiver reads.
+type stdoutExporter struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func NewStdoutExporter(w io.Writer) Exporter {
+	return &stdoutExporter{w: w}
+}
+
+func (exporter *stdoutExporter) Export(spans []*Span) error {
+	data, err := encodeSpans(spans)
+	if err != nil {
+		return err
+	}
+	exporter.Lock()
+	defer exporter.Unlock()
+	_, err = exporter.w.Write(append(data, '\n'))
+	return err
+}
+
+//otlpExporter posts spans to a collector with OTLP over HTTP,
+//JSON encoded.
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+//NewOTLPExporter sends spans to the collector at endpoint, the
+//base URL the /v1/traces path is added to.
+func NewOTLPExporter(endpoint string) Exporter {
+	return &otlpExporter{
+		url:    strings.TrimRight(endpoint, "/") + "/v1/traces",
+		client: &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (exporter *otlpExporter) Export(spans []*Span) error {
+	data, err := encodeSpans(spans)
+	if err != nil {
+		return err
+	}
+	resp, err := exporter.client.Post(exporter.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("collector at %s answered %s", exporter.url, resp.Status)
+	}
+	return nil
+}
+
+//The OTLP JSON encoding of export requests, ids are hex and
+//64 bit integers are strings.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func encodeSpans(spans []*Span) ([]byte, error) {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		encoded = append(encoded, encodeSpan(span))
+	}
+	request := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{newAttribute("service.name", environment.GetServiceName())},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName},
+				Spans: encoded,
+			}},
+		}},
+	}
+	return json.Marshal(request)
+}
+
+func encodeSpan(span *Span) otlpSpan {
+	span.Lock()
+	defer span.Unlock()
+	encoded := otlpSpan{
+		TraceID:           span.context.TraceID.String(),
+		SpanID:            span.context.SpanID.String(),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: span.status, Message: span.statusMessage},
+	}
+	if span.parent.IsValid() {
+		encoded.ParentSpanID = span.parent.String()
+	}
+	for _, attr := range span.attributes {
+		encoded.Attributes = append(encoded.Attributes, newAttribute(attr.key, attr.value))
+	}
+	return encoded
+}
+
+//newAttribute encodes value, strings go through the same
+//redaction as logs do.
+func newAttribute(key string, value interface{}) otlpAttribute {
+	attr := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case bool:
+		attr.Value.BoolValue = &v
+	case int:
+		text := strconv.Itoa(v)
+		attr.Value.IntValue = &text
+	case int32:
+		text := strconv.FormatInt(int64(v), 10)
+		attr.Value.IntValue = &text
+	case int64:
+		text := strconv.FormatInt(v, 10)
+		attr.Value.IntValue = &text
+	case float64:
+		attr.Value.DoubleValue = &v
+	default:
+		text := fmt.Sprint(logging.Redact(key, value))
+		attr.Value.StringValue = &text
+	}
+	return attr
+}
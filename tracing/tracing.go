@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//TraceparentHeader carries the trace a request is part of,
+//W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+//SpanContext is what identifies a span across processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+//Traceparent is sc as the value of the traceparent header.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+//ParseTraceparent reads the span a caller sent the request
+//from, versions after 00 are read as far as 00 goes.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	sc := SpanContext{}
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return sc, false
+	}
+	if len(parts[1]) != 2*len(sc.TraceID) || len(parts[2]) != 2*len(sc.SpanID) || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err = hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err = hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+type SpanKind int
+
+//Kinds of spans, numbered as in OTLP.
+const (
+	Internal = SpanKind(1)
+	Server   = SpanKind(2)
+	Client   = SpanKind(3)
+)
+
+//Status codes of spans, numbered as in OTLP.
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+//Span is one timed operation of a trace. It's exported once
+//it's ended, if its trace is sampled.
+type Span struct {
+	sync.Mutex
+	name          string
+	kind          SpanKind
+	context       SpanContext
+	parent        SpanID
+	start         time.Time
+	end           time.Time
+	attributes    []attribute
+	status        int
+	statusMessage string
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+//Start begins a span under the one of ctx, or under the remote
+//span ctx carries, or of a new trace if it has neither. The
+//context returned carries the new span.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		name:  name,
+		kind:  kind,
+		start: time.Now(),
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.context.TraceID = parent.context.TraceID
+		span.context.Sampled = parent.context.Sampled
+		span.parent = parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		span.context.TraceID = remote.TraceID
+		span.context.Sampled = remote.Sampled
+		span.parent = remote.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Sampled = true
+	}
+	rand.Read(span.context.SpanID[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+//SpanFromContext is the span ctx carries, nil if there's none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+//ContextWithRemoteParent is ctx whose spans are started under
+//sc, a span of another process.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func (span *Span) SpanContext() SpanContext {
+	return span.context
+}
+
+//SetAttribute adds key to the span, or replaces its value.
+//Values are strings, integers, floats or bools, anything else
+//is exported as its string.
+func (span *Span) SetAttribute(key string, value interface{}) {
+	span.Lock()
+	defer span.Unlock()
+	for idx := range span.attributes {
+		if span.attributes[idx].key == key {
+			span.attributes[idx].value = value
+			return
+		}
+	}
+	span.attributes = append(span.attributes, attribute{key, value})
+}
+
+//SetError marks the span as failed by err.
+func (span *Span) SetError(err error) {
+	span.Lock()
+	defer span.Unlock()
+	span.status = StatusError
+	if err != nil {
+		span.statusMessage = err.Error()
+	}
+}
+
+func (span *Span) SetStatus(status int, message string) {
+	span.Lock()
+	defer span.Unlock()
+	span.status = status
+	span.statusMessage = message
+}
+
+//End finishes the span and hands it to the exporter, ending it
+//again does nothing.
+func (span *Span) End() {
+	span.Lock()
+	if !span.end.IsZero() {
+		span.Unlock()
+		return
+	}
+	span.end = time.Now()
+	span.Unlock()
+	if span.context.Sampled {
+		enqueue(span)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -22,12 +23,54 @@ const (
 	//to access the resource. We'll use it to redirect client
 	//to use https in case http scheme is used.
 	HerokuForwardedProto = "X-Forwarded-Proto"
+	//RequestIDHeader names a request, clients may send one of
+	//their own and get it back in the response.
+	RequestIDHeader = "X-Request-ID"
 )
 
+type requestIDKey struct{}
+
 func GetContext() context.Context {
 	return context.Background()
 }
 
+//detachedContext has the values of a context which may be done
+//before the work using it is.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+//Detach is ctx without its deadline and cancellation, for work
+//which has to be finished once started, though the request it
+//was started for is over. Logs and traces still go with it.
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+//WithRequestID is ctx carrying the id of the request it's for.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+//GetRequestID is the id of the request ctx is for, empty if it
+//isn't for one.
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
 type StringSlice []string
 
 func (slice StringSlice) Contains(s string) bool {
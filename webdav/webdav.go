@@ -0,0 +1,246 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Namespaces of the properties CalDAV clients ask for.
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+)
+
+//MediaType of request and response bodies.
+const MediaType = "application/xml"
+
+//maxElementDepth bounds how deep request bodies may nest.
+const maxElementDepth = 32
+
+//Element is an XML element of a request body, with what it
+//holds.
+type Element struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []Element  `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+//Is tells whether e is space:local.
+func (e *Element) Is(space, local string) bool {
+	return e.XMLName.Space == space && e.XMLName.Local == local
+}
+
+//Child is the first child element space:local, nil if there's
+//none.
+func (e *Element) Child(space, local string) *Element {
+	for idx := range e.Children {
+		if e.Children[idx].Is(space, local) {
+			return &e.Children[idx]
+		}
+	}
+	return nil
+}
+
+//Attr is the value of the attribute called name, whatever its
+//namespace.
+func (e *Element) Attr(name string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func (e *Element) depth() int {
+	deepest := 0
+	for idx := range e.Children {
+		if depth := e.Children[idx].depth(); depth > deepest {
+			deepest = depth
+		}
+	}
+	return deepest + 1
+}
+
+//Parse reads a request body, nil for an empty one.
+func Parse(r io.Reader) (*Element, error) {
+	root := &Element{}
+	err := xml.NewDecoder(r).Decode(root)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "body isn't XML")
+	}
+	if root.depth() > maxElementDepth {
+		return nil, errors.New("body is nested too deep")
+	}
+	return root, nil
+}
+
+//Property is a WebDAV property, Inner is its value as XML.
+type Property struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+func Prop(space, local, inner string) Property {
+	return Property{XMLName: xml.Name{Space: space, Local: local}, Inner: inner}
+}
+
+//TextProp is a property whose value is text, it's escaped.
+func TextProp(space, local, text string) Property {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return Prop(space, local, escaped.String())
+}
+
+//HrefProp is a property holding one DAV:href.
+func HrefProp(space, local, href string) Property {
+	return Prop(space, local, Href(href))
+}
+
+//Href is href as a DAV:href element, properties of other
+//namespaces hold them too.
+func Href(href string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(href))
+	return `<href xmlns="DAV:">` + escaped.String() + "</href>"
+}
+
+//PropRequest is what a PROPFIND or REPORT asks for, the values
+//of Names, all properties or only their names.
+type PropRequest struct {
+	AllProp  bool
+	PropName bool
+	Names    []xml.Name
+}
+
+//ParsePropRequest reads the DAV:prop, DAV:allprop or
+//DAV:propname held by e. Without any of them, or without e,
+//all properties are asked for.
+func ParsePropRequest(e *Element) *PropRequest {
+	request := &PropRequest{AllProp: true}
+	if e == nil {
+		return request
+	}
+	if e.Child(NamespaceDAV, "propname") != nil {
+		return &PropRequest{PropName: true}
+	}
+	if prop := e.Child(NamespaceDAV, "prop"); prop != nil {
+		request.AllProp = false
+		for _, child := range prop.Children {
+			request.Names = append(request.Names, child.XMLName)
+		}
+	}
+	return request
+}
+
+//Asks tells whether the property space:local is named in the
+//request. Expensive properties, which allprop leaves out, are
+//only sent when they're asked for.
+func (request *PropRequest) Asks(space, local string) bool {
+	for _, name := range request.Names {
+		if name.Space == space && name.Local == local {
+			return true
+		}
+	}
+	return false
+}
+
+//Propstats splits what's asked for into the available
+//properties, with status 200, and those which aren't, with
+//status 404.
+func (request *PropRequest) Propstats(available []Property) []Propstat {
+	var found, missing []Property
+	switch {
+	case request.PropName:
+		for _, property := range available {
+			found = append(found, Property{XMLName: property.XMLName})
+		}
+	case request.AllProp:
+		found = available
+	default:
+		for _, name := range request.Names {
+			property := Property{XMLName: name}
+			ok := false
+			for _, candidate := range available {
+				if candidate.XMLName == name {
+					property, ok = candidate, true
+					break
+				}
+			}
+			if ok {
+				found = append(found, property)
+			} else {
+				missing = append(missing, property)
+			}
+		}
+	}
+	var propstats []Propstat
+	if len(found) > 0 {
+		propstats = append(propstats, Propstat{Prop: PropList{found}, Status: Status(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		propstats = append(propstats, Propstat{Prop: PropList{missing}, Status: Status(http.StatusNotFound)})
+	}
+	return propstats
+}
+
+//Status is code as the status line multistatus responses
+//carry.
+func Status(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+type PropList struct {
+	Properties []Property `xml:",any"`
+}
+
+type Propstat struct {
+	Prop   PropList `xml:"prop"`
+	Status string   `xml:"status"`
+}
+
+//Response is the outcome for one resource, either its
+//properties or, for a resource which is gone, only a status.
+type Response struct {
+	Href      string     `xml:"href"`
+	Propstats []Propstat `xml:"propstat,omitempty"`
+	Status    string     `xml:"status,omitempty"`
+}
+
+type Multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []Response `xml:"response"`
+	SyncToken string     `xml:"sync-token,omitempty"`
+}
+
+//Write sends the multistatus with status 207.
+func (ms *Multistatus) Write(w http.ResponseWriter) error {
+	body, err := xml.Marshal(ms)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", MediaType+"; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	if _, err = io.WriteString(w, xml.Header); err == nil {
+		_, err = w.Write(body)
+	}
+	return err
+}
+
+//WriteError answers with status and the precondition or
+//postcondition which failed, space:local.
+func WriteError(w http.ResponseWriter, status int, space, local string) {
+	w.Header().Set("Content-Type", MediaType+"; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `%s<error xmlns="DAV:"><%s xmlns="%s"/></error>`, xml.Header, local, space)
+}
@@ -0,0 +1,212 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//Conn is the server end of a WebSocket, RFC 6455. It sends
+//text messages, what the client sends is only read for the
+//control frames.
+type Conn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	writeLock sync.Mutex
+	closeSent bool
+}
+
+const (
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	closeNormal = 1000
+	//maxMessage is the largest frame read from clients, they
+	//have nothing to send us but control frames.
+	maxMessage   = 1 << 16
+	writeTimeout = 10 * time.Second
+)
+
+func headerHasToken(r *http.Request, header, token string) bool {
+	for _, value := range r.Header[http.CanonicalHeaderKey(header)] {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//IsUpgrade is true when r asks to switch to a WebSocket.
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r, "Connection", "upgrade") && headerHasToken(r, "Upgrade", "websocket")
+}
+
+//Accept is the Sec-WebSocket-Accept answering key.
+func Accept(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+//Upgrade switches the connection of r to a WebSocket. When it
+//fails before taking over the connection nothing has been
+//written, the caller answers.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) {
+		return nil, errors.New("not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, errors.New("websocket version must be 13")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("Sec-WebSocket-Key is missing")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection can't be taken over")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't take over connection")
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + Accept(key) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err = rw.WriteString(response); err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "couldn't complete handshake")
+	}
+	return &Conn{conn: conn, reader: rw.Reader}, nil
+}
+
+//writeFrame sends one unfragmented frame, server frames
+//aren't masked.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closeSent {
+		return errors.New("websocket is closing")
+	}
+	c.closeSent = opcode == opClose
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) WriteText(message []byte) error {
+	return c.writeFrame(opText, message)
+}
+
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+//Close says goodbye to the client and closes the connection.
+func (c *Conn) Close() error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, closeNormal)
+	c.writeFrame(opClose, payload)
+	return c.conn.Close()
+}
+
+//readFrame reads one frame sent by the client, which has to
+//mask it.
+func (c *Conn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0f
+	if head[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frame isn't masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxMessage {
+		return 0, nil, errors.Errorf("client frame of %d bytes is too large", length)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for idx := range payload {
+		payload[idx] ^= mask[idx%4]
+	}
+	return opcode, payload, nil
+}
+
+//ReadLoop answers pings and ignores messages until the client
+//closes the connection, or it fails. It returns nil for a
+//close the client started.
+func (c *Conn) ReadLoop() error {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case opPing:
+			if err = c.writeFrame(opPong, payload); err != nil {
+				return err
+			}
+		case opClose:
+			c.writeFrame(opClose, payload)
+			return nil
+		case opText, opBinary, opContinuation, opPong:
+		default:
+			return errors.Errorf("unknown opcode %d", opcode)
+		}
+	}
+}